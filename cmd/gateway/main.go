@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/gateway"
+	"github.com/Mona-bele/rote-notify/core/health"
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	envPath := flag.String("env", ".env", "path of the env file")
+	prefetch := flag.Int("prefetch", 16, "unacked messages per user before the broker stops sending")
+	withMetrics := flag.Bool("metrics", false, "serve Prometheus metrics on /metrics")
+	audience := flag.String("audience", gateway.DefaultAudience, "audience of the client tokens, distinct from JWT_AUDIENCE")
+	flag.Parse()
+
+	logutils.InitLogger()
+	e := env.LoadEnv(*envPath)
+	if *audience == e.JwtAudience {
+		logutils.Fatal("The client token audience must differ from JWT_AUDIENCE", nil, logutils.Fields{"audience": *audience})
+	}
+
+	j, err := jwt.NewJWTFromEnv(e)
+	if err != nil {
+		logutils.Fatal("Failed to create a new JWT instance", err, nil)
+	}
+
+	rmq := rabbitmq.NewRabbitMQ(e)
+	defer rmq.CloseRabbitMQ()
+	// Each stream consumes on its own channel, the prefetch applies to each of them
+	rmq.Prefetch = *prefetch
+
+	hub := gateway.NewHub(rmq, *prefetch)
+	auth := gateway.NewJWTAuthenticator(j, e.JwtIssuer, *audience)
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", gateway.NewWebSocketHandler(hub, auth, gateway.WebSocketConfig{}))
+	mux.Handle("/events", gateway.NewSSEHandler(hub, auth, gateway.SSEConfig{}))
+	if *withMetrics {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	checks := health.NewHealth(health.DefaultTimeout)
+	checks.AddLiveness("rabbitmq_connection", health.Connection(rmq))
+	checks.AddLiveness("rabbitmq_channel", health.Channel(rmq))
+	checks.AddReadiness("signing_key", health.SigningKey(j))
+	checks.Register(mux)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:        *addr,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logutils.Info("Gateway listening", logutils.Fields{"addr": *addr})
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logutils.Fatal("Gateway stopped", err, nil)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/devices"
+	"github.com/Mona-bele/rote-notify/core/health"
+	"github.com/Mona-bele/rote-notify/core/push_worker"
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/Mona-bele/rote-notify/pkg/push"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+)
+
+func main() {
+	addr := flag.String("addr", ":8082", "address to serve the health checks on")
+	envPath := flag.String("env", ".env", "path of the env file")
+	devicesPath := flag.String("devices", "devices.db", "path of the device registry file")
+	prefetch := flag.Int("prefetch", 16, "unacked messages handled at once before the broker stops sending")
+	withMetrics := flag.Bool("metrics", false, "serve Prometheus metrics on /metrics")
+	flag.Parse()
+
+	logutils.InitLogger()
+	e := env.LoadEnv(*envPath)
+
+	registry, err := devices.NewBoltRegistry(*devicesPath, devices.DefaultStaleAfter)
+	if err != nil {
+		logutils.Fatal("Failed to open the device registry", err, nil)
+	}
+	defer registry.Close()
+
+	worker, err := push_worker.NewWorkerFromEnv(e, push_worker.NewRegistryDeviceStore(registry), providers())
+	if err != nil {
+		logutils.Fatal("Failed to create the push worker", err, nil)
+	}
+	defer worker.RabbitMQ.CloseRabbitMQ()
+
+	worker.RabbitMQ.Prefetch = *prefetch
+
+	j, err := jwt.NewJWTFromEnv(e)
+	if err != nil {
+		logutils.Fatal("Failed to create a new JWT instance", err, nil)
+	}
+
+	mux := http.NewServeMux()
+	if *withMetrics {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	checks := health.NewHealth(health.DefaultTimeout)
+	checks.AddLiveness("rabbitmq_connection", health.Connection(worker.RabbitMQ))
+	checks.AddLiveness("rabbitmq_channel", health.Channel(worker.RabbitMQ))
+	checks.AddReadiness("signing_key", health.SigningKey(j))
+	checks.Register(mux)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go devices.PruneEvery(ctx, registry, devices.DefaultPruneInterval)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		logutils.Info("Push worker health listening", logutils.Fields{"addr": *addr})
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logutils.Error("Health server stopped", err, nil)
+		}
+	}()
+
+	logutils.Info("Push worker consuming", logutils.Fields{"queue": push_worker.Queue})
+	if err := worker.Run(ctx, *prefetch); err != nil {
+		logutils.Error("Push worker stopped", err, nil)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdownCtx)
+}
+
+// providers configures FCM and APNs from the environment, a platform without credentials has no provider
+func providers() map[string]push.Provider {
+	providers := make(map[string]push.Provider)
+	if projectID := os.Getenv("FCM_PROJECT_ID"); projectID != "" {
+		fcm := push.NewFCM(push.FCMConfig{ProjectID: projectID, Token: push.StaticToken(os.Getenv("FCM_ACCESS_TOKEN"))})
+		providers[push.PlatformAndroid] = fcm
+		providers[push.PlatformWeb] = fcm
+	}
+	if topic := os.Getenv("APNS_TOPIC"); topic != "" {
+		providers[push.PlatformIOS] = push.NewAPNs(push.APNsConfig{Topic: topic, Token: push.StaticToken(os.Getenv("APNS_TOKEN"))})
+	}
+
+	return providers
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/audit"
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/notifications_user_id"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// send sends a notification to a user
+func (c *cli) send(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	correlationID := flags.String("correlation-id", "", "check the transaction lifecycle of this correlation ID")
+	ttl := flags.Duration("ttl", 0, "validity of the notification, the one of the type when 0")
+	notifierFlags := addNotifierFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 2 {
+		return usageError("send <user> <type>")
+	}
+	if *ttl < 0 {
+		return usageError("-ttl must not be negative")
+	}
+
+	userID, typeMessage := flags.Arg(0), entity.NotifyTypeMessage(flags.Arg(1))
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		return err
+	}
+
+	// The catalog registers its types, so it is loaded before checking the type
+	options, err := notifierFlags.options()
+	if err != nil {
+		return err
+	}
+	if !typeMessage.IsRegistered() {
+		options.close()
+		return fmt.Errorf("unknown notification type %q, see types list", typeMessage)
+	}
+
+	notifier := notifications_user_id.NewNotificationsUserId(c.env(), options.opts...)
+	if notifier == nil {
+		options.close()
+		return errors.New("failed to create the notifier")
+	}
+	defer notifier.CloseNotificationsUserId()
+
+	if *correlationID != "" {
+		err = notifier.NotifyTransactionWithExpiry(ctx, userID, *correlationID, typeMessage, *ttl)
+	} else {
+		err = notifier.NotifyUserIdWithExpiry(ctx, userID, typeMessage, *ttl)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := map[string]string{"user_id": userID, "type": typeMessage.String(), "status": "sent"}
+	return c.print(result, []string{"USER", "TYPE", "STATUS"}, [][]string{{userID, typeMessage.String(), "sent"}})
+}
+
+// tailEntry struct
+type tailEntry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Version int       `json:"version,omitempty"`
+	// CorrelationID is set on the notifications of a transaction
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// tail prints the messages published to a user from a temporary queue, the queue of the user keeps them
+func (c *cli) tail(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 {
+		return usageError("tail <user>")
+	}
+	if err := rabbitmq.ValidateUserID(flags.Arg(0)); err != nil {
+		return err
+	}
+
+	e := c.env()
+	j, err := jwt.NewJWTFromEnv(e)
+	if err != nil {
+		return err
+	}
+
+	rmq := rabbitmq.NewRabbitMQ(e)
+	defer rmq.CloseRabbitMQ()
+
+	// A user without a queue is reported instead of tailed silently
+	if _, err := rmq.UserQueueStats(flags.Arg(0)); err != nil {
+		return fmt.Errorf("user %s has no queue: %w", flags.Arg(0), err)
+	}
+	msgs, err := rmq.TapUserMessages(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("the broker stopped the tail")
+			}
+
+			entry := tailEntry{ID: msg.MessageId, Time: msg.Timestamp, Type: msg.Type}
+			row := []string{entry.ID, entry.Time.Format(time.RFC3339), entry.Type, ""}
+			envelope, err := entity.DecodeNotifyType(msg.Body)
+			if err != nil {
+				entry.Error = err.Error()
+				row[3] = entry.Error
+			} else {
+				entry.Version, entry.CorrelationID = envelope.Version, envelope.CorrelationID
+				token, err := j.ParseToken(envelope.Body, e.JwtIssuer, e.JwtAudience, e.JwtSubject)
+				if err != nil {
+					entry.Error = err.Error()
+					row[3] = "invalid token: " + entry.Error
+				} else {
+					entry.Payload = json.RawMessage(j.GetPayload(token))
+					row[3] = string(entry.Payload)
+				}
+			}
+			if err := c.print(entry, []string{"ID", "TIME", "TYPE", "PAYLOAD"}, [][]string{row}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// queue inspects, deletes or purges a user queue
+func (c *cli) queue(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return usageError("queue stats|delete|purge <user>")
+	}
+
+	action, userID := args[0], args[1]
+	if action != "stats" && action != "delete" && action != "purge" {
+		return usageError("unknown queue action %q", action)
+	}
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		return err
+	}
+
+	rmq := rabbitmq.NewRabbitMQ(c.env())
+	defer rmq.CloseRabbitMQ()
+
+	switch action {
+	case "stats":
+		q, err := rmq.UserQueueStats(userID)
+		if err != nil {
+			return err
+		}
+		stats := map[string]any{"queue": q.Name, "messages": q.Messages, "consumers": q.Consumers}
+		return c.print(stats, []string{"QUEUE", "MESSAGES", "CONSUMERS"}, [][]string{{q.Name, strconv.Itoa(q.Messages), strconv.Itoa(q.Consumers)}})
+
+	case "purge":
+		count, err := rmq.PurgeUserQueue(userID)
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]any{"user_id": userID, "purged": count}, []string{"USER", "PURGED"}, [][]string{{userID, strconv.Itoa(count)}})
+
+	default:
+		if err := rmq.DeleteUserQueue(userID); err != nil {
+			return err
+		}
+		return c.print(map[string]any{"user_id": userID, "deleted": true}, []string{"USER", "DELETED"}, [][]string{{userID, "true"}})
+	}
+}
+
+// token verifies a notification token with the configured key and prints its claims
+func (c *cli) token(ctx context.Context, args []string) error {
+	if len(args) != 2 || args[0] != "verify" {
+		return usageError("token verify <jwt>")
+	}
+
+	e := c.env()
+	j, err := jwt.NewJWTFromEnv(e)
+	if err != nil {
+		return err
+	}
+
+	token, err := j.ParseToken(args[1], e.JwtIssuer, e.JwtAudience, e.JwtSubject)
+	if err != nil {
+		return err
+	}
+
+	claims, ok := token.Claims.(gojwt.MapClaims)
+	if !ok {
+		return errors.New("unexpected token claims")
+	}
+
+	keys := make([]string, 0, len(claims))
+	for key := range claims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, []string{key, fmt.Sprint(claims[key])})
+	}
+
+	return c.print(claims, []string{"CLAIM", "VALUE"}, rows)
+}
+
+// typeEntry struct
+type typeEntry struct {
+	Type     string `json:"type"`
+	Category string `json:"category"`
+	Priority string `json:"priority"`
+	TTL      string `json:"ttl"`
+	Message  string `json:"message"`
+	Family   string `json:"family,omitempty"`
+}
+
+// types lists the notification types
+func (c *cli) types(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return usageError("types list")
+	}
+
+	defs := entity.DefaultRegistry.Types()
+	entries := make([]typeEntry, 0, len(defs))
+	for _, def := range defs {
+		entry := typeEntry{
+			Type:     def.Name.String(),
+			Category: string(def.Category),
+			Priority: string(def.Priority),
+			TTL:      def.GetTTL().String(),
+			Message:  def.Message,
+		}
+		if lifecycle, ok := def.Name.GetLifecycle(); ok {
+			entry.Family = string(lifecycle.Family)
+		}
+		entries = append(entries, entry)
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{entry.Type, entry.Category, entry.Priority, entry.TTL, entry.Family, entry.Message})
+	}
+
+	return c.print(entries, []string{"TYPE", "CATEGORY", "PRIORITY", "TTL", "FAMILY", "MESSAGE"}, rows)
+}
+
+// generatedKey struct
+type generatedKey struct {
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	// EnvValue is the value of JWT_NOTIFY_PRIVATE_KEY
+	EnvValue string `json:"env_value"`
+}
+
+// keys generates an RSA key to sign the notification tokens
+func (c *cli) keys(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return usageError("keys generate [-bits n]")
+	}
+
+	flags := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	bits := flags.Int("bits", 2048, "size of the RSA key")
+	if err := flags.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	if *bits < 2048 {
+		return usageError("the key must have at least 2048 bits")
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, *bits)
+	if err != nil {
+		return err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	key := generatedKey{
+		PrivateKey: string(privatePEM),
+		PublicKey:  string(publicPEM),
+		EnvValue:   base64.StdEncoding.EncodeToString(privatePEM),
+	}
+
+	if c.output == "json" {
+		return c.print(key, nil, nil)
+	}
+
+	_, err = fmt.Fprintf(c.stdout, "%s\n%s\nJWT_NOTIFY_PRIVATE_KEY=%s\n", key.PrivateKey, key.PublicKey, key.EnvValue)
+	return err
+}
+
+// audit verifies or exports an audit log
+func (c *cli) audit(ctx context.Context, args []string) error {
+	if len(args) < 2 || (args[0] != "verify" && args[0] != "export") {
+		return usageError("audit verify|export <file>")
+	}
+
+	flags := flag.NewFlagSet("audit "+args[0], flag.ContinueOnError)
+	userID := flags.String("user", "", "export only the entries of this user")
+	since := flags.String("since", "", "export the entries from this RFC 3339 time")
+	until := flags.String("until", "", "export the entries before this RFC 3339 time")
+	if err := flags.Parse(args[2:]); err != nil {
+		return errUsage
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if args[0] == "verify" {
+		key := os.Getenv("AUDIT_KEY")
+		if key == "" {
+			return errors.New("AUDIT_KEY is not set")
+		}
+		// The head kept next to the log detects removed last entries
+		head, err := audit.ReadHead(args[1])
+		if err != nil {
+			return err
+		}
+		count, err := audit.VerifyWithHead(file, []byte(key), head)
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]any{"entries": count, "valid": true}, []string{"ENTRIES", "VALID"}, [][]string{{strconv.Itoa(count), "true"}})
+	}
+
+	filter := audit.Filter{UserID: *userID}
+	for _, bound := range []struct {
+		value string
+		to    *time.Time
+	}{{*since, &filter.Since}, {*until, &filter.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		if *bound.to, err = time.Parse(time.RFC3339, bound.value); err != nil {
+			return usageError("invalid time %q", bound.value)
+		}
+	}
+
+	// The export is always JSON Lines, the output flag does not apply
+	_, err = audit.Export(file, c.stdout, filter)
+	return err
+}
+
+// catalog checks a catalog file before it is deployed
+func (c *cli) catalog(ctx context.Context, args []string) error {
+	if len(args) != 2 || args[0] != "validate" {
+		return usageError("catalog validate <file>")
+	}
+
+	loaded, err := catalog.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	count := strconv.Itoa(len(loaded.Types))
+	return c.print(map[string]any{"types": len(loaded.Types), "default_locale": loaded.DefaultLocale, "valid": true},
+		[]string{"TYPES", "DEFAULT LOCALE", "VALID"}, [][]string{{count, loaded.DefaultLocale, "true"}})
+}
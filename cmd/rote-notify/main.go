@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/rs/zerolog"
+)
+
+const usage = `Usage: rote-notify [-env path] [-o table|json] [-v] <command> [args]
+
+Commands:
+  serve                        run the HTTP (and optional gRPC) API
+  send <user> <type>           send a notification to a user
+  tail <user>                  print the notifications published to a user
+  queue stats|delete|purge <user>
+                               inspect or clean up a user queue
+  token verify <jwt>           verify a notification token and print its claims
+  types list                   list the notification types
+  keys generate                generate an RSA signing key
+  audit verify <file>          check the hash chain of an audit log with AUDIT_KEY
+  audit export <file>          export audit entries as JSON Lines
+  catalog validate <file>      check a notification type catalog against its schema
+`
+
+// cli holds the global flags shared by every command
+type cli struct {
+	stdout  io.Writer
+	stderr  io.Writer
+	envPath string
+	output  string
+}
+
+// env loads the environment, only commands talking to RabbitMQ or signing need it
+func (c *cli) env() *env.Env {
+	return env.LoadEnv(c.envPath)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses the global flags and runs the command, it returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+
+	flags := flag.NewFlagSet("rote-notify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	flags.StringVar(&c.envPath, "env", ".env", "path of the env file")
+	flags.StringVar(&c.output, "o", "table", "output format: table or json")
+	verbose := flags.Bool("v", false, "log debug messages to stderr")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(stderr, "invalid output format %q\n", c.output)
+		return 2
+	}
+
+	// Operator commands keep stdout for their output
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	if *verbose {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	commands := map[string]func(ctx context.Context, args []string) error{
+		"serve":   c.serve,
+		"send":    c.send,
+		"tail":    c.tail,
+		"queue":   c.queue,
+		"token":   c.token,
+		"types":   c.types,
+		"keys":    c.keys,
+		"audit":   c.audit,
+		"catalog": c.catalog,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		flags.Usage()
+		return 2
+	}
+
+	if err := command(ctx, args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "%v\n\n", err)
+			flags.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
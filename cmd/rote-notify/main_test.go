@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mona-bele/rote-notify/core/audit"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("Test types list as JSON", func(t *testing.T) {
+		// Arrange
+		var stdout, stderr bytes.Buffer
+		// Act
+		code := run(context.Background(), []string{"-o", "json", "types", "list"}, &stdout, &stderr)
+		var entries []typeEntry
+		err := json.Unmarshal(stdout.Bytes(), &entries)
+		// Assert
+		assert.Equal(t, 0, code)
+		assert.Nil(t, err)
+		assert.Contains(t, entries, typeEntry{Type: "deposit_process", Category: "deposit", Priority: "low", TTL: "1h0m0s", Message: "Processing the deposit", Family: "deposit"})
+	})
+
+	t.Run("Test types list as table", func(t *testing.T) {
+		// Arrange
+		var stdout, stderr bytes.Buffer
+		// Act
+		code := run(context.Background(), []string{"types", "list"}, &stdout, &stderr)
+		// Assert
+		assert.Equal(t, 0, code)
+		assert.Contains(t, stdout.String(), "TYPE")
+		assert.Contains(t, stdout.String(), "new_post")
+	})
+
+	t.Run("Test keys generate", func(t *testing.T) {
+		// Arrange
+		var stdout, stderr bytes.Buffer
+		// Act
+		code := run(context.Background(), []string{"-o", "json", "keys", "generate"}, &stdout, &stderr)
+		var key generatedKey
+		_ = json.Unmarshal(stdout.Bytes(), &key)
+		decoded, decodeErr := base64.StdEncoding.DecodeString(key.EnvValue)
+		_, parseErr := jwt.ParsePrivateKey(decoded)
+		// Assert
+		assert.Equal(t, 0, code)
+		assert.Nil(t, decodeErr)
+		assert.Nil(t, parseErr)
+		assert.Contains(t, key.PublicKey, "PUBLIC KEY")
+	})
+
+	t.Run("Test audit verify and export", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		t.Setenv("AUDIT_KEY", "audit-key")
+		log, _ := audit.OpenFileLog(path, []byte("audit-key"))
+		_, _ = log.Append(context.Background(), audit.Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: audit.OutcomePublished})
+		_, _ = log.Append(context.Background(), audit.Record{UserID: "2", Type: "deposit", MessageID: "b", Outcome: audit.OutcomePublished})
+		_ = log.Close()
+		var stdout, stderr, exported bytes.Buffer
+		// Act
+		verified := run(context.Background(), []string{"-o", "json", "audit", "verify", path}, &stdout, &stderr)
+		export := run(context.Background(), []string{"audit", "export", path, "-user", "2"}, &exported, &stderr)
+		data, _ := os.ReadFile(path)
+		_ = os.WriteFile(path, bytes.Replace(data, []byte(`"user_id":"2"`), []byte(`"user_id":"3"`), 1), 0o600)
+		tampered := run(context.Background(), []string{"audit", "verify", path}, &stdout, &stderr)
+		// Assert
+		assert.Equal(t, 0, verified)
+		assert.JSONEq(t, `{"entries":2,"valid":true}`, stdout.String())
+		assert.Equal(t, 0, export)
+		assert.Contains(t, exported.String(), `"message_id":"b"`)
+		assert.NotContains(t, exported.String(), `"message_id":"a"`)
+		assert.Equal(t, 1, tampered)
+		assert.Contains(t, stderr.String(), "audit log tampered: line 2")
+	})
+
+	t.Run("Test catalog validate", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		valid := filepath.Join(dir, "catalog.json")
+		invalid := filepath.Join(dir, "catalog.yaml")
+		_ = os.WriteFile(valid, []byte(`{"version": 1, "types": {"new_post": {"ttl": "48h"}}}`), 0o600)
+		_ = os.WriteFile(invalid, []byte("version: 1\ntypes:\n  new_post:\n    priority: urgent"), 0o600)
+		var stdout, stderr bytes.Buffer
+		// Act
+		validated := run(context.Background(), []string{"-o", "json", "catalog", "validate", valid}, &stdout, &stderr)
+		rejected := run(context.Background(), []string{"catalog", "validate", invalid}, &bytes.Buffer{}, &stderr)
+		// Assert
+		assert.Equal(t, 0, validated)
+		assert.JSONEq(t, `{"types":1,"default_locale":"en","valid":true}`, stdout.String())
+		assert.Equal(t, 1, rejected)
+		assert.Contains(t, stderr.String(), "invalid catalog")
+	})
+
+	t.Run("Test invalid usage", func(t *testing.T) {
+		// Arrange
+		var stdout, stderr bytes.Buffer
+		// Act
+		unknown := run(context.Background(), []string{"unknown"}, &stdout, &stderr)
+		missing := run(context.Background(), []string{"send", "1"}, &stdout, &stderr)
+		negative := run(context.Background(), []string{"send", "-ttl", "-1m", "-correlation-id", "tx-1", "1", "deposit"}, &stdout, &stderr)
+		format := run(context.Background(), []string{"-o", "yaml", "types", "list"}, &stdout, &stderr)
+		// Assert
+		assert.Equal(t, 2, unknown)
+		assert.Equal(t, 2, missing)
+		assert.Equal(t, 2, negative)
+		assert.Contains(t, stderr.String(), "-ttl must not be negative")
+		assert.Equal(t, 2, format)
+		assert.Contains(t, stderr.String(), "Usage: rote-notify")
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/Mona-bele/rote-notify/core/audit"
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/inbox"
+	"github.com/Mona-bele/rote-notify/core/notifications_user_id"
+)
+
+// notifierFlags are the flags configuring the notifier, shared by the commands that send
+type notifierFlags struct {
+	inbox   *string
+	audit   *string
+	catalog *string
+	metrics *bool
+}
+
+// addNotifierFlags registers the notifier flags on the flag set
+func addNotifierFlags(flags *flag.FlagSet) *notifierFlags {
+	return &notifierFlags{
+		inbox:   flags.String("inbox", "", "path of the inbox file, disabled when empty"),
+		audit:   flags.String("audit", "", "path of the audit log signed with AUDIT_KEY, disabled when empty"),
+		catalog: flags.String("catalog", "", "path of the notification type catalog, YAML or JSON, reloaded on change"),
+		metrics: flags.Bool("metrics", false, "record Prometheus metrics, serve exposes them on /metrics"),
+	}
+}
+
+// notifierOptions are the options built from the flags, with the stores they opened
+type notifierOptions struct {
+	opts    []notifications_user_id.Option
+	watcher *catalog.Watcher
+	audit   audit.Log
+	inbox   inbox.Store
+}
+
+// options loads the catalog and opens the stores of the flags, the catalog types are registered once it returns
+func (f *notifierFlags) options() (*notifierOptions, error) {
+	o := &notifierOptions{
+		opts: []notifications_user_id.Option{
+			notifications_user_id.WithInterceptors(notifications_user_id.ValidationInterceptor(), notifications_user_id.LoggingInterceptor()),
+		},
+	}
+	if *f.metrics {
+		o.opts = append(o.opts, notifications_user_id.WithInterceptors(notifications_user_id.MetricsInterceptor()))
+	}
+	if *f.catalog != "" {
+		watcher, err := catalog.NewWatcher(*f.catalog, entity.DefaultRegistry)
+		if err != nil {
+			return nil, err
+		}
+		o.watcher = watcher
+		o.opts = append(o.opts, notifications_user_id.WithCatalog(watcher))
+	}
+	if *f.audit != "" {
+		key := os.Getenv("AUDIT_KEY")
+		if key == "" {
+			return nil, errors.New("AUDIT_KEY is not set")
+		}
+		log, err := audit.OpenFileLog(*f.audit, []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		o.audit = log
+		o.opts = append(o.opts, notifications_user_id.WithAudit(log))
+	}
+	if *f.inbox != "" {
+		store, err := inbox.NewBoltStore(*f.inbox)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		o.inbox = store
+		o.opts = append(o.opts, notifications_user_id.WithInbox(store))
+	}
+
+	return o, nil
+}
+
+// run starts reloading the catalog and pruning the inbox until the context is done
+func (o *notifierOptions) run(ctx context.Context) {
+	if o.watcher != nil {
+		go o.watcher.Run(ctx, catalog.DefaultInterval)
+	}
+	if o.inbox != nil {
+		go inbox.PruneEvery(ctx, o.inbox, inbox.DefaultPruneInterval)
+	}
+}
+
+// close closes the stores when the notifier could not be created, the notifier closes them otherwise
+func (o *notifierOptions) close() {
+	if o.audit != nil {
+		_ = o.audit.Close()
+	}
+	if o.inbox != nil {
+		_ = o.inbox.Close()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/api"
+	"github.com/Mona-bele/rote-notify/core/gateway"
+	"github.com/Mona-bele/rote-notify/core/grpc_api"
+	"github.com/Mona-bele/rote-notify/core/notifications_user_id"
+	"github.com/Mona-bele/rote-notify/core/transaction"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+)
+
+// serve runs the HTTP API and, when -grpc-addr is set, the gRPC API
+func (c *cli) serve(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	grpcAddr := flags.String("grpc-addr", "", "address to serve gRPC on, disabled when empty")
+	audience := flags.String("audience", gateway.DefaultAudience, "audience of the user tokens streaming over gRPC, distinct from JWT_AUDIENCE")
+	notifierFlags := addNotifierFlags(flags)
+	maxPublishAge := flags.Duration("max-publish-age", 0, "not ready when nothing was published for this long, disabled when 0")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	logutils.InitLogger()
+	e := c.env()
+
+	// API keys are given as service1:key1,service2:key2
+	keys := api.ParseAPIKeys(os.Getenv("API_KEYS"))
+	if len(keys) == 0 {
+		return errors.New("API_KEYS is not set")
+	}
+
+	options, err := notifierFlags.options()
+	if err != nil {
+		return err
+	}
+
+	notifier := notifications_user_id.NewNotificationsUserId(e, options.opts...)
+	if notifier == nil {
+		options.close()
+		return errors.New("failed to create the notifier")
+	}
+	defer notifier.CloseNotificationsUserId()
+	options.run(ctx)
+	go transaction.PruneEvery(ctx, notifier.Transactions(), transaction.DefaultPruneInterval, transaction.DefaultRetention)
+
+	// The health routes are more specific than "/", so they report every check
+	checks := notifier.Health(*maxPublishAge)
+	mux := http.NewServeMux()
+	mux.Handle("/", api.NewServer(notifier, keys, checks.Ready))
+	checks.Register(mux)
+	if *notifierFlags.metrics {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+
+		if *audience == e.JwtAudience {
+			return errors.New("the user token audience must differ from JWT_AUDIENCE")
+		}
+		j, err := jwt.NewJWTFromEnv(e)
+		if err != nil {
+			return err
+		}
+
+		hub := gateway.NewHub(notifier.RabbitMQ, 16)
+		users := gateway.NewJWTAuthenticator(j, e.JwtIssuer, *audience)
+		grpcServer := grpc_api.NewGRPCServer(grpc_api.NewServer(notifier, hub, users), keys, grpc_api.Deadlines{Default: 10 * time.Second, Max: time.Minute})
+		defer grpcServer.Stop()
+
+		go func() {
+			logutils.Info("gRPC listening", logutils.Fields{"addr": *grpcAddr})
+			if err := grpcServer.Serve(listener); err != nil {
+				logutils.Error("gRPC stopped", err, nil)
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logutils.Info("API listening", logutils.Fields{"addr": *addr})
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// RenderFunc renders the summary text for count notifications of a type
+type RenderFunc func(typeMessage entity.NotifyTypeMessage, count int) string
+
+// TextFunc returns the text of a single notification of a type
+type TextFunc func(typeMessage entity.NotifyTypeMessage) string
+
+// SettleFunc is called with the outcome of the flush sending a collected notification
+type SettleFunc func(err error)
+
+// FlushFunc publishes the combined notification, a zero expiry keeps the validity of the type
+type FlushFunc func(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, count int, text string, expiry time.Duration) error
+
+// Rule struct
+type Rule struct {
+	// Window is how long notifications are collected before the summary is sent
+	Window time.Duration
+	// Threshold sends the summary as soon as this many notifications are collected, 0 disables it
+	Threshold int
+	// Render renders the summary text, DefaultRender is used when nil
+	Render RenderFunc
+}
+
+// group holds the notifications collected for a user and type
+type group struct {
+	userID      string
+	typeMessage entity.NotifyTypeMessage
+	count       int
+	// expiry is the shortest validity override of the collected notifications, 0 when none has one
+	expiry time.Duration
+	// settles are called with the outcome of the flush
+	settles []SettleFunc
+	timer   *time.Timer
+}
+
+// Aggregator struct
+type Aggregator struct {
+	rules  map[entity.NotifyTypeMessage]Rule
+	flush  FlushFunc
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+// NewAggregator creates a new Aggregator instance
+func NewAggregator(rules map[entity.NotifyTypeMessage]Rule, flush FlushFunc) *Aggregator {
+	return &Aggregator{
+		rules:  rules,
+		flush:  flush,
+		groups: make(map[string]*group),
+	}
+}
+
+// DefaultRender renders the built-in type message, with the count when there is more than one
+func DefaultRender(typeMessage entity.NotifyTypeMessage, count int) string {
+	return RenderText(entity.NotifyTypeMessage.GetNotifyTypeMessage)(typeMessage, count)
+}
+
+// RenderText renders the text of the type like DefaultRender, for callers with their own texts
+func RenderText(text TextFunc) RenderFunc {
+	return func(typeMessage entity.NotifyTypeMessage, count int) string {
+		if count <= 1 {
+			return text(typeMessage)
+		}
+
+		return fmt.Sprintf("%s (%d)", text(typeMessage), count)
+	}
+}
+
+// Add collects the notification, it returns false when the type is not aggregated
+func (a *Aggregator) Add(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage) (bool, error) {
+	return a.AddWithExpiry(ctx, userID, typeMessage, 0)
+}
+
+// AddWithExpiry collects the notification with a validity overriding the one of the type,
+// the summary is valid for the shortest override collected
+func (a *Aggregator) AddWithExpiry(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) (bool, error) {
+	return a.AddWithSettle(ctx, userID, typeMessage, expiry, nil)
+}
+
+// AddWithSettle collects the notification like AddWithExpiry, settle is called with the outcome of the flush
+// sending its summary. It is not called when the type is not aggregated.
+func (a *Aggregator) AddWithSettle(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration, settle SettleFunc) (bool, error) {
+	rule, ok := a.rules[typeMessage]
+	if !ok {
+		return false, nil
+	}
+
+	key := userID + "." + typeMessage.String()
+
+	a.mu.Lock()
+	g, ok := a.groups[key]
+	if !ok {
+		g = &group{userID: userID, typeMessage: typeMessage}
+		g.timer = time.AfterFunc(rule.Window, func() {
+			if err := a.flushKey(context.Background(), key, g); err != nil {
+				logutils.Error("Failed to flush aggregated notifications", err, logutils.Fields{"user_id": userID, "type": typeMessage.String()})
+			}
+		})
+		a.groups[key] = g
+	}
+	g.count++
+	if settle != nil {
+		g.settles = append(g.settles, settle)
+	}
+	if expiry > 0 && (g.expiry == 0 || expiry < g.expiry) {
+		g.expiry = expiry
+	}
+	full := rule.Threshold > 0 && g.count >= rule.Threshold
+	a.mu.Unlock()
+
+	if full {
+		return true, a.flushKey(ctx, key, g)
+	}
+
+	return true, nil
+}
+
+// Flush sends every pending summary
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	groups := make(map[string]*group, len(a.groups))
+	for key, g := range a.groups {
+		groups[key] = g
+	}
+	a.mu.Unlock()
+
+	var errs []error
+	for key, g := range groups {
+		errs = append(errs, a.flushKey(ctx, key, g))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Aggregates reports whether the notifications of the type are collected into a summary
+func (a *Aggregator) Aggregates(typeMessage entity.NotifyTypeMessage) bool {
+	_, ok := a.rules[typeMessage]
+	return ok
+}
+
+// Pending returns the number of notifications collected for a user and type
+func (a *Aggregator) Pending(userID string, typeMessage entity.NotifyTypeMessage) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	g, ok := a.groups[userID+"."+typeMessage.String()]
+	if !ok {
+		return 0
+	}
+
+	return g.count
+}
+
+// flushKey sends the summary of the group if it is still pending
+func (a *Aggregator) flushKey(ctx context.Context, key string, g *group) error {
+	a.mu.Lock()
+	if a.groups[key] != g {
+		a.mu.Unlock()
+		return nil
+	}
+	delete(a.groups, key)
+	g.timer.Stop()
+	count, expiry := g.count, g.expiry
+	a.mu.Unlock()
+
+	render := a.rules[g.typeMessage].Render
+	if render == nil {
+		render = DefaultRender
+	}
+
+	err := a.flush(ctx, g.userID, g.typeMessage, count, render(g.typeMessage, count), expiry)
+	for _, settle := range g.settles {
+		settle(err)
+	}
+
+	return err
+}
@@ -0,0 +1,153 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+type flushed struct {
+	userID      string
+	typeMessage entity.NotifyTypeMessage
+	count       int
+	text        string
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	flushed  []flushed
+	expiries []time.Duration
+}
+
+func (r *recorder) flush(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, count int, text string, expiry time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flushed = append(r.flushed, flushed{userID, typeMessage, count, text})
+	r.expiries = append(r.expiries, expiry)
+	return nil
+}
+
+func (r *recorder) get() []flushed {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]flushed(nil), r.flushed...)
+}
+
+func TestAggregator(t *testing.T) {
+	t.Run("Test Add ignores types without a rule", func(t *testing.T) {
+		// Arrange
+		rec := &recorder{}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{entity.NEW_POST: {Window: time.Hour}}, rec.flush)
+		// Act
+		aggregated, err := agg.Add(context.Background(), "1", entity.DEPOSIT)
+		// Assert
+		assert.Nil(t, err)
+		assert.False(t, aggregated)
+		assert.Empty(t, rec.get())
+	})
+
+	t.Run("Test Add flushes when the threshold is hit", func(t *testing.T) {
+		// Arrange
+		rec := &recorder{}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{
+			entity.NEW_POST: {
+				Window:    time.Hour,
+				Threshold: 30,
+				Render: func(typeMessage entity.NotifyTypeMessage, count int) string {
+					return fmt.Sprintf("%d new posts", count)
+				},
+			},
+		}, rec.flush)
+		// Act
+		for i := 0; i < 29; i++ {
+			_, _ = agg.Add(context.Background(), "1", entity.NEW_POST)
+		}
+		pending := agg.Pending("1", entity.NEW_POST)
+		aggregated, err := agg.Add(context.Background(), "1", entity.NEW_POST)
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, aggregated)
+		assert.Equal(t, 29, pending)
+		assert.Equal(t, 0, agg.Pending("1", entity.NEW_POST))
+		assert.Equal(t, []flushed{{"1", entity.NEW_POST, 30, "30 new posts"}}, rec.get())
+	})
+
+	t.Run("Test Add flushes when the window closes", func(t *testing.T) {
+		// Arrange
+		rec := &recorder{}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{entity.NEW_POST: {Window: 20 * time.Millisecond}}, rec.flush)
+		// Act
+		_, _ = agg.Add(context.Background(), "1", entity.NEW_POST)
+		_, _ = agg.Add(context.Background(), "1", entity.NEW_POST)
+		_, _ = agg.Add(context.Background(), "2", entity.NEW_POST)
+		// Assert
+		assert.Eventually(t, func() bool { return len(rec.get()) == 2 }, time.Second, 5*time.Millisecond)
+		assert.ElementsMatch(t, []flushed{
+			{"1", entity.NEW_POST, 2, "New post (2)"},
+			{"2", entity.NEW_POST, 1, "New post"},
+		}, rec.get())
+	})
+
+	t.Run("Test Flush sends every pending summary", func(t *testing.T) {
+		// Arrange
+		rec := &recorder{}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{entity.NEW_POST: {Window: time.Hour}}, rec.flush)
+		_, _ = agg.Add(context.Background(), "1", entity.NEW_POST)
+		// Act
+		err := agg.Flush(context.Background())
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []flushed{{"1", entity.NEW_POST, 1, "New post"}}, rec.get())
+	})
+
+	t.Run("Test the summary keeps the shortest expiry override", func(t *testing.T) {
+		// Arrange
+		rec := &recorder{}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{entity.NEW_POST: {Window: time.Hour}}, rec.flush)
+		_, _ = agg.AddWithExpiry(context.Background(), "1", entity.NEW_POST, time.Hour)
+		_, _ = agg.Add(context.Background(), "1", entity.NEW_POST)
+		_, _ = agg.AddWithExpiry(context.Background(), "1", entity.NEW_POST, 10*time.Minute)
+		_, _ = agg.Add(context.Background(), "2", entity.NEW_POST)
+		// Act
+		err := agg.Flush(context.Background())
+		// Assert
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []time.Duration{10 * time.Minute, 0}, rec.expiries)
+	})
+
+	t.Run("Test the settles get the outcome of the flush", func(t *testing.T) {
+		// Arrange
+		failing := func(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, count int, text string, expiry time.Duration) error {
+			return errors.New("broker is down")
+		}
+		agg := NewAggregator(map[entity.NotifyTypeMessage]Rule{entity.DEPOSIT_PROCESS: {Window: time.Hour}}, failing)
+		var settled []error
+		settle := func(err error) { settled = append(settled, err) }
+		_, _ = agg.AddWithSettle(context.Background(), "1", entity.DEPOSIT_PROCESS, 0, settle)
+		_, _ = agg.AddWithSettle(context.Background(), "1", entity.DEPOSIT_PROCESS, 0, settle)
+		aggregated, _ := agg.AddWithSettle(context.Background(), "1", entity.NEW_POST, 0, settle)
+		// Act
+		err := agg.Flush(context.Background())
+		// Assert, the type without a rule is not settled by the aggregator
+		assert.NotNil(t, err)
+		assert.False(t, aggregated)
+		assert.Len(t, settled, 2)
+		assert.EqualError(t, settled[0], "broker is down")
+	})
+
+	t.Run("Test RenderText renders the given texts", func(t *testing.T) {
+		// Arrange
+		render := RenderText(func(typeMessage entity.NotifyTypeMessage) string { return "Nouvelle publication" })
+		// Act
+		single, many := render(entity.NEW_POST, 1), render(entity.NEW_POST, 3)
+		// Assert
+		assert.Equal(t, "Nouvelle publication", single)
+		assert.Equal(t, "Nouvelle publication (3)", many)
+	})
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/transaction"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/ratelimit"
+)
+
+const (
+	// MaxBatchSize is the maximum number of notifications in a batch request
+	MaxBatchSize = 100
+	maxBodyBytes = 1 << 20
+
+	// StatusSent is the status of a published notification
+	StatusSent = "sent"
+	// StatusAggregated is the status of a notification collected into a summary sent later
+	StatusAggregated = "aggregated"
+	// StatusCollapsed is the status of a notification collapsed into a pending one by the rate limit
+	StatusCollapsed = "collapsed"
+	// StatusFailed is the status of a batch notification that was not sent
+	StatusFailed = "failed"
+)
+
+// Notifier is implemented by notifications_user_id.NotificationsUserId
+type Notifier interface {
+	NotifyUserIdWithExpiry(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error
+	NotifyTransactionWithExpiry(ctx context.Context, userID, correlationID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error
+	DeleteNotificationsUserId(ctx context.Context, userID string) error
+	Aggregates(typeMessage entity.NotifyTypeMessage) bool
+}
+
+// NotificationRequest struct
+type NotificationRequest struct {
+	UserID        string                   `json:"user_id"`
+	Type          entity.NotifyTypeMessage `json:"type"`
+	CorrelationID string                   `json:"correlation_id,omitempty"`
+	// TTL overrides the validity of the type when set, written as "90s" or "1h30m"
+	TTL catalog.Duration `json:"ttl,omitempty"`
+}
+
+// Result struct
+type Result struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+// Error struct
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	status  int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Server struct
+type Server struct {
+	notifier Notifier
+	keys     APIKeys
+	ready    func(ctx context.Context) error
+	mux      *http.ServeMux
+}
+
+// NewServer creates a new Server instance, ready reports whether the notifier can publish
+func NewServer(notifier Notifier, keys APIKeys, ready func(ctx context.Context) error) *Server {
+	s := &Server{notifier: notifier, keys: keys, ready: ready, mux: http.NewServeMux()}
+
+	s.mux.Handle("POST /v1/notifications", s.authenticated(s.postNotifications))
+	s.mux.Handle("DELETE /v1/users/{id}/queue", s.authenticated(s.deleteUserQueue))
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /readyz", s.readyz)
+
+	return s
+}
+
+// ServeHTTP routes the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// authenticated rejects requests without a valid API key
+func (s *Server) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service, ok := s.keys.service(r)
+		if !ok {
+			writeError(w, &Error{Code: "unauthorized", Message: "missing or invalid API key", status: http.StatusUnauthorized})
+			return
+		}
+
+		logutils.Debug("API request", logutils.Fields{"service": service, "method": r.Method, "path": r.URL.Path})
+		next(w, r)
+	})
+}
+
+// postNotifications sends a single notification object or a batch array
+func (s *Server) postNotifications(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, &Error{Code: "invalid_request", Message: "request body is too large", status: http.StatusRequestEntityTooLarge})
+			return
+		}
+		writeError(w, &Error{Code: "invalid_request", Message: "failed to read the request body: " + err.Error(), status: http.StatusBadRequest})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		s.postBatch(w, r, body)
+		return
+	}
+
+	var req NotificationRequest
+	if err := decode(body, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := validate(req); err != nil {
+		writeError(w, err)
+		return
+	}
+	status, notifyErr := s.notify(r.Context(), req)
+	if notifyErr != nil {
+		writeError(w, notifyErr)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, Result{Status: status})
+}
+
+// postBatch sends each notification of the batch and reports the result of each
+func (s *Server) postBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	var reqs []NotificationRequest
+	if err := decode(body, &reqs); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > MaxBatchSize {
+		writeError(w, &Error{Code: "invalid_request", Message: fmt.Sprintf("batch must have between 1 and %d notifications", MaxBatchSize), status: http.StatusBadRequest})
+		return
+	}
+
+	// Validate the whole batch first so an invalid entry sends nothing
+	for i, req := range reqs {
+		if err := validate(req); err != nil {
+			err.Message = fmt.Sprintf("notification %d: %s", i, err.Message)
+			writeError(w, err)
+			return
+		}
+	}
+
+	results := make([]Result, len(reqs))
+	for i, req := range reqs {
+		status, err := s.notify(r.Context(), req)
+		results[i] = Result{Index: i, Status: status}
+		if err != nil {
+			results[i].Status = StatusFailed
+			results[i].Error = err
+		}
+	}
+
+	writeJSON(w, http.StatusMultiStatus, map[string][]Result{"results": results})
+}
+
+// deleteUserQueue deletes the queue of the user
+func (s *Server) deleteUserQueue(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		writeError(w, &Error{Code: "invalid_request", Message: err.Error(), status: http.StatusBadRequest})
+		return
+	}
+	if err := s.notifier.DeleteNotificationsUserId(r.Context(), userID); err != nil {
+		writeError(w, &Error{Code: "broker_error", Message: err.Error(), status: http.StatusBadGateway})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.ready != nil {
+		if err := s.ready(r.Context()); err != nil {
+			writeError(w, &Error{Code: "not_ready", Message: err.Error(), status: http.StatusServiceUnavailable})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// notify sends the notification and returns its status, its error is mapped to an API error.
+// A notification collapsed by the rate limit is accepted, the pending one carries it.
+func (s *Server) notify(ctx context.Context, req NotificationRequest) (string, *Error) {
+	var err error
+	if req.CorrelationID != "" {
+		err = s.notifier.NotifyTransactionWithExpiry(ctx, req.UserID, req.CorrelationID, req.Type, time.Duration(req.TTL))
+	} else {
+		err = s.notifier.NotifyUserIdWithExpiry(ctx, req.UserID, req.Type, time.Duration(req.TTL))
+	}
+
+	switch {
+	case err == nil && s.notifier.Aggregates(req.Type):
+		return StatusAggregated, nil
+	case err == nil:
+		return StatusSent, nil
+	case errors.Is(err, ratelimit.ErrCollapsed):
+		return StatusCollapsed, nil
+	case errors.Is(err, ratelimit.ErrRateLimited):
+		return "", &Error{Code: "rate_limited", Message: err.Error(), status: http.StatusTooManyRequests}
+	case errors.Is(err, transaction.ErrInvalidTransition), errors.Is(err, transaction.ErrFamilyMismatch):
+		return "", &Error{Code: "invalid_transition", Message: err.Error(), status: http.StatusConflict}
+	case errors.Is(err, transaction.ErrNotLifecycleType), errors.Is(err, rabbitmq.ErrInvalidUserID), errors.Is(err, entity.ErrUnknownType):
+		return "", &Error{Code: "invalid_request", Message: err.Error(), status: http.StatusBadRequest}
+	default:
+		return "", &Error{Code: "delivery_failed", Message: err.Error(), status: http.StatusBadGateway}
+	}
+}
+
+// validate checks the required fields and the notification type
+func validate(req NotificationRequest) *Error {
+	if req.UserID == "" {
+		return &Error{Code: "invalid_request", Message: "user_id is required", status: http.StatusBadRequest}
+	}
+	if err := rabbitmq.ValidateUserID(req.UserID); err != nil {
+		return &Error{Code: "invalid_request", Message: err.Error(), status: http.StatusBadRequest}
+	}
+	if !req.Type.IsRegistered() {
+		return &Error{Code: "invalid_request", Message: fmt.Sprintf("unknown notification type %q", req.Type), status: http.StatusBadRequest}
+	}
+	if req.TTL < 0 {
+		return &Error{Code: "invalid_request", Message: "ttl must not be negative", status: http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+// decode parses the JSON body, rejecting unknown fields
+func decode(body []byte, v any) *Error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &Error{Code: "invalid_request", Message: "invalid JSON body: " + err.Error(), status: http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err *Error) {
+	writeJSON(w, err.status, map[string]*Error{"error": err})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logutils.Error("Failed to write the response", err, nil)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	mu         sync.Mutex
+	sent       []NotificationRequest
+	deleted    []string
+	err        error
+	aggregated entity.NotifyTypeMessage
+}
+
+func (f *fakeNotifier) NotifyUserIdWithExpiry(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, NotificationRequest{UserID: userID, Type: typeMessage, TTL: catalog.Duration(expiry)})
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyTransactionWithExpiry(ctx context.Context, userID, correlationID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, NotificationRequest{UserID: userID, Type: typeMessage, CorrelationID: correlationID, TTL: catalog.Duration(expiry)})
+	return f.err
+}
+
+func (f *fakeNotifier) DeleteNotificationsUserId(ctx context.Context, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, userID)
+	return f.err
+}
+
+func (f *fakeNotifier) Aggregates(typeMessage entity.NotifyTypeMessage) bool {
+	return typeMessage == f.aggregated
+}
+
+// failingReader fails every read
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset by peer") }
+
+func do(server http.Handler, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	keys := ParseAPIKeys("payments:secret-key, broken")
+
+	t.Run("Test POST single notification", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		server := NewServer(notifier, keys, nil)
+		// Act
+		rec := do(server, http.MethodPost, "/v1/notifications", "secret-key", `{"user_id":"1","type":"deposit_success"}`)
+		// Assert
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, []NotificationRequest{{UserID: "1", Type: entity.DEPOSIT_SUCCESS}}, notifier.sent)
+	})
+
+	t.Run("Test POST with a ttl", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		server := NewServer(notifier, keys, nil)
+		// Act
+		rec := do(server, http.MethodPost, "/v1/notifications", "secret-key",
+			`[{"user_id":"1","type":"new_post","ttl":"10m"},{"user_id":"2","type":"transfer_process","correlation_id":"tx-1","ttl":"90s"}]`)
+		// Assert
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, catalog.Duration(10*time.Minute), notifier.sent[0].TTL)
+		assert.Equal(t, catalog.Duration(90*time.Second), notifier.sent[1].TTL)
+	})
+
+	t.Run("Test POST batch", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		server := NewServer(notifier, keys, nil)
+		// Act
+		rec := do(server, http.MethodPost, "/v1/notifications", "secret-key",
+			`[{"user_id":"1","type":"new_post"},{"user_id":"2","type":"transfer_process","correlation_id":"tx-1"}]`)
+		var body map[string][]Result
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		// Assert
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, []Result{{Index: 0, Status: "sent"}, {Index: 1, Status: "sent"}}, body["results"])
+		assert.Equal(t, "tx-1", notifier.sent[1].CorrelationID)
+	})
+
+	t.Run("Test POST validation errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+			code string
+		}{
+			{name: "Test missing user", body: `{"type":"deposit"}`, code: "invalid_request"},
+			{name: "Test unknown type", body: `{"user_id":"1","type":"unknown"}`, code: "invalid_request"},
+			{name: "Test user ID with routing wildcard", body: `{"user_id":"1.*","type":"deposit"}`, code: "invalid_request"},
+			{name: "Test unknown field", body: `{"user_id":"1","type":"deposit","extra":1}`, code: "invalid_request"},
+			{name: "Test negative ttl", body: `{"user_id":"1","type":"deposit","ttl":"-1m"}`, code: "invalid_request"},
+			{name: "Test invalid ttl", body: `{"user_id":"1","type":"deposit","ttl":"soon"}`, code: "invalid_request"},
+			{name: "Test empty batch", body: `[]`, code: "invalid_request"},
+			{name: "Test invalid batch entry", body: `[{"user_id":"1","type":"deposit"},{"user_id":"","type":"deposit"}]`, code: "invalid_request"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				notifier := &fakeNotifier{}
+				rec := do(NewServer(notifier, keys, nil), http.MethodPost, "/v1/notifications", "secret-key", tt.body)
+				var body map[string]Error
+				_ = json.Unmarshal(rec.Body.Bytes(), &body)
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Equal(t, tt.code, body["error"].Code)
+				assert.Empty(t, notifier.sent)
+			})
+		}
+	})
+
+	t.Run("Test POST rate limited", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{err: ratelimit.ErrRateLimited}, keys, nil)
+		// Act
+		rec := do(server, http.MethodPost, "/v1/notifications", "secret-key", `{"user_id":"1","type":"deposit"}`)
+		// Assert
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"rate_limited"`)
+	})
+
+	t.Run("Test POST collapsed", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{err: ratelimit.ErrCollapsed}, keys, nil)
+		// Act
+		single := do(server, http.MethodPost, "/v1/notifications", "secret-key", `{"user_id":"1","type":"deposit"}`)
+		batch := do(server, http.MethodPost, "/v1/notifications", "secret-key", `[{"user_id":"1","type":"deposit"}]`)
+		var result Result
+		_ = json.Unmarshal(single.Body.Bytes(), &result)
+		var body map[string][]Result
+		_ = json.Unmarshal(batch.Body.Bytes(), &body)
+		// Assert, the pending notification carries the collapsed one so it is accepted
+		assert.Equal(t, http.StatusAccepted, single.Code)
+		assert.Equal(t, StatusCollapsed, result.Status)
+		assert.Equal(t, []Result{{Index: 0, Status: StatusCollapsed}}, body["results"])
+	})
+
+	t.Run("Test POST aggregated", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{aggregated: entity.NEW_POST}, keys, nil)
+		// Act
+		rec := do(server, http.MethodPost, "/v1/notifications", "secret-key",
+			`[{"user_id":"1","type":"new_post"},{"user_id":"1","type":"deposit"}]`)
+		var body map[string][]Result
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		// Assert
+		assert.Equal(t, []Result{{Index: 0, Status: StatusAggregated}, {Index: 1, Status: StatusSent}}, body["results"])
+	})
+
+	t.Run("Test POST body errors", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{}, keys, nil)
+		unreadable := httptest.NewRequest(http.MethodPost, "/v1/notifications", failingReader{})
+		unreadable.Header.Set("X-API-Key", "secret-key")
+		unreadableRec := httptest.NewRecorder()
+		// Act
+		server.ServeHTTP(unreadableRec, unreadable)
+		tooLarge := do(server, http.MethodPost, "/v1/notifications", "secret-key", strings.Repeat(" ", maxBodyBytes+1))
+		// Assert, only a body over the limit is reported as too large
+		assert.Equal(t, http.StatusBadRequest, unreadableRec.Code)
+		assert.NotContains(t, unreadableRec.Body.String(), "too large")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, tooLarge.Code)
+	})
+
+	t.Run("Test API key is required", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{}, keys, nil)
+		// Act
+		missing := do(server, http.MethodPost, "/v1/notifications", "", `{"user_id":"1","type":"deposit"}`)
+		invalid := do(server, http.MethodDelete, "/v1/users/1/queue", "wrong", "")
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, missing.Code)
+		assert.Equal(t, http.StatusUnauthorized, invalid.Code)
+	})
+
+	t.Run("Test DELETE user queue", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		server := NewServer(notifier, keys, nil)
+		// Act
+		rec := do(server, http.MethodDelete, "/v1/users/42/queue", "secret-key", "")
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{"42"}, notifier.deleted)
+	})
+
+	t.Run("Test health endpoints", func(t *testing.T) {
+		// Arrange
+		server := NewServer(&fakeNotifier{}, keys, func(ctx context.Context) error { return errors.New("rabbitmq connection is closed") })
+		// Act
+		live := do(server, http.MethodGet, "/healthz", "", "")
+		ready := do(server, http.MethodGet, "/readyz", "", "")
+		// Assert
+		assert.Equal(t, http.StatusOK, live.Code)
+		assert.Equal(t, http.StatusServiceUnavailable, ready.Code)
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// APIKeys maps each API key to the name of the service using it
+type APIKeys map[string]string
+
+// ParseAPIKeys parses keys in the form service1:key1,service2:key2
+func ParseAPIKeys(value string) APIKeys {
+	keys := make(APIKeys)
+	for _, entry := range strings.Split(value, ",") {
+		service, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || key == "" {
+			continue
+		}
+		keys[key] = service
+	}
+
+	return keys
+}
+
+// service returns the service owning the key sent in X-API-Key or as a bearer token
+func (k APIKeys) service(r *http.Request) (string, bool) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	return k.Service(key)
+}
+
+// Service returns the service owning the key
+func (k APIKeys) Service(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	// Compare every key so the time taken does not reveal a match
+	found, match := "", 0
+	for candidate, service := range k {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			found, match = service, 1
+		}
+	}
+
+	return found, match == 1
+}
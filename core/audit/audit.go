@@ -0,0 +1,261 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// Outcome of the audited operation
+type Outcome string
+
+const (
+	OutcomePublished Outcome = "published"
+	OutcomeFailed    Outcome = "failed"
+	OutcomeRecalled  Outcome = "recalled"
+)
+
+var (
+	// ErrTampered is wrapped by the errors of Verify
+	ErrTampered = errors.New("audit log tampered")
+	// ErrMissingKey is returned when the log is opened or verified without a key
+	ErrMissingKey = errors.New("audit log key is required")
+)
+
+// Record is what the caller audits, the log completes it into an Entry
+type Record struct {
+	UserID    string                   `json:"user_id"`
+	Type      entity.NotifyTypeMessage `json:"type"`
+	MessageID string                   `json:"message_id"`
+	// PayloadHash is the hex SHA-256 of the published body, see HashPayload
+	PayloadHash string  `json:"payload_hash"`
+	Outcome     Outcome `json:"outcome"`
+	Error       string  `json:"error,omitempty"`
+	// Reason is given by the caller of a recall
+	Reason string `json:"reason,omitempty"`
+}
+
+// Entry struct
+type Entry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Record
+	// PrevHash is the Hash of the previous entry, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex HMAC-SHA256 of the entry, it cannot be recomputed without the key of the log
+	Hash string `json:"hash"`
+}
+
+// HashPayload returns the hex SHA-256 of the payload
+func HashPayload(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// computeHash signs every field of the entry but Hash with the key, chaining it to PrevHash
+func (e Entry) computeHash(key []byte) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyError struct
+type VerifyError struct {
+	// Line is the 1-based line of the first invalid entry
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s", ErrTampered, e.Line, e.Reason)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return ErrTampered
+}
+
+// Head is the sequence and hash of the last entry of a log. It is kept next to the log so removing
+// the last entries is detected, copying it elsewhere also detects a rollback of both files.
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// HeadPath returns the path of the head kept next to the log at path
+func HeadPath(path string) string {
+	return path + ".head"
+}
+
+// ReadHead reads the head kept next to the log at path, a log without one has a zero Head
+func ReadHead(path string) (Head, error) {
+	data, err := os.ReadFile(HeadPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return Head{}, nil
+	}
+	if err != nil {
+		return Head{}, err
+	}
+
+	var head Head
+	if err := json.Unmarshal(data, &head); err != nil {
+		return Head{}, fmt.Errorf("%w: invalid head: %w", ErrTampered, err)
+	}
+
+	return head, nil
+}
+
+// writeHead replaces the head kept next to the log at path
+func writeHead(path string, head Head) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	// The head is replaced by a rename so a crash leaves either the old or the new one
+	tmp := HeadPath(path) + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, HeadPath(path))
+}
+
+// chain checks the entries one line at a time
+type chain struct {
+	key []byte
+	// head is the last entry the log is known to reach, zero when unknown
+	head  Head
+	prev  Entry
+	count int
+}
+
+// add checks the line against the previous entry and makes it the last one
+func (c *chain) add(data []byte) error {
+	line := c.count + 1
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return &VerifyError{Line: line, Reason: "invalid JSON: " + err.Error()}
+	}
+	if entry.Seq != uint64(line) {
+		return &VerifyError{Line: line, Reason: fmt.Sprintf("sequence %d, expected %d", entry.Seq, line)}
+	}
+	if entry.PrevHash != c.prev.Hash {
+		return &VerifyError{Line: line, Reason: "previous hash does not match"}
+	}
+	if !hmac.Equal([]byte(entry.Hash), []byte(entry.computeHash(c.key))) {
+		return &VerifyError{Line: line, Reason: "hash does not match the entry"}
+	}
+	if entry.Seq == c.head.Seq && entry.Hash != c.head.Hash {
+		return &VerifyError{Line: line, Reason: "hash does not match the head"}
+	}
+
+	c.prev = entry
+	c.count++
+
+	return nil
+}
+
+// end checks the log reaches its head once every entry is added
+func (c *chain) end() error {
+	if uint64(c.count) < c.head.Seq {
+		return &VerifyError{Line: c.count + 1, Reason: fmt.Sprintf("log ends before its head entry %d", c.head.Seq)}
+	}
+
+	return nil
+}
+
+// Verify checks the hash chain of a JSON Lines log signed with key and returns the number of valid entries
+func Verify(r io.Reader, key []byte) (int, error) {
+	return VerifyWithHead(r, key, Head{})
+}
+
+// VerifyWithHead checks the hash chain like Verify and that the log reaches the head, see ReadHead
+func VerifyWithHead(r io.Reader, key []byte, head Head) (int, error) {
+	if len(key) == 0 {
+		return 0, ErrMissingKey
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	c := &chain{key: key, head: head}
+	for scanner.Scan() {
+		if err := c.add(scanner.Bytes()); err != nil {
+			return c.count, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c.count, err
+	}
+
+	return c.count, c.end()
+}
+
+// Filter selects the entries of an export, zero fields match every entry
+type Filter struct {
+	UserID string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Match reports whether the entry is selected by the filter
+func (f Filter) Match(e Entry) bool {
+	if f.UserID != "" && e.UserID != f.UserID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Export copies the entries of a JSON Lines log matching the filter to w, one JSON object per line
+func Export(r io.Reader, w io.Writer, filter Filter) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, err
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, scanner.Err()
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey = []byte("audit-key")
+
+func writeLog(t *testing.T, path string, records ...Record) {
+	l, err := OpenFileLog(path, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, record := range records {
+		if _, err := l.Append(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	t.Run("Test Append chains the entries across reopening", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		writeLog(t, path, Record{UserID: "1", Type: "withdraw_success", MessageID: "a", PayloadHash: HashPayload([]byte("token")), Outcome: OutcomePublished})
+		l, err := OpenFileLog(path, testKey)
+		assert.Nil(t, err)
+		// Act
+		entry, appendErr := l.Append(context.Background(), Record{UserID: "2", Type: "transfer_error", MessageID: "b", Outcome: OutcomeFailed, Error: "broker down"})
+		_ = l.Close()
+		file, _ := os.Open(path)
+		defer file.Close()
+		count, verifyErr := Verify(file, testKey)
+		// Assert
+		assert.Nil(t, appendErr)
+		assert.Equal(t, uint64(2), entry.Seq)
+		assert.NotEmpty(t, entry.PrevHash)
+		assert.Nil(t, verifyErr)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("Test OpenFileLog truncates a partial last line", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		writeLog(t, path, Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished})
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+		_, _ = file.WriteString(`{"seq":2,"time":"2026-`)
+		_ = file.Close()
+		// Act
+		l, err := OpenFileLog(path, testKey)
+		assert.Nil(t, err)
+		entry, appendErr := l.Append(context.Background(), Record{UserID: "1", Type: "deposit", MessageID: "b", Outcome: OutcomePublished})
+		_ = l.Close()
+		data, _ := os.ReadFile(path)
+		count, verifyErr := Verify(bytes.NewReader(data), testKey)
+		// Assert
+		assert.Nil(t, appendErr)
+		assert.Equal(t, uint64(2), entry.Seq)
+		assert.Nil(t, verifyErr)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("Test Append truncates a failed write", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		writeLog(t, path, Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished})
+		l, err := OpenFileLog(path, testKey)
+		assert.Nil(t, err)
+		write := l.write
+		l.write = func(data []byte) (int, error) {
+			n, _ := write(data[:len(data)/2])
+			return n, errors.New("no space left on device")
+		}
+		// Act
+		_, failedErr := l.Append(context.Background(), Record{UserID: "1", Type: "deposit", MessageID: "b", Outcome: OutcomePublished})
+		l.write = write
+		entry, appendErr := l.Append(context.Background(), Record{UserID: "1", Type: "deposit", MessageID: "c", Outcome: OutcomePublished})
+		_ = l.Close()
+		data, _ := os.ReadFile(path)
+		count, verifyErr := Verify(bytes.NewReader(data), testKey)
+		// Assert, the next entry follows the last written one
+		assert.NotNil(t, failedErr)
+		assert.Nil(t, appendErr)
+		assert.Equal(t, uint64(2), entry.Seq)
+		assert.Nil(t, verifyErr)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("Test OpenFileLog detects removed last entries", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		writeLog(t, path,
+			Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished},
+			Record{UserID: "1", Type: "deposit", MessageID: "b", Outcome: OutcomePublished},
+		)
+		data, _ := os.ReadFile(path)
+		first := strings.SplitAfter(string(data), "\n")[0]
+		_ = os.WriteFile(path, []byte(first), 0o600)
+		head, headErr := ReadHead(path)
+		// Act
+		_, err := OpenFileLog(path, testKey)
+		count, verifyErr := VerifyWithHead(strings.NewReader(first), testKey, head)
+		// Assert
+		assert.Nil(t, headErr)
+		assert.Equal(t, uint64(2), head.Seq)
+		assert.ErrorIs(t, err, ErrTampered)
+		assert.ErrorIs(t, verifyErr, ErrTampered)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Test OpenFileLog rejects a tampered chain", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		writeLog(t, path, Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished})
+		data, _ := os.ReadFile(path)
+		_ = os.WriteFile(path, bytes.Replace(data, []byte(`"user_id":"1"`), []byte(`"user_id":"2"`), 1), 0o600)
+		// Act
+		_, err := OpenFileLog(path, testKey)
+		_, missingKeyErr := OpenFileLog(path, nil)
+		// Assert
+		assert.ErrorIs(t, err, ErrTampered)
+		assert.ErrorIs(t, missingKeyErr, ErrMissingKey)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeLog(t, path,
+		Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished},
+		Record{UserID: "1", Type: "transfer_success", MessageID: "b", Outcome: OutcomePublished},
+		Record{UserID: "2", Type: "deposit", MessageID: "c", Outcome: OutcomePublished},
+	)
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+
+	tests := []struct {
+		name  string
+		log   string
+		key   []byte
+		count int
+		line  int
+	}{
+		{name: "Test untouched log", log: string(data), key: testKey, count: 3},
+		{name: "Test modified entry", log: strings.Replace(string(data), `"user_id":"2"`, `"user_id":"3"`, 1), key: testKey, count: 2, line: 3},
+		{name: "Test removed entry", log: lines[0] + lines[2], key: testKey, count: 1, line: 2},
+		{name: "Test truncated head", log: lines[1] + lines[2], key: testKey, count: 0, line: 1},
+		{name: "Test log signed with another key", log: string(data), key: []byte("forger-key"), count: 0, line: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			count, err := Verify(strings.NewReader(tt.log), tt.key)
+			// Assert
+			assert.Equal(t, tt.count, count)
+			if tt.line == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			var verifyErr *VerifyError
+			assert.ErrorAs(t, err, &verifyErr)
+			assert.ErrorIs(t, err, ErrTampered)
+			assert.Equal(t, tt.line, verifyErr.Line)
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeLog(t, path,
+		Record{UserID: "1", Type: "withdraw_success", MessageID: "a", Outcome: OutcomePublished},
+		Record{UserID: "2", Type: "deposit", MessageID: "b", Outcome: OutcomePublished},
+		Record{UserID: "1", Type: "transfer_success", MessageID: "c", Outcome: OutcomePublished},
+	)
+	file, _ := os.Open(path)
+	defer file.Close()
+	var out bytes.Buffer
+	// Act
+	count, err := Export(file, &out, Filter{UserID: "1", Since: time.Now().Add(-time.Minute)})
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), `"message_id":"c"`)
+	assert.NotContains(t, out.String(), `"message_id":"b"`)
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+)
+
+// Log appends the audited records
+type Log interface {
+	Append(ctx context.Context, record Record) (Entry, error)
+	Close() error
+}
+
+// FileLog is a Log kept as a JSON Lines file, each entry is synced before Append returns.
+// Its Head is kept next to it, see HeadPath.
+type FileLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// size is the offset after the last entry, a failed append is truncated back to it
+	size  int64
+	key   []byte
+	last  Entry
+	now   func() time.Time
+	write func(data []byte) (int, error)
+	// err fails the appends once a failed one could not be truncated
+	err error
+}
+
+// OpenFileLog opens or creates the log at path, entries are signed with key. The existing chain is verified
+// against its head before continuing it and a partial last line, left by a crash while appending, is truncated.
+func OpenFileLog(path string, key []byte) (*FileLog, error) {
+	if len(key) == 0 {
+		return nil, ErrMissingKey
+	}
+
+	head, err := ReadHead(path)
+	if err != nil {
+		logutils.Error("Failed to read the audit log head", err, logutils.Fields{"path": path})
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		logutils.Error("Failed to open the audit log", err, logutils.Fields{"path": path})
+		return nil, err
+	}
+
+	c := &chain{key: key, head: head}
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				logutils.Warn("Truncating the partial last line of the audit log", logutils.Fields{"path": path, "line": c.count + 1})
+				if err := file.Truncate(offset); err != nil {
+					_ = file.Close()
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if err := c.add(bytes.TrimSuffix(line, []byte("\n"))); err != nil {
+			logutils.Error("The audit log chain is invalid", err, logutils.Fields{"path": path})
+			_ = file.Close()
+			return nil, err
+		}
+		offset += int64(len(line))
+	}
+	if err := c.end(); err != nil {
+		logutils.Error("The audit log is shorter than its head", err, logutils.Fields{"path": path})
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileLog{path: path, file: file, size: offset, key: key, last: c.prev, now: time.Now, write: file.Write}, nil
+}
+
+// Append chains the record to the last entry and writes it. A failed write is truncated so the next entry
+// follows the last one, an error writing the head is returned with the written entry.
+func (l *FileLog) Append(ctx context.Context, record Record) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return Entry{}, l.err
+	}
+
+	entry := Entry{
+		Seq:      l.last.Seq + 1,
+		Time:     l.now().UTC(),
+		Record:   record,
+		PrevHash: l.last.Hash,
+	}
+	entry.Hash = entry.computeHash(l.key)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	line = append(line, '\n')
+	if _, err := l.write(line); err != nil {
+		logutils.Error("Failed to write the audit log", err, nil)
+		return Entry{}, l.truncate(err)
+	}
+	if err := l.file.Sync(); err != nil {
+		logutils.Error("Failed to sync the audit log", err, nil)
+		return Entry{}, l.truncate(err)
+	}
+
+	l.size += int64(len(line))
+	l.last = entry
+
+	if err := writeHead(l.path, Head{Seq: entry.Seq, Hash: entry.Hash}); err != nil {
+		logutils.Error("Failed to write the audit log head", err, logutils.Fields{"seq": entry.Seq})
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// Head returns the sequence and hash of the last entry
+func (l *FileLog) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Head{Seq: l.last.Seq, Hash: l.last.Hash}
+}
+
+// truncate removes what a failed append left after the last entry, the log fails every append when it cannot
+func (l *FileLog) truncate(cause error) error {
+	if err := l.file.Truncate(l.size); err != nil {
+		logutils.Error("Failed to truncate the audit log", err, nil)
+		l.err = fmt.Errorf("audit log has a partial entry: %w", errors.Join(cause, err))
+		return l.err
+	}
+
+	return cause
+}
+
+// Close closes the log file
+func (l *FileLog) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,306 @@
+package catalog
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLocale is the locale of the built-in texts and of catalogs without default_locale
+const DefaultLocale = "en"
+
+// ErrInvalidCatalog is wrapped by the errors of Parse and Load
+var ErrInvalidCatalog = errors.New("invalid catalog")
+
+//go:embed schema.json
+var schemaJSON []byte
+
+// schema is the JSON Schema every catalog is validated against
+var schema = jsonschema.MustCompileString("schema.json", string(schemaJSON))
+
+// Format of a catalog file
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// FormatOf returns the format of the file from its extension, YAML unless it is .json
+func FormatOf(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+
+	return FormatYAML
+}
+
+// Text of a notification in one locale
+type Text struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// Duration is a time.Duration written as "90s" or "1h30m"
+type Duration time.Duration
+
+// UnmarshalJSON parses the duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+
+	return nil
+}
+
+// MarshalJSON writes the duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Entry overrides the fields of a type, empty fields keep the built-in or registered ones
+type Entry struct {
+	Category entity.Category `json:"category,omitempty"`
+	Priority entity.Priority `json:"priority,omitempty"`
+	// Channels restricts the channels delivering the type, every channel when empty
+	Channels []string        `json:"channels,omitempty"`
+	TTL      Duration        `json:"ttl,omitempty"`
+	Texts    map[string]Text `json:"texts,omitempty"`
+}
+
+// Catalog of notification types
+type Catalog struct {
+	Version       int                                `json:"version"`
+	DefaultLocale string                             `json:"default_locale,omitempty"`
+	Types         map[entity.NotifyTypeMessage]Entry `json:"types"`
+
+	// registry is the one the catalog is registered in, entity.DefaultRegistry until then
+	registry *entity.Registry
+}
+
+// Definition is a type of the catalog merged with its built-in or registered definition
+type Definition struct {
+	entity.TypeDefinition
+	Channels []string
+	Texts    map[string]Text
+}
+
+// Load reads and validates the catalog file, its format is given by the extension
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, FormatOf(path))
+}
+
+// Parse validates the catalog against the schema, then checks its types can be merged
+// with the registered ones
+func Parse(data []byte, format Format) (*Catalog, error) {
+	// YAML is converted to JSON so both formats go through the same schema
+	if format == FormatYAML {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, err)
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, err)
+		}
+		data = converted
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, err)
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, err)
+	}
+	if c.DefaultLocale == "" {
+		c.DefaultLocale = DefaultLocale
+	}
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// check rejects new types the registry cannot accept
+func (c *Catalog) check() error {
+	for t := range c.Types {
+		if t.IsRegistered() {
+			continue
+		}
+
+		def, _ := c.Lookup(t)
+		if err := def.TypeDefinition.Validate(); err != nil {
+			return fmt.Errorf("%w: new type needs a category and a %s message: %w", ErrInvalidCatalog, c.DefaultLocale, err)
+		}
+	}
+
+	return nil
+}
+
+// Lookup merges the catalog entry of the type with its definition in the registry the catalog
+// is registered in, false when the type is in neither
+func (c *Catalog) Lookup(t entity.NotifyTypeMessage) (Definition, bool) {
+	registry := c.registry
+	if registry == nil {
+		registry = entity.DefaultRegistry
+	}
+	registered, isRegistered := registry.Lookup(t)
+
+	return c.merge(t, registered, isRegistered)
+}
+
+// merge overrides the definition with the catalog entry of the type, false when the type is in neither
+func (c *Catalog) merge(t entity.NotifyTypeMessage, registered entity.TypeDefinition, isRegistered bool) (Definition, bool) {
+	entry, inCatalog := c.Types[t]
+	if !isRegistered && !inCatalog {
+		return Definition{}, false
+	}
+
+	def := Definition{TypeDefinition: registered}
+	def.Name = t
+	if !isRegistered {
+		def.Priority = entity.PriorityNormal
+	}
+	if entry.Category != "" {
+		def.Category = entry.Category
+	}
+	if entry.Priority != "" {
+		def.Priority = entry.Priority
+	}
+	if entry.TTL > 0 {
+		def.TTL = time.Duration(entry.TTL)
+	}
+	def.Channels = entry.Channels
+	def.Texts = entry.Texts
+	if text, ok := entry.Texts[c.DefaultLocale]; ok {
+		def.Message = text.Message
+		if text.Title != "" {
+			def.Title = text.Title
+		}
+	}
+
+	return def, true
+}
+
+// Text returns the text of the type in the locale, falling back to its language, the default locale
+// and the built-in text
+func (c *Catalog) Text(t entity.NotifyTypeMessage, locale string) Text {
+	def, ok := c.Lookup(t)
+	if !ok {
+		return Text{Title: t.GetTitle(), Message: t.GetNotifyTypeMessage()}
+	}
+
+	candidates := []string{locale}
+	if language, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, language)
+	}
+	for _, candidate := range candidates {
+		if text, ok := def.Texts[candidate]; ok {
+			if text.Title == "" {
+				text.Title = def.GetTitle()
+			}
+			return text
+		}
+	}
+
+	return Text{Title: def.GetTitle(), Message: def.Message}
+}
+
+// Register adds the new types of the catalog to the registry and overrides the registered ones
+func (c *Catalog) Register(r *entity.Registry) error {
+	_, err := c.apply(r, registration{})
+	return err
+}
+
+// registration remembers what a catalog changed in a registry, so the next catalog can undo it
+type registration struct {
+	// base holds the definitions the registry had before the catalog overrode them
+	base map[entity.NotifyTypeMessage]entity.TypeDefinition
+	// added holds the types the catalog added
+	added map[entity.NotifyTypeMessage]bool
+}
+
+// apply registers the catalog in place of the previous one: its types are added or overridden,
+// the overrides it dropped are reverted and the types it dropped are removed
+func (c *Catalog) apply(r *entity.Registry, prev registration) (registration, error) {
+	next := registration{
+		base:  make(map[entity.NotifyTypeMessage]entity.TypeDefinition),
+		added: make(map[entity.NotifyTypeMessage]bool),
+	}
+
+	var defs []entity.TypeDefinition
+	for t := range c.Types {
+		base, ok := prev.base[t]
+		if !ok && !prev.added[t] {
+			base, ok = r.Lookup(t)
+		}
+		if ok {
+			next.base[t] = base
+		} else {
+			next.added[t] = true
+		}
+
+		def, _ := c.merge(t, base, ok)
+		defs = append(defs, def.TypeDefinition)
+	}
+
+	var removed []entity.NotifyTypeMessage
+	for t, base := range prev.base {
+		if _, ok := c.Types[t]; !ok {
+			defs = append(defs, base)
+		}
+	}
+	for t := range prev.added {
+		if _, ok := c.Types[t]; !ok {
+			removed = append(removed, t)
+		}
+	}
+
+	if err := r.Replace(defs, removed...); err != nil {
+		return prev, err
+	}
+	c.registry = r
+
+	return next, nil
+}
+
+// Catalog returns c, so a static catalog is a Provider
+func (c *Catalog) Catalog() *Catalog {
+	return c
+}
+
+// Provider returns the current catalog, see Watcher
+type Provider interface {
+	Catalog() *Catalog
+}
@@ -0,0 +1,189 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCatalog = `
+version: 1
+default_locale: en
+types:
+  deposit_process:
+    priority: normal
+    ttl: 30m
+    channels: [email]
+    texts:
+      en:
+        message: Your deposit is on its way
+      pt:
+        title: Depósito
+        message: Seu depósito está a caminho
+  loyalty_points:
+    category: loyalty
+    texts:
+      en:
+        title: Points earned
+        message: You earned loyalty points
+`
+
+func TestParse(t *testing.T) {
+	t.Run("Test YAML is merged with the built-in definitions", func(t *testing.T) {
+		// Act
+		c, err := Parse([]byte(testCatalog), FormatYAML)
+		def, ok := c.Lookup(entity.DEPOSIT_PROCESS)
+		builtin, builtinOk := c.Lookup(entity.DEPOSIT_SUCCESS)
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, entity.CategoryDeposit, def.Category)
+		assert.Equal(t, entity.PriorityNormal, def.Priority)
+		assert.Equal(t, 30*time.Minute, def.GetTTL())
+		assert.Equal(t, []string{"email"}, def.Channels)
+		assert.Equal(t, "Your deposit is on its way", def.Message)
+		assert.True(t, builtinOk)
+		assert.Equal(t, "Deposit completed", builtin.Message)
+	})
+
+	t.Run("Test JSON catalog", func(t *testing.T) {
+		// Act
+		c, err := Parse([]byte(`{"version": 1, "types": {"new_post": {"ttl": "48h"}}}`), FormatJSON)
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultLocale, c.DefaultLocale)
+		assert.Equal(t, 48*time.Hour, time.Duration(c.Types[entity.NEW_POST].TTL))
+	})
+
+	t.Run("Test Text falls back to the language, the default locale and the built-in text", func(t *testing.T) {
+		// Arrange
+		c, _ := Parse([]byte(testCatalog), FormatYAML)
+		// Act
+		regional := c.Text(entity.DEPOSIT_PROCESS, "pt-BR")
+		missing := c.Text(entity.DEPOSIT_PROCESS, "fr")
+		builtin := c.Text(entity.DEPOSIT_CANCEL, "pt")
+		// Assert
+		assert.Equal(t, Text{Title: "Depósito", Message: "Seu depósito está a caminho"}, regional)
+		assert.Equal(t, Text{Title: "deposit_process", Message: "Your deposit is on its way"}, missing)
+		assert.Equal(t, Text{Title: "deposit_cancel", Message: "Deposit canceled"}, builtin)
+	})
+
+	t.Run("Test invalid catalogs are rejected", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			catalog string
+		}{
+			{name: "missing version", catalog: `types: {}`},
+			{name: "unknown field", catalog: "version: 1\ntypes:\n  new_post:\n    color: red"},
+			{name: "unknown priority", catalog: "version: 1\ntypes:\n  new_post:\n    priority: urgent"},
+			{name: "invalid TTL", catalog: "version: 1\ntypes:\n  new_post:\n    ttl: 2 days"},
+			{name: "invalid locale", catalog: "version: 1\ntypes:\n  new_post:\n    texts:\n      English:\n        message: New post"},
+			{name: "routing key wildcard", catalog: "version: 1\ntypes:\n  new.post: {}"},
+			{name: "new type without category", catalog: "version: 1\ntypes:\n  loyalty_points:\n    texts:\n      en:\n        message: Points"},
+			{name: "invalid YAML", catalog: "version: [1"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := Parse([]byte(tt.catalog), FormatYAML)
+				assert.ErrorIs(t, err, ErrInvalidCatalog)
+			})
+		}
+	})
+
+	t.Run("Test Register adds the new types and overrides the registered ones", func(t *testing.T) {
+		// Arrange
+		c, _ := Parse([]byte(testCatalog), FormatYAML)
+		r := entity.NewRegistry()
+		_ = r.Register(entity.TypeDefinition{Name: entity.DEPOSIT_PROCESS, Category: entity.CategoryDeposit, Message: "Processing the deposit", Priority: entity.PriorityLow})
+		// Act
+		err := c.Register(r)
+		loyalty, ok := r.Lookup("loyalty_points")
+		process, _ := r.Lookup(entity.DEPOSIT_PROCESS)
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, entity.Category("loyalty"), loyalty.Category)
+		assert.Equal(t, "Points earned", loyalty.GetTitle())
+		assert.Equal(t, "Your deposit is on its way", process.Message)
+		assert.Equal(t, entity.PriorityNormal, process.Priority)
+		assert.Equal(t, 30*time.Minute, process.TTL)
+		assert.Equal(t, "Your deposit is on its way", c.Text(entity.DEPOSIT_PROCESS, "en").Message)
+	})
+}
+
+func TestWatcher(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	write := func(content string) {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("version: 1\ntypes:\n  new_post:\n    texts:\n      en:\n        message: A new post")
+	w, err := NewWatcher(path, nil)
+	assert.Nil(t, err)
+
+	t.Run("Test Reload swaps the catalog when the file changes", func(t *testing.T) {
+		// Arrange
+		write("version: 1\ntypes:\n  new_post:\n    texts:\n      en:\n        message: Fresh post")
+		// Act
+		reloaded, err := w.Reload()
+		unchanged, _ := w.Reload()
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, reloaded)
+		assert.False(t, unchanged)
+		assert.Equal(t, "Fresh post", w.Catalog().Text(entity.NEW_POST, "en").Message)
+	})
+
+	t.Run("Test Reload keeps the previous catalog when the file is invalid", func(t *testing.T) {
+		// Arrange
+		write("version: 2\ntypes: {}")
+		// Act
+		reloaded, err := w.Reload()
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCatalog)
+		assert.False(t, reloaded)
+		assert.Equal(t, "Fresh post", w.Catalog().Text(entity.NEW_POST, "en").Message)
+	})
+
+	t.Run("Test NewWatcher rejects an invalid file", func(t *testing.T) {
+		// Act
+		_, err := NewWatcher(path, nil)
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidCatalog)
+	})
+}
+
+func TestWatcherRegistry(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	write := func(content string) {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	r := entity.NewRegistry()
+	_ = r.Register(entity.TypeDefinition{Name: entity.DEPOSIT_PROCESS, Category: entity.CategoryDeposit, Message: "Processing the deposit", Priority: entity.PriorityLow})
+	write(testCatalog)
+	w, err := NewWatcher(path, r)
+	assert.Nil(t, err)
+	overridden, _ := r.Lookup(entity.DEPOSIT_PROCESS)
+	// Act
+	write("version: 1\ntypes:\n  deposit_process:\n    category: payments")
+	_, reloadErr := w.Reload()
+	recategorized, _ := r.Lookup(entity.DEPOSIT_PROCESS)
+	_, loyaltyOk := r.Lookup("loyalty_points")
+	write("version: 1\ntypes: {}")
+	_, emptyErr := w.Reload()
+	reverted, _ := r.Lookup(entity.DEPOSIT_PROCESS)
+	// Assert
+	assert.Equal(t, entity.PriorityNormal, overridden.Priority)
+	assert.Nil(t, reloadErr)
+	assert.Equal(t, entity.Category("payments"), recategorized.Category)
+	assert.Equal(t, entity.PriorityLow, recategorized.Priority)
+	assert.Equal(t, "Processing the deposit", recategorized.Message)
+	assert.False(t, loyaltyOk)
+	assert.Nil(t, emptyErr)
+	assert.Equal(t, entity.TypeDefinition{Name: entity.DEPOSIT_PROCESS, Category: entity.CategoryDeposit, Message: "Processing the deposit", Priority: entity.PriorityLow}, reverted)
+}
@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// DefaultInterval is how often Run checks the catalog file when given no interval
+const DefaultInterval = 10 * time.Second
+
+// Watcher reloads the catalog file when its content changes, the registry follows every reload.
+// An invalid file is logged and the previous catalog is kept, so a bad edit never takes texts down.
+type Watcher struct {
+	path     string
+	registry *entity.Registry
+	current  atomic.Pointer[Catalog]
+
+	mu           sync.Mutex
+	data         []byte
+	registration registration
+}
+
+// NewWatcher loads the catalog file and registers its types in the registry, nil skips the registration
+func NewWatcher(path string, registry *entity.Registry) (*Watcher, error) {
+	w := &Watcher{path: path, registry: registry}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Catalog returns the last valid catalog
+func (w *Watcher) Catalog() *Catalog {
+	return w.current.Load()
+}
+
+// Reload reads the file and swaps the catalog when the content changed, it reports whether it did
+func (w *Watcher) Reload() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, err
+	}
+	if w.data != nil && bytes.Equal(data, w.data) {
+		return false, nil
+	}
+
+	c, err := Parse(data, FormatOf(w.path))
+	if err != nil {
+		return false, err
+	}
+	if w.registry != nil {
+		registration, err := c.apply(w.registry, w.registration)
+		if err != nil {
+			return false, err
+		}
+		w.registration = registration
+	}
+
+	w.data = data
+	w.current.Store(c)
+
+	return true, nil
+}
+
+// Run reloads the catalog every interval until the context is done.
+// Polling follows the symlink swaps of mounted config maps, which file events miss.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := w.Reload()
+			if err != nil {
+				logutils.Error("Failed to reload the catalog, keeping the previous one", err, logutils.Fields{"path": w.path})
+				continue
+			}
+			if reloaded {
+				logutils.Info("Catalog reloaded", logutils.Fields{"path": w.path, "types": len(w.Catalog().Types)})
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package channel
+
+import (
+	"context"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// Notification struct
+type Notification struct {
+	UserID      string                   `json:"user_id"`
+	Type        entity.NotifyTypeMessage `json:"type"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description"`
+	// Token is the signed JWT published to the user queue
+	Token string `json:"token"`
+	// Envelope is the encoded entity.NotifyType published to the user queue
+	Envelope []byte `json:"-"`
+}
+
+// Channel delivers notifications outside of the user queue
+type Channel interface {
+	Name() string
+	Accepts(typeMessage entity.NotifyTypeMessage) bool
+	Deliver(ctx context.Context, notification Notification) error
+}
+
+// Types is the set of notification types a channel delivers, an empty set accepts every type
+type Types []entity.NotifyTypeMessage
+
+// Contains reports whether the type is in the set
+func (t Types) Contains(typeMessage entity.NotifyTypeMessage) bool {
+	if len(t) == 0 {
+		return true
+	}
+
+	for _, tm := range t {
+		if tm == typeMessage {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/email"
+)
+
+// AddressBook looks up the email address of a user
+type AddressBook interface {
+	Address(ctx context.Context, userID string) (string, error)
+}
+
+// EmailTemplates struct
+type EmailTemplates struct {
+	Subject *texttemplate.Template
+	Text    *texttemplate.Template
+	HTML    *htmltemplate.Template
+}
+
+// DefaultEmailTemplates renders the title and description of the notification
+var DefaultEmailTemplates = EmailTemplates{
+	Subject: texttemplate.Must(texttemplate.New("subject").Parse(`{{.Description}}`)),
+	Text:    texttemplate.Must(texttemplate.New("text").Parse("{{.Description}}\n")),
+	HTML:    htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html><html><body><h1>{{.Description}}</h1></body></html>`)),
+}
+
+// EmailChannel struct
+type EmailChannel struct {
+	client    *email.Client
+	addresses AddressBook
+	types     Types
+	templates map[entity.NotifyTypeMessage]EmailTemplates
+}
+
+// NewEmailChannel creates a new EmailChannel instance for the types
+func NewEmailChannel(client *email.Client, addresses AddressBook, types Types) *EmailChannel {
+	return &EmailChannel{
+		client:    client,
+		addresses: addresses,
+		types:     types,
+		templates: make(map[entity.NotifyTypeMessage]EmailTemplates),
+	}
+}
+
+// SetTemplates overrides the templates of a notification type
+func (c *EmailChannel) SetTemplates(typeMessage entity.NotifyTypeMessage, templates EmailTemplates) {
+	c.templates[typeMessage] = templates
+}
+
+// Name returns the channel name
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Accepts reports whether the type is delivered by email
+func (c *EmailChannel) Accepts(typeMessage entity.NotifyTypeMessage) bool {
+	return c.types.Contains(typeMessage)
+}
+
+// Deliver renders and sends the notification to the user address
+func (c *EmailChannel) Deliver(ctx context.Context, notification Notification) error {
+	address, err := c.addresses.Address(ctx, notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := c.Render(notification)
+	if err != nil {
+		logutils.Error("Failed to render an email", err, logutils.Fields{"type": notification.Type.String()})
+		return err
+	}
+	msg.To = []string{address}
+
+	return c.client.Send(ctx, msg)
+}
+
+// Render renders the subject, text and HTML parts of the notification
+func (c *EmailChannel) Render(notification Notification) (email.Message, error) {
+	templates, ok := c.templates[notification.Type]
+	if !ok {
+		templates = DefaultEmailTemplates
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := templates.Subject.Execute(&subject, notification); err != nil {
+		return email.Message{}, err
+	}
+	if err := templates.Text.Execute(&text, notification); err != nil {
+		return email.Message{}, err
+	}
+	if err := templates.HTML.Execute(&html, notification); err != nil {
+		return email.Message{}, err
+	}
+
+	return email.Message{
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
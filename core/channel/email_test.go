@@ -0,0 +1,40 @@
+package channel
+
+import (
+	"testing"
+	texttemplate "text/template"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailChannel(t *testing.T) {
+	channel := NewEmailChannel(nil, nil, Types{entity.WITHDRAW_SUCCESS})
+
+	t.Run("Test Accepts", func(t *testing.T) {
+		assert.True(t, channel.Accepts(entity.WITHDRAW_SUCCESS))
+		assert.False(t, channel.Accepts(entity.NEW_POST))
+	})
+
+	t.Run("Test Render with the default templates", func(t *testing.T) {
+		// Act
+		msg, err := channel.Render(Notification{Type: entity.WITHDRAW_SUCCESS, Description: "Withdraw <completed>"})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Withdraw <completed>", msg.Subject)
+		assert.Equal(t, "Withdraw <completed>\n", msg.Text)
+		assert.Contains(t, msg.HTML, "<h1>Withdraw &lt;completed&gt;</h1>")
+	})
+
+	t.Run("Test Render with type templates", func(t *testing.T) {
+		// Arrange
+		templates := DefaultEmailTemplates
+		templates.Subject = texttemplate.Must(texttemplate.New("subject").Parse("Receipt: {{.Description}}"))
+		channel.SetTemplates(entity.WITHDRAW_SUCCESS, templates)
+		// Act
+		msg, err := channel.Render(Notification{Type: entity.WITHDRAW_SUCCESS, Description: "Withdraw completed"})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "Receipt: Withdraw completed", msg.Subject)
+	})
+}
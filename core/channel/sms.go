@@ -0,0 +1,187 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/sms"
+)
+
+// PhoneBook looks up the phone number of a user
+type PhoneBook interface {
+	Phone(ctx context.Context, userID string) (string, error)
+}
+
+const (
+	// ReceiptTTL is how long the receipt of a sent message is kept
+	ReceiptTTL = 24 * time.Hour
+	// MaxReceipts is the number of receipts kept, the oldest are evicted first
+	MaxReceipts = 10000
+)
+
+// DefaultSMSTemplate renders the description of the notification
+var DefaultSMSTemplate = texttemplate.Must(texttemplate.New("sms").Parse(`{{.Description}}`))
+
+// SMSChannel struct
+type SMSChannel struct {
+	provider    sms.Provider
+	phones      PhoneBook
+	types       Types
+	maxSegments int
+	templates   map[entity.NotifyTypeMessage]*texttemplate.Template
+	now         func() time.Time
+	mu          sync.RWMutex
+	receipts    map[string]sentReceipt
+	// sent holds the message IDs in send order to evict the oldest receipts
+	sent []string
+}
+
+// sentReceipt is the last receipt of a message sent by the channel
+type sentReceipt struct {
+	receipt sms.Receipt
+	sentAt  time.Time
+}
+
+// NewSMSChannel creates a new SMSChannel instance, texts longer than maxSegments are truncated
+func NewSMSChannel(provider sms.Provider, phones PhoneBook, types Types, maxSegments int) *SMSChannel {
+	return &SMSChannel{
+		provider:    provider,
+		phones:      phones,
+		types:       types,
+		maxSegments: maxSegments,
+		templates:   make(map[entity.NotifyTypeMessage]*texttemplate.Template),
+		now:         time.Now,
+		receipts:    make(map[string]sentReceipt),
+	}
+}
+
+// SetTemplate overrides the template of a notification type
+func (c *SMSChannel) SetTemplate(typeMessage entity.NotifyTypeMessage, template *texttemplate.Template) {
+	c.templates[typeMessage] = template
+}
+
+// Name returns the channel name
+func (c *SMSChannel) Name() string {
+	return "sms"
+}
+
+// Accepts reports whether the type is delivered by SMS
+func (c *SMSChannel) Accepts(typeMessage entity.NotifyTypeMessage) bool {
+	return c.types.Contains(typeMessage)
+}
+
+// Deliver renders and sends the notification to the user phone
+func (c *SMSChannel) Deliver(ctx context.Context, notification Notification) error {
+	phone, err := c.phones.Phone(ctx, notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	text, err := c.Render(notification)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.provider.Send(ctx, phone, text)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	c.evict(now)
+	c.receipts[result.MessageID] = sentReceipt{
+		receipt: sms.Receipt{Provider: c.provider.Name(), MessageID: result.MessageID, Status: sms.StatusPending},
+		sentAt:  now,
+	}
+	c.sent = append(c.sent, result.MessageID)
+	c.mu.Unlock()
+
+	logutils.Info("SMS sent", logutils.Fields{"provider": c.provider.Name(), "message_id": result.MessageID, "segments": result.Segments})
+
+	return nil
+}
+
+// Render renders the text of the notification within the segment limit
+func (c *SMSChannel) Render(notification Notification) (string, error) {
+	template, ok := c.templates[notification.Type]
+	if !ok {
+		template = DefaultSMSTemplate
+	}
+
+	var text bytes.Buffer
+	if err := template.Execute(&text, notification); err != nil {
+		return "", err
+	}
+
+	return sms.Truncate(text.String(), c.maxSegments), nil
+}
+
+// Receipt returns the last delivery receipt of the message, receipts are kept for ReceiptTTL
+func (c *SMSChannel) Receipt(messageID string) (sms.Receipt, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sent, ok := c.receipts[messageID]
+	if !ok || c.now().Sub(sent.sentAt) >= ReceiptTTL {
+		return sms.Receipt{}, false
+	}
+
+	return sent.receipt, true
+}
+
+// evict removes the expired receipts and the oldest ones beyond MaxReceipts, c.mu must be held
+func (c *SMSChannel) evict(now time.Time) {
+	for len(c.sent) > 0 {
+		id := c.sent[0]
+		if len(c.sent) < MaxReceipts && now.Sub(c.receipts[id].sentAt) < ReceiptTTL {
+			return
+		}
+		delete(c.receipts, id)
+		c.sent = c.sent[1:]
+	}
+}
+
+// ReceiptHandler returns the handler receiving the provider delivery receipts,
+// receipts failing the provider authentication are rejected and those of unknown messages ignored
+func (c *SMSChannel) ReceiptHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := c.provider.ParseReceipt(r)
+		if errors.Is(err, sms.ErrUnauthenticatedReceipt) {
+			logutils.Warn("Rejected an unauthenticated SMS receipt", logutils.Fields{"provider": c.provider.Name(), "error": err.Error()})
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logutils.Error("Failed to parse an SMS receipt", err, logutils.Fields{"provider": c.provider.Name()})
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		sent, ok := c.receipts[receipt.MessageID]
+		if ok {
+			sent.receipt = receipt
+			c.receipts[receipt.MessageID] = sent
+		}
+		c.mu.Unlock()
+
+		if !ok {
+			logutils.Warn("Ignoring the receipt of an unknown SMS", logutils.Fields{"provider": receipt.Provider, "message_id": receipt.MessageID})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if receipt.Status == sms.StatusFailed {
+			logutils.Warn("SMS delivery failed", logutils.Fields{"provider": receipt.Provider, "message_id": receipt.MessageID, "error": receipt.Error})
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,132 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/security/webhook"
+	"github.com/Mona-bele/rote-notify/pkg/sms"
+	"github.com/stretchr/testify/assert"
+)
+
+type phoneBook map[string]string
+
+func (p phoneBook) Phone(ctx context.Context, userID string) (string, error) {
+	return p[userID], nil
+}
+
+func TestSMSChannel(t *testing.T) {
+	// Arrange
+	var sent map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "account" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		_, _ = w.Write([]byte(`{"sid":"SM1"}`))
+	}))
+	defer server.Close()
+
+	provider := sms.NewHTTPProvider(sms.HTTPProviderConfig{
+		Endpoint:      server.URL,
+		From:          "ROTE",
+		Username:      "account",
+		Password:      "secret",
+		ToField:       "To",
+		IDField:       "sid",
+		ReceiptSecret: []byte("receipt-secret"),
+	})
+	channel := NewSMSChannel(provider, phoneBook{"1": "+5511999999999"}, Types{entity.WITHDRAW_ERROR}, 1)
+
+	t.Run("Test Deliver", func(t *testing.T) {
+		// Act
+		err := channel.Deliver(context.Background(), Notification{UserID: "1", Type: entity.WITHDRAW_ERROR, Description: strings.Repeat("x", 200)})
+		receipt, ok := channel.Receipt("SM1")
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "+5511999999999", sent["To"])
+		assert.Equal(t, "ROTE", sent["from"])
+		assert.Len(t, sent["text"], 160)
+		assert.True(t, ok)
+		assert.Equal(t, sms.StatusPending, receipt.Status)
+	})
+
+	receipt := func(body string, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/receipts", strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set(webhook.SignatureHeader, webhook.Sign([]byte(secret), time.Now(), []byte(body)))
+		}
+		return req
+	}
+
+	t.Run("Test ReceiptHandler", func(t *testing.T) {
+		// Arrange
+		req := receipt(`{"sid":"SM1","status":"undelivered"}`, "receipt-secret")
+		rec := httptest.NewRecorder()
+		// Act
+		channel.ReceiptHandler().ServeHTTP(rec, req)
+		receipt, _ := channel.Receipt("SM1")
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, sms.StatusFailed, receipt.Status)
+		assert.Equal(t, "undelivered", receipt.Error)
+	})
+
+	t.Run("Test ReceiptHandler rejects unauthenticated receipts", func(t *testing.T) {
+		// Arrange
+		unsigned, forged := httptest.NewRecorder(), httptest.NewRecorder()
+		// Act
+		channel.ReceiptHandler().ServeHTTP(unsigned, receipt(`{"sid":"SM1","status":"delivered"}`, ""))
+		channel.ReceiptHandler().ServeHTTP(forged, receipt(`{"sid":"SM1","status":"delivered"}`, "guessed-secret"))
+		got, _ := channel.Receipt("SM1")
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, unsigned.Code)
+		assert.Equal(t, http.StatusUnauthorized, forged.Code)
+		assert.Equal(t, sms.StatusFailed, got.Status)
+	})
+
+	t.Run("Test ReceiptHandler ignores unknown messages", func(t *testing.T) {
+		// Arrange
+		rec := httptest.NewRecorder()
+		// Act
+		channel.ReceiptHandler().ServeHTTP(rec, receipt(`{"sid":"SM404","status":"delivered"}`, "receipt-secret"))
+		_, ok := channel.Receipt("SM404")
+		// Assert
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.False(t, ok)
+	})
+
+	t.Run("Test receipts expire", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		channel.now = func() time.Time { return now }
+		defer func() { channel.now = time.Now }()
+		// Act
+		now = now.Add(ReceiptTTL)
+		_, expired := channel.Receipt("SM1")
+		_ = channel.Deliver(context.Background(), Notification{UserID: "1", Type: entity.WITHDRAW_ERROR})
+		// Assert, the receipt of the new message has the same ID and replaces the evicted one
+		assert.False(t, expired)
+		assert.Equal(t, []string{"SM1"}, channel.sent)
+		got, ok := channel.Receipt("SM1")
+		assert.True(t, ok)
+		assert.Equal(t, sms.StatusPending, got.Status)
+	})
+
+	t.Run("Test Deliver with rejected credentials", func(t *testing.T) {
+		// Arrange
+		unauthorized := NewSMSChannel(sms.NewHTTPProvider(sms.HTTPProviderConfig{Endpoint: server.URL}), phoneBook{}, nil, 1)
+		// Act
+		err := unauthorized.Deliver(context.Background(), Notification{UserID: "1", Type: entity.WITHDRAW_ERROR})
+		// Assert
+		assert.ErrorContains(t, err, "status 401")
+	})
+}
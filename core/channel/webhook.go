@@ -0,0 +1,307 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/security/webhook"
+)
+
+var (
+	// ErrMissingEnvelope is returned when delivering a notification without its published envelope
+	ErrMissingEnvelope = errors.New("notification has no envelope")
+	// ErrUnknownEndpoint is returned when updating an endpoint that is not stored
+	ErrUnknownEndpoint = errors.New("unknown webhook endpoint")
+)
+
+// Endpoint struct
+type Endpoint struct {
+	ID        string `json:"id"`
+	Recipient string `json:"recipient"`
+	URL       string `json:"url"`
+	Secret    string `json:"-"`
+	Disabled  bool   `json:"disabled"`
+	// Failures counts the consecutive failed deliveries
+	Failures int `json:"failures"`
+}
+
+// EndpointStore stores the webhook endpoints of each recipient
+type EndpointStore interface {
+	Endpoints(ctx context.Context, recipient string) ([]Endpoint, error)
+	SaveEndpoint(ctx context.Context, endpoint Endpoint) error
+	// UpdateEndpoint applies the update to the stored endpoint atomically and returns the updated endpoint
+	UpdateEndpoint(ctx context.Context, id string, update func(endpoint *Endpoint)) (Endpoint, error)
+}
+
+// MemoryEndpointStore struct
+type MemoryEndpointStore struct {
+	mu        sync.RWMutex
+	endpoints map[string]Endpoint
+}
+
+// NewMemoryEndpointStore creates a new MemoryEndpointStore instance
+func NewMemoryEndpointStore() *MemoryEndpointStore {
+	return &MemoryEndpointStore{endpoints: make(map[string]Endpoint)}
+}
+
+// Endpoints returns the endpoints of the recipient
+func (s *MemoryEndpointStore) Endpoints(ctx context.Context, recipient string) ([]Endpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var endpoints []Endpoint
+	for _, endpoint := range s.endpoints {
+		if endpoint.Recipient == recipient {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// SaveEndpoint creates or updates the endpoint
+func (s *MemoryEndpointStore) SaveEndpoint(ctx context.Context, endpoint Endpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.endpoints[endpoint.ID] = endpoint
+
+	return nil
+}
+
+// UpdateEndpoint applies the update to the endpoint under the store lock
+func (s *MemoryEndpointStore) UpdateEndpoint(ctx context.Context, id string, update func(endpoint *Endpoint)) (Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok {
+		return Endpoint{}, fmt.Errorf("%w: %s", ErrUnknownEndpoint, id)
+	}
+	update(&endpoint)
+	s.endpoints[id] = endpoint
+
+	return endpoint, nil
+}
+
+// Delivery struct
+type Delivery struct {
+	EndpointID string        `json:"endpoint_id"`
+	UserID     string        `json:"user_id"`
+	Type       string        `json:"type"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Time       time.Time     `json:"time"`
+}
+
+// WebhookConfig struct
+type WebhookConfig struct {
+	Types Types
+	// MaxAttempts is the number of tries of each delivery, defaults to 5
+	MaxAttempts int
+	// Backoff is the wait before the first retry, it doubles on each retry
+	Backoff time.Duration
+	// DisableAfter disables an endpoint after this many consecutive failed deliveries, defaults to 10
+	DisableAfter int
+	// LogSize is the number of deliveries kept in the log, defaults to 1000
+	LogSize int
+	Client  *http.Client
+}
+
+// WebhookChannel struct
+type WebhookChannel struct {
+	cfg       WebhookConfig
+	endpoints EndpointStore
+	mu        sync.RWMutex
+	log       []Delivery
+}
+
+// NewWebhookChannel creates a new WebhookChannel instance
+func NewWebhookChannel(endpoints EndpointStore, cfg WebhookConfig) *WebhookChannel {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = 10
+	}
+	if cfg.LogSize <= 0 {
+		cfg.LogSize = 1000
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &WebhookChannel{cfg: cfg, endpoints: endpoints}
+}
+
+// Name returns the channel name
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Accepts reports whether the type is delivered by webhook
+func (c *WebhookChannel) Accepts(typeMessage entity.NotifyTypeMessage) bool {
+	return c.cfg.Types.Contains(typeMessage)
+}
+
+// Deliver posts the envelope published to the user queue to every enabled endpoint of the user,
+// receivers parse it with entity.DecodeNotifyType and verify its signed body
+func (c *WebhookChannel) Deliver(ctx context.Context, notification Notification) error {
+	body := notification.Envelope
+	if len(body) == 0 {
+		return ErrMissingEnvelope
+	}
+
+	endpoints, err := c.endpoints.Endpoints(ctx, notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	// The endpoints are posted to concurrently so each one has the whole delivery deadline for its retries
+	var wg sync.WaitGroup
+	errs := make([]error, len(endpoints))
+	for i, endpoint := range endpoints {
+		if endpoint.Disabled {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.deliver(ctx, endpoint, notification, body)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliver posts the body to the endpoint and counts its consecutive failures, disabling it after DisableAfter
+func (c *WebhookChannel) deliver(ctx context.Context, endpoint Endpoint, notification Notification, body []byte) error {
+	postErr := c.post(ctx, endpoint, notification, body)
+
+	disabled := false
+	// The outcome is recorded even when the delivery ran out of time
+	updated, err := c.endpoints.UpdateEndpoint(context.WithoutCancel(ctx), endpoint.ID, func(endpoint *Endpoint) {
+		if postErr == nil {
+			endpoint.Failures = 0
+			return
+		}
+		endpoint.Failures++
+		if endpoint.Failures >= c.cfg.DisableAfter && !endpoint.Disabled {
+			endpoint.Disabled, disabled = true, true
+		}
+	})
+	if disabled {
+		logutils.Warn("Webhook endpoint disabled", logutils.Fields{"endpoint_id": updated.ID, "failures": updated.Failures})
+	}
+	if postErr != nil {
+		postErr = fmt.Errorf("endpoint %s: %w", endpoint.ID, postErr)
+	}
+
+	return errors.Join(postErr, err)
+}
+
+// Deliveries returns the logged deliveries of the endpoint, oldest first
+func (c *WebhookChannel) Deliveries(endpointID string) []Delivery {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var deliveries []Delivery
+	for _, delivery := range c.log {
+		if delivery.EndpointID == endpointID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	return deliveries
+}
+
+// post sends the body to the endpoint, retrying with exponential backoff
+func (c *WebhookChannel) post(ctx context.Context, endpoint Endpoint, notification Notification, body []byte) error {
+	backoff := c.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		status, err := c.request(ctx, endpoint, body)
+
+		delivery := Delivery{
+			EndpointID: endpoint.ID,
+			UserID:     notification.UserID,
+			Type:       notification.Type.String(),
+			Attempt:    attempt,
+			StatusCode: status,
+			Duration:   time.Since(start),
+			Time:       start,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		c.record(delivery)
+
+		if err == nil {
+			return nil
+		}
+
+		// A 4xx other than 408 and 429 will not succeed on retry
+		retryable := status == 0 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+		if !retryable || attempt >= c.cfg.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// request sends one signed POST and returns the response status
+func (c *WebhookChannel) request(ctx context.Context, endpoint Endpoint, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", entity.NotifyTypeContentType)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign([]byte(endpoint.Secret), time.Now(), body))
+
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// record appends the delivery to the log, dropping the oldest entries
+func (c *WebhookChannel) record(delivery Delivery) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.log = append(c.log, delivery)
+	if len(c.log) > c.cfg.LogSize {
+		c.log = c.log[len(c.log)-c.cfg.LogSize:]
+	}
+}
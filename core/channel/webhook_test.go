@@ -0,0 +1,156 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/security/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookChannel(t *testing.T) {
+	// Arrange
+	var calls atomic.Int32
+	var received entity.NotifyType
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify([]byte("partner-secret"), r.Header.Get(webhook.SignatureHeader), body, webhook.DefaultTolerance); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// The first attempt fails to exercise the retry
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	store := NewMemoryEndpointStore()
+	_ = store.SaveEndpoint(context.Background(), Endpoint{ID: "ok", Recipient: "partner", URL: server.URL, Secret: "partner-secret"})
+	_ = store.SaveEndpoint(context.Background(), Endpoint{ID: "down", Recipient: "partner", URL: failing.URL, Secret: "partner-secret"})
+
+	channel := NewWebhookChannel(store, WebhookConfig{MaxAttempts: 2, Backoff: time.Millisecond, DisableAfter: 2})
+	envelope := entity.NewNotifyType("id-1", entity.DEPOSIT_SUCCESS.String(), "partner", "tx-1", "jwt")
+	encoded, err := envelope.Encode()
+	assert.Nil(t, err)
+	notification := Notification{UserID: "partner", Type: entity.DEPOSIT_SUCCESS, Title: "deposit_success", Description: "Deposit completed", Token: "jwt", Envelope: encoded}
+
+	t.Run("Test Deliver signs and retries", func(t *testing.T) {
+		// Act
+		err := channel.Deliver(context.Background(), notification)
+		// Assert
+		assert.ErrorContains(t, err, "endpoint down")
+		assert.Equal(t, envelope.ID, received.ID)
+		assert.Equal(t, envelope.CorrelationID, received.CorrelationID)
+		assert.Equal(t, "jwt", received.Body)
+		assert.Len(t, channel.Deliveries("ok"), 2)
+		assert.Equal(t, http.StatusServiceUnavailable, channel.Deliveries("ok")[0].StatusCode)
+		assert.Equal(t, http.StatusNoContent, channel.Deliveries("ok")[1].StatusCode)
+	})
+
+	t.Run("Test Deliver disables failing endpoints", func(t *testing.T) {
+		// Act
+		_ = channel.Deliver(context.Background(), notification)
+		endpoints, _ := store.Endpoints(context.Background(), "partner")
+		err := channel.Deliver(context.Background(), notification)
+		// Assert
+		for _, endpoint := range endpoints {
+			assert.Equal(t, endpoint.ID == "down", endpoint.Disabled)
+		}
+		assert.Nil(t, err)
+		assert.Len(t, channel.Deliveries("down"), 4)
+	})
+
+	t.Run("Test Deliver needs the envelope", func(t *testing.T) {
+		// Act
+		err := channel.Deliver(context.Background(), Notification{UserID: "partner", Type: entity.DEPOSIT_SUCCESS, Token: "jwt"})
+		// Assert
+		assert.ErrorIs(t, err, ErrMissingEnvelope)
+	})
+}
+
+func TestWebhookChannelConcurrent(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ok.Close()
+
+	encoded, err := entity.NewNotifyType("id-1", entity.DEPOSIT_SUCCESS.String(), "partner", "", "jwt").Encode()
+	assert.Nil(t, err)
+	notification := Notification{UserID: "partner", Type: entity.DEPOSIT_SUCCESS, Token: "jwt", Envelope: encoded}
+
+	t.Run("Test concurrent deliveries count every failure", func(t *testing.T) {
+		// Arrange
+		store := NewMemoryEndpointStore()
+		_ = store.SaveEndpoint(context.Background(), Endpoint{ID: "down", Recipient: "partner", URL: failing.URL})
+		channel := NewWebhookChannel(store, WebhookConfig{MaxAttempts: 1, DisableAfter: 100})
+		// Act
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = channel.Deliver(context.Background(), notification)
+			}()
+		}
+		wg.Wait()
+		endpoints, _ := store.Endpoints(context.Background(), "partner")
+		// Assert
+		assert.Equal(t, 20, endpoints[0].Failures)
+	})
+
+	t.Run("Test a hanging endpoint does not hold back the others", func(t *testing.T) {
+		// Arrange
+		store := NewMemoryEndpointStore()
+		_ = store.SaveEndpoint(context.Background(), Endpoint{ID: "hanging", Recipient: "partner", URL: hanging.URL})
+		_ = store.SaveEndpoint(context.Background(), Endpoint{ID: "ok", Recipient: "partner", URL: ok.URL, Failures: 3})
+		channel := NewWebhookChannel(store, WebhookConfig{MaxAttempts: 5, Backoff: time.Millisecond})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		// Act
+		start := time.Now()
+		err := channel.Deliver(ctx, notification)
+		elapsed := time.Since(start)
+		endpoints, _ := store.Endpoints(context.Background(), "partner")
+		// Assert, the whole fan-out ends with the deadline
+		assert.ErrorContains(t, err, "endpoint hanging")
+		assert.Less(t, elapsed, time.Second)
+		assert.Len(t, channel.Deliveries("ok"), 1)
+		for _, endpoint := range endpoints {
+			assert.Equal(t, endpoint.ID == "hanging", endpoint.Failures == 1)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package devices
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	usersBucket = []byte("users")
+	// tokensBucket maps each token to its user, so a token belongs to one user at a time
+	tokensBucket = []byte("tokens")
+)
+
+// BoltRegistry is a Registry kept in a single file on disk
+type BoltRegistry struct {
+	db         *bolt.DB
+	staleAfter time.Duration
+	now        func() time.Time
+}
+
+// NewBoltRegistry opens or creates the registry file at path, staleAfter defaults to DefaultStaleAfter
+func NewBoltRegistry(path string, staleAfter time.Duration) (*BoltRegistry, error) {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		logutils.Error("Failed to open the device registry", err, logutils.Fields{"path": path})
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(usersBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(tokensBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &BoltRegistry{db: db, staleAfter: staleAfter, now: time.Now}, nil
+}
+
+// Register adds the device or refreshes its details and last-seen time
+func (r *BoltRegistry) Register(ctx context.Context, userID string, device Device) (Device, error) {
+	if err := device.Validate(); err != nil {
+		return Device{}, err
+	}
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		tokens := tx.Bucket(tokensBucket)
+		token := []byte(device.Token)
+
+		if owner := tokens.Get(token); owner != nil && string(owner) != userID {
+			if previous := users.Bucket(owner); previous != nil {
+				if err := previous.Delete(token); err != nil {
+					return err
+				}
+			}
+		}
+
+		user, err := users.CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+
+		device.RegisteredAt = r.now()
+		if value := user.Get(token); value != nil {
+			var existing Device
+			if err := json.Unmarshal(value, &existing); err != nil {
+				return err
+			}
+			device.RegisteredAt = existing.RegisteredAt
+		}
+		device.LastSeen = r.now()
+
+		value, err := json.Marshal(device)
+		if err != nil {
+			return err
+		}
+		if err := user.Put(token, value); err != nil {
+			return err
+		}
+		return tokens.Put(token, []byte(userID))
+	})
+	if err != nil {
+		return Device{}, err
+	}
+
+	return device, nil
+}
+
+// Unregister removes the device from the user
+func (r *BoltRegistry) Unregister(ctx context.Context, userID, token string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil || user.Get([]byte(token)) == nil {
+			return ErrNotFound
+		}
+
+		if err := user.Delete([]byte(token)); err != nil {
+			return err
+		}
+		return tx.Bucket(tokensBucket).Delete([]byte(token))
+	})
+}
+
+// Devices returns the devices of the user seen within the stale period
+func (r *BoltRegistry) Devices(ctx context.Context, userID string) ([]Device, error) {
+	devices := []Device{}
+	limit := r.now().Add(-r.staleAfter)
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil {
+			return nil
+		}
+
+		return user.ForEach(func(_, value []byte) error {
+			var device Device
+			if err := json.Unmarshal(value, &device); err != nil {
+				return err
+			}
+			if device.LastSeen.After(limit) {
+				devices = append(devices, device)
+			}
+			return nil
+		})
+	})
+
+	return devices, err
+}
+
+// Users returns the users with at least one registered device, sorted
+func (r *BoltRegistry) Users(ctx context.Context) ([]string, error) {
+	var users []string
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEachBucket(func(userID []byte) error {
+			if k, _ := tx.Bucket(usersBucket).Bucket(userID).Cursor().First(); k != nil {
+				users = append(users, string(userID))
+			}
+			return nil
+		})
+	})
+
+	return users, err
+}
+
+// Prune removes the devices not seen within the stale period
+func (r *BoltRegistry) Prune(ctx context.Context) (int, error) {
+	pruned := 0
+	limit := r.now().Add(-r.staleAfter)
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		tokens := tx.Bucket(tokensBucket)
+
+		return tx.Bucket(usersBucket).ForEachBucket(func(userID []byte) error {
+			user := tx.Bucket(usersBucket).Bucket(userID)
+
+			var stale [][]byte
+			err := user.ForEach(func(token, value []byte) error {
+				var device Device
+				if err := json.Unmarshal(value, &device); err != nil {
+					return err
+				}
+				if !device.LastSeen.After(limit) {
+					stale = append(stale, append([]byte(nil), token...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// Keys cannot be deleted while iterating the bucket
+			for _, token := range stale {
+				if err := user.Delete(token); err != nil {
+					return err
+				}
+				if err := tokens.Delete(token); err != nil {
+					return err
+				}
+				pruned++
+			}
+			return nil
+		})
+	})
+
+	return pruned, err
+}
+
+// Close closes the registry file
+func (r *BoltRegistry) Close() error {
+	return r.db.Close()
+}
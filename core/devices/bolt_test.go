@@ -0,0 +1,138 @@
+package devices
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/pkg/push"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistry(t *testing.T) (*BoltRegistry, *time.Time) {
+	registry, err := NewBoltRegistry(filepath.Join(t.TempDir(), "devices.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = registry.Close() })
+
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+
+	return registry, &now
+}
+
+func tokens(devices []Device) []string {
+	var tokens []string
+	for _, device := range devices {
+		tokens = append(tokens, device.Token)
+	}
+	return tokens
+}
+
+func TestBoltRegistryRegister(t *testing.T) {
+	t.Run("Test Register fans out to every device", func(t *testing.T) {
+		// Arrange
+		registry, _ := newTestRegistry(t)
+		// Act
+		_, phoneErr := registry.Register(context.Background(), "1", Device{Platform: push.PlatformAndroid, Token: "phone", Locale: "pt-BR", AppVersion: "1.2.0"})
+		_, webErr := registry.Register(context.Background(), "1", Device{Platform: push.PlatformWeb, Token: "browser"})
+		devices, err := registry.Devices(context.Background(), "1")
+		// Assert
+		assert.Nil(t, phoneErr)
+		assert.Nil(t, webErr)
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []string{"phone", "browser"}, tokens(devices))
+	})
+
+	t.Run("Test Register refreshes the device", func(t *testing.T) {
+		// Arrange
+		registry, now := newTestRegistry(t)
+		first, _ := registry.Register(context.Background(), "1", Device{Platform: push.PlatformIOS, Token: "tablet", AppVersion: "1.0.0"})
+		*now = now.Add(time.Minute)
+		// Act
+		second, err := registry.Register(context.Background(), "1", Device{Platform: push.PlatformIOS, Token: "tablet", AppVersion: "1.1.0"})
+		devices, _ := registry.Devices(context.Background(), "1")
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, first.RegisteredAt.Equal(second.RegisteredAt))
+		assert.Equal(t, now.Unix(), second.LastSeen.Unix())
+		assert.Len(t, devices, 1)
+		assert.Equal(t, "1.1.0", devices[0].AppVersion)
+	})
+
+	t.Run("Test a token moves to the new user", func(t *testing.T) {
+		// Arrange
+		registry, _ := newTestRegistry(t)
+		_, _ = registry.Register(context.Background(), "1", Device{Platform: push.PlatformAndroid, Token: "shared"})
+		// Act
+		_, err := registry.Register(context.Background(), "2", Device{Platform: push.PlatformAndroid, Token: "shared"})
+		first, _ := registry.Devices(context.Background(), "1")
+		second, _ := registry.Devices(context.Background(), "2")
+		// Assert
+		assert.Nil(t, err)
+		assert.Empty(t, first)
+		assert.Equal(t, []string{"shared"}, tokens(second))
+	})
+
+	t.Run("Test Register rejects invalid devices", func(t *testing.T) {
+		// Arrange
+		registry, _ := newTestRegistry(t)
+		// Act
+		_, noToken := registry.Register(context.Background(), "1", Device{Platform: push.PlatformAndroid})
+		_, badPlatform := registry.Register(context.Background(), "1", Device{Platform: "fax", Token: "x"})
+		// Assert
+		assert.ErrorIs(t, noToken, ErrInvalidDevice)
+		assert.ErrorIs(t, badPlatform, ErrInvalidDevice)
+	})
+}
+
+func TestBoltRegistryUnregister(t *testing.T) {
+	// Arrange
+	registry, _ := newTestRegistry(t)
+	_, _ = registry.Register(context.Background(), "1", Device{Platform: push.PlatformAndroid, Token: "phone"})
+	// Act
+	err := registry.Unregister(context.Background(), "1", "phone")
+	again := registry.Unregister(context.Background(), "1", "phone")
+	devices, _ := registry.Devices(context.Background(), "1")
+	// Assert
+	assert.Nil(t, err)
+	assert.ErrorIs(t, again, ErrNotFound)
+	assert.Empty(t, devices)
+}
+
+func TestBoltRegistryStale(t *testing.T) {
+	// Arrange
+	registry, now := newTestRegistry(t)
+	_, _ = registry.Register(context.Background(), "1", Device{Platform: push.PlatformAndroid, Token: "old"})
+	*now = now.Add(30 * time.Minute)
+	_, _ = registry.Register(context.Background(), "1", Device{Platform: push.PlatformIOS, Token: "recent"})
+	_, _ = registry.Register(context.Background(), "2", Device{Platform: push.PlatformWeb, Token: "other"})
+	*now = now.Add(45 * time.Minute)
+	// Act
+	active, _ := registry.Devices(context.Background(), "1")
+	pruned, err := registry.Prune(context.Background())
+	again, _ := registry.Prune(context.Background())
+	_, reRegisterErr := registry.Register(context.Background(), "3", Device{Platform: push.PlatformAndroid, Token: "old"})
+	// Assert
+	assert.Equal(t, []string{"recent"}, tokens(active))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.Equal(t, 0, again)
+	assert.Nil(t, reRegisterErr)
+}
+
+func TestBoltRegistryUsers(t *testing.T) {
+	// Arrange
+	registry, _ := newTestRegistry(t)
+	_, _ = registry.Register(context.Background(), "2", Device{Platform: push.PlatformAndroid, Token: "phone"})
+	_, _ = registry.Register(context.Background(), "1", Device{Platform: push.PlatformWeb, Token: "browser"})
+	_, _ = registry.Register(context.Background(), "3", Device{Platform: push.PlatformIOS, Token: "tablet"})
+	_ = registry.Unregister(context.Background(), "3", "tablet")
+	// Act
+	users, err := registry.Users(context.Background())
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, users)
+}
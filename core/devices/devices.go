@@ -0,0 +1,85 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/pkg/push"
+)
+
+const (
+	// DefaultStaleAfter is how long a device stays active without being seen
+	DefaultStaleAfter = 30 * 24 * time.Hour
+	// DefaultPruneInterval is how often the services remove the inactive devices
+	DefaultPruneInterval = time.Hour
+)
+
+var (
+	// ErrNotFound is returned when the device is not registered for the user
+	ErrNotFound = errors.New("device not found")
+	// ErrInvalidDevice is returned when registering a device without token or with an unknown platform
+	ErrInvalidDevice = errors.New("invalid device")
+)
+
+// Device struct
+type Device struct {
+	Platform     string    `json:"platform"`
+	Token        string    `json:"token"`
+	Locale       string    `json:"locale,omitempty"`
+	AppVersion   string    `json:"app_version,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Validate checks the token and the platform of the device
+func (d Device) Validate() error {
+	if d.Token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidDevice)
+	}
+
+	switch d.Platform {
+	case push.PlatformAndroid, push.PlatformIOS, push.PlatformWeb:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown platform %q", ErrInvalidDevice, d.Platform)
+	}
+}
+
+// Registry keeps the devices of each user, a device not seen within the stale period is inactive
+type Registry interface {
+	// Register adds the device or refreshes it, a token registered by another user moves to this one
+	Register(ctx context.Context, userID string, device Device) (Device, error)
+	Unregister(ctx context.Context, userID, token string) error
+	// Devices returns the active devices of the user
+	Devices(ctx context.Context, userID string) ([]Device, error)
+	// Users returns the users with at least one registered device
+	Users(ctx context.Context) ([]string, error)
+	// Prune removes the inactive devices of every user
+	Prune(ctx context.Context) (int, error)
+	Close() error
+}
+
+// PruneEvery prunes the registry on every interval until the context is done
+func PruneEvery(ctx context.Context, registry Registry, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := registry.Prune(ctx)
+			if err != nil {
+				logutils.Error("Failed to prune the devices", err, nil)
+				continue
+			}
+			if pruned > 0 {
+				logutils.Info("Stale devices pruned", logutils.Fields{"pruned": pruned})
+			}
+		}
+	}
+}
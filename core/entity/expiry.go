@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// DefaultExpiry is the validity of the types registered without a TTL
+const DefaultExpiry = time.Hour * 24 * 365
+
+// MapNotifyTypeExpiry maps the built-in NotifyTypeMessage to how long the notification stays valid,
+// it is the TTL they are pre-registered with
+var MapNotifyTypeExpiry = map[NotifyTypeMessage]time.Duration{
+	// Progress updates are replaced by the outcome of the operation
+	DEPOSIT_PROCESS:  time.Hour,
+	WITHDRAW_PROCESS: time.Hour,
+	TRANSFER_PROCESS: time.Hour,
+	REQUEST_PROCESS:  15 * time.Minute,
+
+	// Request
+	REQUEST_EXCHANGE: 24 * time.Hour,
+	REQUEST_ACCEPTED: 24 * time.Hour,
+
+	// Post
+	NEW_POST: 7 * 24 * time.Hour,
+}
+
+// GetExpiry returns how long a notification of the type stays valid
+func (t NotifyTypeMessage) GetExpiry() time.Duration {
+	if def, ok := DefaultRegistry.Lookup(t); ok {
+		return def.GetTTL()
+	}
+
+	return DefaultExpiry
+}
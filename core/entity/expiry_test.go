@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyTypeMessage_GetExpiry(t *testing.T) {
+	// TestNotifyTypeMessage_GetExpiry tests the GetExpiry method
+	// It should return the validity of the type or the default one
+	tests := []struct {
+		name string
+		t    NotifyTypeMessage
+		want time.Duration
+	}{
+		{name: "Test short lived progress", t: REQUEST_PROCESS, want: 15 * time.Minute},
+		{name: "Test configured type", t: NEW_POST, want: 7 * 24 * time.Hour},
+		{name: "Test default", t: DEPOSIT_SUCCESS, want: DefaultExpiry},
+		{name: "Test unknown type", t: NotifyTypeMessage("unknown"), want: DefaultExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.t.GetExpiry())
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package entity
+
+// Family groups the notification types of one transaction lifecycle
+type Family string
+
+const (
+	FamilyDeposit  Family = Family("deposit")
+	FamilyWithdraw Family = Family("withdraw")
+	FamilyTransfer Family = Family("transfer")
+	FamilyRequest  Family = Family("request")
+)
+
+// Lifecycle struct
+type Lifecycle struct {
+	Family Family
+	// Initial are the types a transaction can start with
+	Initial []NotifyTypeMessage
+	// Transitions are the types allowed after each type, types without transitions are terminal
+	Transitions map[NotifyTypeMessage][]NotifyTypeMessage
+	// Aliases maps legacy types to the type they stand for in the lifecycle
+	Aliases map[NotifyTypeMessage]NotifyTypeMessage
+}
+
+// operationLifecycle builds the lifecycle shared by deposit, withdraw and transfer
+func operationLifecycle(family Family, base, process, success, failure, cancel NotifyTypeMessage) *Lifecycle {
+	return &Lifecycle{
+		Family:  family,
+		Initial: []NotifyTypeMessage{process, success, failure, cancel},
+		Transitions: map[NotifyTypeMessage][]NotifyTypeMessage{
+			process: {success, failure, cancel},
+			failure: {process, cancel},
+		},
+		Aliases: map[NotifyTypeMessage]NotifyTypeMessage{base: success},
+	}
+}
+
+// MapLifecycle maps the Family to its Lifecycle
+var MapLifecycle = map[Family]*Lifecycle{
+	FamilyDeposit:  operationLifecycle(FamilyDeposit, DEPOSIT, DEPOSIT_PROCESS, DEPOSIT_SUCCESS, DEPOSIT_ERROR, DEPOSIT_CANCEL),
+	FamilyWithdraw: operationLifecycle(FamilyWithdraw, WITHDRAW, WITHDRAW_PROCESS, WITHDRAW_SUCCESS, WITHDRAW_ERROR, WITHDRAW_CANCEL),
+	FamilyTransfer: operationLifecycle(FamilyTransfer, TRANSFER, TRANSFER_PROCESS, TRANSFER_SUCCESS, TRANSFER_ERROR, TRANSFER_CANCEL),
+	FamilyRequest: {
+		Family:  FamilyRequest,
+		Initial: []NotifyTypeMessage{REQUEST_EXCHANGE},
+		Transitions: map[NotifyTypeMessage][]NotifyTypeMessage{
+			REQUEST_EXCHANGE: {REQUEST_ACCEPTED, REQUEST_REJECTED, REQUEST_EXPIRED, REQUEST_CANCEL},
+			REQUEST_ACCEPTED: {REQUEST_PROCESS, REQUEST_CANCEL},
+			REQUEST_PROCESS:  {REQUEST_COMPLETED, REQUEST_CANCEL},
+		},
+	},
+}
+
+// GetLifecycle returns the Lifecycle the NotifyTypeMessage belongs to
+func (t NotifyTypeMessage) GetLifecycle() (*Lifecycle, bool) {
+	for _, l := range MapLifecycle {
+		if l.Contains(t) {
+			return l, true
+		}
+	}
+
+	return nil, false
+}
+
+// Contains reports whether the type belongs to the lifecycle
+func (l *Lifecycle) Contains(t NotifyTypeMessage) bool {
+	t = l.Resolve(t)
+	for _, initial := range l.Initial {
+		if initial == t {
+			return true
+		}
+	}
+	for from, to := range l.Transitions {
+		if from == t {
+			return true
+		}
+		for _, next := range to {
+			if next == t {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the lifecycle type for a legacy alias
+func (l *Lifecycle) Resolve(t NotifyTypeMessage) NotifyTypeMessage {
+	if alias, ok := l.Aliases[t]; ok {
+		return alias
+	}
+
+	return t
+}
+
+// CanTransition reports whether a transaction in state from can move to to, an empty from is a new transaction
+func (l *Lifecycle) CanTransition(from, to NotifyTypeMessage) bool {
+	to = l.Resolve(to)
+	next := l.Initial
+	if from != "" {
+		next = l.Transitions[l.Resolve(from)]
+	}
+
+	for _, t := range next {
+		if t == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTerminal reports whether no transition is allowed after the type
+func (l *Lifecycle) IsTerminal(t NotifyTypeMessage) bool {
+	return len(l.Transitions[l.Resolve(t)]) == 0
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle_CanTransition(t *testing.T) {
+	// TestLifecycle_CanTransition tests the CanTransition method
+	// It should only allow the transitions of the family
+	tests := []struct {
+		name string
+		from NotifyTypeMessage
+		to   NotifyTypeMessage
+		want bool
+	}{
+		{name: "Test start with process", from: "", to: DEPOSIT_PROCESS, want: true},
+		{name: "Test process to success", from: DEPOSIT_PROCESS, to: DEPOSIT_SUCCESS, want: true},
+		{name: "Test legacy alias", from: DEPOSIT_PROCESS, to: DEPOSIT, want: true},
+		{name: "Test error to retry", from: WITHDRAW_ERROR, to: WITHDRAW_PROCESS, want: true},
+		{name: "Test success after cancel", from: DEPOSIT_CANCEL, to: DEPOSIT_SUCCESS, want: false},
+		{name: "Test cancel after success", from: TRANSFER_SUCCESS, to: TRANSFER_CANCEL, want: false},
+		{name: "Test request start", from: "", to: REQUEST_EXCHANGE, want: true},
+		{name: "Test request skip acceptance", from: REQUEST_EXCHANGE, to: REQUEST_COMPLETED, want: false},
+		{name: "Test request completed", from: REQUEST_PROCESS, to: REQUEST_COMPLETED, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifecycle, ok := tt.to.GetLifecycle()
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, lifecycle.CanTransition(tt.from, tt.to))
+		})
+	}
+}
+
+func TestNotifyTypeMessage_GetLifecycle(t *testing.T) {
+	t.Run("Test GetLifecycle", func(t *testing.T) {
+		// Act
+		deposit, okDeposit := DEPOSIT.GetLifecycle()
+		request, okRequest := REQUEST_CANCEL.GetLifecycle()
+		_, okPost := NEW_POST.GetLifecycle()
+		// Assert
+		assert.True(t, okDeposit)
+		assert.Equal(t, FamilyDeposit, deposit.Family)
+		assert.True(t, okRequest)
+		assert.Equal(t, FamilyRequest, request.Family)
+		assert.True(t, request.IsTerminal(REQUEST_CANCEL))
+		assert.False(t, okPost)
+	})
+}
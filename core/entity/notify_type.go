@@ -1,12 +1,5 @@
 package entity
 
-// NotifyType struct
-type NotifyType struct {
-	Type   string `json:"type"`
-	UserID string `json:"user_id"`
-	Body   []byte `json:"body"`
-}
-
 type NotifyTypeMessage string
 
 const (
@@ -44,7 +37,8 @@ const (
 	NEW_POST NotifyTypeMessage = NotifyTypeMessage("new_post")
 )
 
-// MapNotifyTypeMessage maps the NotifyTypeMessage to a string messages
+// MapNotifyTypeMessage maps the built-in NotifyTypeMessage to a string messages, they are pre-registered
+// in DefaultRegistry which also holds the types registered at runtime
 var MapNotifyTypeMessage = map[NotifyTypeMessage]string{
 	// Deposit
 	DEPOSIT:         "Deposit completed",
@@ -82,12 +76,13 @@ var MapNotifyTypeMessage = map[NotifyTypeMessage]string{
 
 // GetNotifyTypeMessage returns the message of the NotifyTypeMessage
 func GetNotifyTypeMessage(t NotifyTypeMessage) string {
-	return MapNotifyTypeMessage[t]
+	return t.GetNotifyTypeMessage()
 }
 
-// GetNotifyTypeMessage returns the message of the NotifyTypeMessage
+// GetNotifyTypeMessage returns the message of the NotifyTypeMessage, empty when it is not registered
 func (t NotifyTypeMessage) GetNotifyTypeMessage() string {
-	return MapNotifyTypeMessage[t]
+	def, _ := DefaultRegistry.Lookup(t)
+	return def.Message
 }
 
 // GetKey returns the key of the NotifyTypeMessage
@@ -0,0 +1,10 @@
+package entity
+
+// RecallType is the message type of a tombstone
+const RecallType = "recall"
+
+// Tombstone is the payload of the signed message recalling a notification already sent
+type Tombstone struct {
+	RecalledID string `json:"recalled_id"`
+	Reason     string `json:"reason"`
+}
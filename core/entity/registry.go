@@ -0,0 +1,287 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDuplicateType is returned when registering a name that is already registered
+	ErrDuplicateType = errors.New("notification type already registered")
+	// ErrConflictingType is returned when a name differs from a registered one only by case
+	// or is reserved by the library
+	ErrConflictingType = errors.New("notification type conflicts with a registered one")
+	// ErrInvalidDefinition is returned when a definition misses a field or has an invalid one
+	ErrInvalidDefinition = errors.New("invalid notification type definition")
+	// ErrUnknownType is returned by Validate for a type missing from DefaultRegistry
+	ErrUnknownType = errors.New("unknown notification type")
+)
+
+// Category groups related notification types, the built-in ones use the name of their family
+type Category string
+
+const (
+	CategoryDeposit  Category = Category("deposit")
+	CategoryWithdraw Category = Category("withdraw")
+	CategoryTransfer Category = Category("transfer")
+	CategoryRequest  Category = Category("request")
+	CategoryPost     Category = Category("post")
+)
+
+// Priority of the notifications of a type
+type Priority string
+
+const (
+	PriorityLow    Priority = Priority("low")
+	PriorityNormal Priority = Priority("normal")
+	PriorityHigh   Priority = Priority("high")
+)
+
+// typeNamePattern keeps names usable as a word of the routing key
+var typeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// TypeDefinition describes a notification type
+type TypeDefinition struct {
+	Name     NotifyTypeMessage `json:"name"`
+	Category Category          `json:"category"`
+	// Title defaults to the name and Message is the default description
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority Priority `json:"priority"`
+	// TTL is how long the notification stays valid, DefaultExpiry when 0
+	TTL time.Duration `json:"ttl"`
+}
+
+// Validate checks the name is usable in a routing key and the fields are set
+func (d TypeDefinition) Validate() error {
+	switch {
+	case !typeNamePattern.MatchString(string(d.Name)):
+		return fmt.Errorf("%w: name %q must be letters, digits, '_' or '-'", ErrInvalidDefinition, d.Name)
+	case d.Category == "":
+		return fmt.Errorf("%w: %s: category is empty", ErrInvalidDefinition, d.Name)
+	case d.Message == "":
+		return fmt.Errorf("%w: %s: message is empty", ErrInvalidDefinition, d.Name)
+	case d.TTL < 0:
+		return fmt.Errorf("%w: %s: negative TTL %s", ErrInvalidDefinition, d.Name, d.TTL)
+	}
+
+	switch d.Priority {
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s: unknown priority %q", ErrInvalidDefinition, d.Name, d.Priority)
+	}
+}
+
+// GetTitle returns the title, the name when it is empty
+func (d TypeDefinition) GetTitle() string {
+	if d.Title == "" {
+		return d.Name.String()
+	}
+
+	return d.Title
+}
+
+// GetTTL returns the TTL, DefaultExpiry when it is 0
+func (d TypeDefinition) GetTTL() time.Duration {
+	if d.TTL == 0 {
+		return DefaultExpiry
+	}
+
+	return d.TTL
+}
+
+// Registry holds the notification types services can send
+type Registry struct {
+	mu    sync.RWMutex
+	types map[NotifyTypeMessage]TypeDefinition
+	// folded maps the lower-case names to the registered ones
+	folded map[string]NotifyTypeMessage
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		types:  make(map[NotifyTypeMessage]TypeDefinition),
+		folded: make(map[string]NotifyTypeMessage),
+	}
+}
+
+// Register adds the definitions, none of them is added when one is invalid or conflicts
+func (r *Registry) Register(defs ...TypeDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	batch := make(map[string]NotifyTypeMessage, len(defs))
+	for _, def := range defs {
+		if err := def.Validate(); err != nil {
+			return err
+		}
+
+		key := strings.ToLower(string(def.Name))
+		if _, ok := r.types[def.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateType, def.Name)
+		}
+		if existing, ok := r.folded[key]; ok {
+			return fmt.Errorf("%w: %s and %s", ErrConflictingType, def.Name, existing)
+		}
+		if existing, ok := batch[key]; ok {
+			if existing == def.Name {
+				return fmt.Errorf("%w: %s", ErrDuplicateType, def.Name)
+			}
+			return fmt.Errorf("%w: %s and %s", ErrConflictingType, def.Name, existing)
+		}
+		if key == string(RecallType) {
+			return fmt.Errorf("%w: %s is reserved for recalls", ErrConflictingType, def.Name)
+		}
+		batch[key] = def.Name
+	}
+
+	for _, def := range defs {
+		r.types[def.Name] = def
+		r.folded[strings.ToLower(string(def.Name))] = def.Name
+	}
+
+	return nil
+}
+
+// Replace adds or overrides the definitions and removes the names, a reloaded catalog uses it.
+// Nothing changes when a definition is invalid or conflicts.
+func (r *Registry) Replace(defs []TypeDefinition, remove ...NotifyTypeMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := maps.Clone(r.types)
+	for _, name := range remove {
+		delete(types, name)
+	}
+	for _, def := range defs {
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		if strings.ToLower(string(def.Name)) == string(RecallType) {
+			return fmt.Errorf("%w: %s is reserved for recalls", ErrConflictingType, def.Name)
+		}
+		types[def.Name] = def
+	}
+
+	folded := make(map[string]NotifyTypeMessage, len(types))
+	for name := range types {
+		key := strings.ToLower(string(name))
+		if existing, ok := folded[key]; ok {
+			return fmt.Errorf("%w: %s and %s", ErrConflictingType, name, existing)
+		}
+		folded[key] = name
+	}
+
+	r.types, r.folded = types, folded
+
+	return nil
+}
+
+// Lookup returns the definition of the type
+func (r *Registry) Lookup(t NotifyTypeMessage) (TypeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.types[t]
+	return def, ok
+}
+
+// Types returns the definitions sorted by name
+func (r *Registry) Types() []TypeDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]TypeDefinition, 0, len(r.types))
+	for _, def := range r.types {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, k int) bool { return defs[i].Name < defs[k].Name })
+
+	return defs
+}
+
+// DefaultRegistry holds the built-in types and the ones registered with RegisterType
+var DefaultRegistry = newBuiltinRegistry()
+
+// RegisterType adds the definitions to DefaultRegistry, services call it at startup
+func RegisterType(defs ...TypeDefinition) error {
+	return DefaultRegistry.Register(defs...)
+}
+
+// LookupType returns the definition of the type in DefaultRegistry
+func LookupType(t NotifyTypeMessage) (TypeDefinition, bool) {
+	return DefaultRegistry.Lookup(t)
+}
+
+// IsRegistered reports whether the type is in DefaultRegistry
+func (t NotifyTypeMessage) IsRegistered() bool {
+	_, ok := DefaultRegistry.Lookup(t)
+	return ok
+}
+
+// Validate returns ErrUnknownType when the type is not in DefaultRegistry
+func (t NotifyTypeMessage) Validate() error {
+	if !t.IsRegistered() {
+		return fmt.Errorf("%w: %q", ErrUnknownType, t)
+	}
+
+	return nil
+}
+
+// GetTitle returns the title of the type, its name when it is not registered
+func (t NotifyTypeMessage) GetTitle() string {
+	if def, ok := DefaultRegistry.Lookup(t); ok {
+		return def.GetTitle()
+	}
+
+	return t.String()
+}
+
+// newBuiltinRegistry registers the constants of the package with their messages and expiries
+func newBuiltinRegistry() *Registry {
+	defs := make([]TypeDefinition, 0, len(MapNotifyTypeMessage))
+	for t, message := range MapNotifyTypeMessage {
+		defs = append(defs, TypeDefinition{
+			Name:     t,
+			Category: builtinCategory(t),
+			Message:  message,
+			Priority: builtinPriority(t),
+			TTL:      MapNotifyTypeExpiry[t],
+		})
+	}
+
+	r := NewRegistry()
+	if err := r.Register(defs...); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func builtinCategory(t NotifyTypeMessage) Category {
+	if lifecycle, ok := t.GetLifecycle(); ok {
+		return Category(lifecycle.Family)
+	}
+
+	return CategoryPost
+}
+
+// builtinPriority raises failures and lowers progress updates, which are replaced by the outcome
+func builtinPriority(t NotifyTypeMessage) Priority {
+	switch {
+	case strings.HasSuffix(string(t), "_error"):
+		return PriorityHigh
+	case strings.HasSuffix(string(t), "_process"):
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
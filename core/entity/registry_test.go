@@ -0,0 +1,131 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	loyalty := TypeDefinition{
+		Name:     NotifyTypeMessage("loyalty_points"),
+		Category: Category("loyalty"),
+		Title:    "Points earned",
+		Message:  "You earned loyalty points",
+		Priority: PriorityLow,
+		TTL:      48 * time.Hour,
+	}
+
+	t.Run("Test Register and Lookup", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		// Act
+		err := r.Register(loyalty)
+		def, ok := r.Lookup(loyalty.Name)
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, loyalty, def)
+		assert.Equal(t, "Points earned", def.GetTitle())
+		assert.Equal(t, 48*time.Hour, def.GetTTL())
+	})
+
+	t.Run("Test Replace overrides, adds and removes atomically", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		_ = r.Register(loyalty)
+		high := loyalty
+		high.Priority = PriorityHigh
+		upper := loyalty
+		upper.Name = "Loyalty_Points"
+		// Act
+		err := r.Replace([]TypeDefinition{high})
+		overridden, _ := r.Lookup(loyalty.Name)
+		conflictErr := r.Replace([]TypeDefinition{upper})
+		renameErr := r.Replace([]TypeDefinition{upper}, loyalty.Name)
+		_, kept := r.Lookup(loyalty.Name)
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, PriorityHigh, overridden.Priority)
+		assert.ErrorIs(t, conflictErr, ErrConflictingType)
+		assert.Nil(t, renameErr)
+		assert.False(t, kept)
+		assert.Len(t, r.Types(), 1)
+	})
+
+	t.Run("Test Register rejects duplicate and conflicting names", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		_ = r.Register(loyalty)
+		upper := loyalty
+		upper.Name = "Loyalty_Points"
+		recall := loyalty
+		recall.Name = "Recall"
+		// Act
+		duplicate := r.Register(loyalty)
+		conflicting := r.Register(upper)
+		reserved := r.Register(recall)
+		// Assert
+		assert.ErrorIs(t, duplicate, ErrDuplicateType)
+		assert.ErrorIs(t, conflicting, ErrConflictingType)
+		assert.ErrorIs(t, reserved, ErrConflictingType)
+	})
+
+	t.Run("Test Register adds nothing when the batch is rejected", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		other := loyalty
+		other.Name = "loyalty_tier"
+		// Act
+		err := r.Register(other, loyalty, loyalty)
+		_, ok := r.Lookup(other.Name)
+		// Assert
+		assert.ErrorIs(t, err, ErrDuplicateType)
+		assert.False(t, ok)
+	})
+
+	t.Run("Test Register rejects invalid definitions", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(d *TypeDefinition)
+		}{
+			{name: "routing key wildcard", modify: func(d *TypeDefinition) { d.Name = "loyalty.*" }},
+			{name: "empty name", modify: func(d *TypeDefinition) { d.Name = "" }},
+			{name: "empty category", modify: func(d *TypeDefinition) { d.Category = "" }},
+			{name: "empty message", modify: func(d *TypeDefinition) { d.Message = "" }},
+			{name: "unknown priority", modify: func(d *TypeDefinition) { d.Priority = "urgent" }},
+			{name: "negative TTL", modify: func(d *TypeDefinition) { d.TTL = -time.Second }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				def := loyalty
+				tt.modify(&def)
+				assert.ErrorIs(t, NewRegistry().Register(def), ErrInvalidDefinition)
+			})
+		}
+	})
+
+	t.Run("Test the constants are pre-registered", func(t *testing.T) {
+		// Act
+		def, ok := LookupType(DEPOSIT_PROCESS)
+		post, _ := LookupType(NEW_POST)
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, TypeDefinition{
+			Name:     DEPOSIT_PROCESS,
+			Category: CategoryDeposit,
+			Message:  "Processing the deposit",
+			Priority: PriorityLow,
+			TTL:      time.Hour,
+		}, def)
+		assert.Equal(t, CategoryPost, post.Category)
+		assert.Len(t, DefaultRegistry.Types(), len(MapNotifyTypeMessage))
+		assert.ErrorIs(t, RegisterType(TypeDefinition{Name: DEPOSIT, Category: CategoryDeposit, Message: "Deposit", Priority: PriorityNormal}), ErrDuplicateType)
+	})
+
+	t.Run("Test Validate", func(t *testing.T) {
+		assert.Nil(t, DEPOSIT.Validate())
+		assert.ErrorIs(t, NotifyTypeMessage("unknown").Validate(), ErrUnknownType)
+	})
+}
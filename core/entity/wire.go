@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// NotifyTypeVersionLegacy is the version of the bare JWT bodies published before the envelope
+	NotifyTypeVersionLegacy = 1
+	// NotifyTypeVersion is the version of the envelope published by this release
+	NotifyTypeVersion = 2
+	// NotifyTypeContentType is the content type of a published envelope
+	NotifyTypeContentType = "application/vnd.rote-notify.envelope+json"
+)
+
+var (
+	// ErrInvalidNotifyType is returned when a message body is not an envelope
+	ErrInvalidNotifyType = errors.New("invalid notification envelope")
+	// ErrUnsupportedVersion is returned for an envelope newer than NotifyTypeVersion
+	ErrUnsupportedVersion = errors.New("unsupported notification envelope version")
+)
+
+// NotifyType is the envelope of every message published to the user queues
+type NotifyType struct {
+	Version       int       `json:"version"`
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	UserID        string    `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	// Body is the JWT signing the payload, consumers verify it before trusting the other fields
+	Body string `json:"body"`
+}
+
+// NewNotifyType creates an envelope of the current version created now
+func NewNotifyType(id, typeMessage, userID, correlationID, body string) NotifyType {
+	return NotifyType{
+		Version:       NotifyTypeVersion,
+		ID:            id,
+		Type:          typeMessage,
+		UserID:        userID,
+		CreatedAt:     time.Now().UTC(),
+		CorrelationID: correlationID,
+		Body:          body,
+	}
+}
+
+// Encode returns the JSON of the envelope
+func (n NotifyType) Encode() ([]byte, error) {
+	if n.Body == "" {
+		return nil, fmt.Errorf("%w: body is empty", ErrInvalidNotifyType)
+	}
+
+	return json.Marshal(n)
+}
+
+// DecodeNotifyType parses a message body of any version up to NotifyTypeVersion.
+// A version 1 body only sets Body, consumers read the other fields from the message properties.
+func DecodeNotifyType(data []byte) (NotifyType, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return NotifyType{}, fmt.Errorf("%w: body is empty", ErrInvalidNotifyType)
+	}
+	// A JWT never starts with a brace
+	if data[0] != '{' {
+		return NotifyType{Version: NotifyTypeVersionLegacy, Body: string(data)}, nil
+	}
+
+	var n NotifyType
+	if err := json.Unmarshal(data, &n); err != nil {
+		return NotifyType{}, fmt.Errorf("%w: %w", ErrInvalidNotifyType, err)
+	}
+	switch {
+	case n.Version < NotifyTypeVersion:
+		return NotifyType{}, fmt.Errorf("%w: version %d", ErrInvalidNotifyType, n.Version)
+	case n.Version > NotifyTypeVersion:
+		return NotifyType{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, n.Version)
+	case n.Body == "":
+		return NotifyType{}, fmt.Errorf("%w: body is empty", ErrInvalidNotifyType)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyTypeWire(t *testing.T) {
+	t.Run("Test Encode and DecodeNotifyType round trip", func(t *testing.T) {
+		// Arrange
+		envelope := NewNotifyType("id-1", DEPOSIT.String(), "1", "tx-1", "signed.jwt.token")
+		// Act
+		data, encodeErr := envelope.Encode()
+		decoded, decodeErr := DecodeNotifyType(data)
+		// Assert
+		assert.Nil(t, encodeErr)
+		assert.Nil(t, decodeErr)
+		assert.Equal(t, NotifyTypeVersion, decoded.Version)
+		assert.Equal(t, "tx-1", decoded.CorrelationID)
+		assert.True(t, envelope.CreatedAt.Equal(decoded.CreatedAt))
+		decoded.CreatedAt = envelope.CreatedAt
+		assert.Equal(t, envelope, decoded)
+	})
+
+	t.Run("Test DecodeNotifyType reads a legacy bare token", func(t *testing.T) {
+		// Act
+		decoded, err := DecodeNotifyType([]byte("signed.jwt.token\n"))
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, NotifyType{Version: NotifyTypeVersionLegacy, Body: "signed.jwt.token"}, decoded)
+	})
+
+	t.Run("Test DecodeNotifyType ignores unknown fields", func(t *testing.T) {
+		// Act
+		decoded, err := DecodeNotifyType([]byte(`{"version":2,"id":"id-1","body":"signed.jwt.token","priority":"high"}`))
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "id-1", decoded.ID)
+	})
+
+	t.Run("Test DecodeNotifyType errors", func(t *testing.T) {
+		tests := []struct {
+			name string
+			data string
+			err  error
+		}{
+			{name: "Test empty body", data: " ", err: ErrInvalidNotifyType},
+			{name: "Test malformed JSON", data: `{"version":`, err: ErrInvalidNotifyType},
+			{name: "Test missing version", data: `{"body":"signed.jwt.token"}`, err: ErrInvalidNotifyType},
+			{name: "Test missing body", data: `{"version":2}`, err: ErrInvalidNotifyType},
+			{name: "Test newer version", data: `{"version":3,"body":"signed.jwt.token"}`, err: ErrUnsupportedVersion},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := DecodeNotifyType([]byte(tt.data))
+				assert.ErrorIs(t, err, tt.err)
+			})
+		}
+	})
+
+	t.Run("Test Encode rejects an empty body", func(t *testing.T) {
+		_, err := NotifyType{Version: NotifyTypeVersion}.Encode()
+		assert.ErrorIs(t, err, ErrInvalidNotifyType)
+	})
+}
@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+)
+
+// ErrUnauthorized is returned when the client does not send a valid token
+var ErrUnauthorized = errors.New("unauthorized")
+
+// DefaultAudience is the audience of the client tokens, it must differ from the one of the notifications
+const DefaultAudience = "rote-notify-gateway"
+
+// Authenticator returns the user ID of the client making the request
+type Authenticator interface {
+	Authenticate(r *http.Request) (string, error)
+}
+
+// JWTAuthenticator struct
+type JWTAuthenticator struct {
+	jwt      *jwt.JWT
+	issuer   string
+	audience string
+}
+
+// NewJWTAuthenticator authenticates clients with user tokens from jwt.GenerateUserToken,
+// the notification tokens clients receive are rejected
+func NewJWTAuthenticator(j *jwt.JWT, issuer, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{jwt: j, issuer: issuer, audience: audience}
+}
+
+// Authenticate reads the token from the Authorization header or, for browsers, the access_token query parameter
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("access_token")
+	}
+
+	return a.AuthenticateToken(token)
+}
+
+// AuthenticateToken returns the user ID of the user token
+func (a *JWTAuthenticator) AuthenticateToken(token string) (string, error) {
+	if token == "" {
+		return "", ErrUnauthorized
+	}
+
+	userID, err := a.jwt.ParseUserToken(token, a.issuer, a.audience)
+	if err != nil {
+		return "", errors.Join(ErrUnauthorized, err)
+	}
+	// The subject names the queue the client consumes, it must not reach another one
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		return "", errors.Join(ErrUnauthorized, err)
+	}
+
+	return userID, nil
+}
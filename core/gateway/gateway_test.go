@@ -0,0 +1,346 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/gorilla/websocket"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker implements Consumer and amqp.Acknowledger
+type fakeBroker struct {
+	mu        sync.Mutex
+	queues    map[string]bool
+	consumers map[string]chan amqp.Delivery
+	acked     []uint64
+	nacked    []uint64
+	canceled  []string
+	// failConsume makes ConsumeUserQueue return nil like a failed consume
+	failConsume bool
+	// cancelBlock blocks CancelConsumer until it is closed, like a slow broker
+	cancelBlock chan struct{}
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{queues: make(map[string]bool), consumers: make(map[string]chan amqp.Delivery)}
+}
+
+func (b *fakeBroker) CreateUserQueue(userID string, temporary bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queues[userID] = true
+	return nil
+}
+
+func (b *fakeBroker) ConsumeUserQueue(userID, consumerTag string, autoAck bool) <-chan amqp.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failConsume {
+		return nil
+	}
+	ch := make(chan amqp.Delivery, 16)
+	// The broker refuses to consume a queue that was never declared
+	if !b.queues[userID] {
+		close(ch)
+		return ch
+	}
+	b.consumers[userID] = ch
+	return ch
+}
+
+// consumer waits for the hub to consume the queue of the user, it starts in the background
+func (b *fakeBroker) consumer(userID string) chan amqp.Delivery {
+	for i := 0; i < 200; i++ {
+		b.mu.Lock()
+		ch, ok := b.consumers[userID]
+		b.mu.Unlock()
+		if ok {
+			return ch
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	panic("no consumer for user " + userID)
+}
+
+func (b *fakeBroker) CancelConsumer(consumerTag string) error {
+	if b.cancelBlock != nil {
+		<-b.cancelBlock
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.canceled = append(b.canceled, consumerTag)
+	return nil
+}
+
+func (b *fakeBroker) publish(userID string, tag uint64, token string) {
+	b.consumer(userID) <- amqp.Delivery{Acknowledger: b, DeliveryTag: tag, Body: []byte(token)}
+}
+
+func (b *fakeBroker) Ack(tag uint64, multiple bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, tag)
+	return nil
+}
+
+func (b *fakeBroker) Nack(tag uint64, multiple, requeue bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nacked = append(b.nacked, tag)
+	return nil
+}
+
+func (b *fakeBroker) Reject(tag uint64, requeue bool) error {
+	return b.Nack(tag, false, requeue)
+}
+
+func (b *fakeBroker) snapshot() (acked, nacked []uint64, canceled []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]uint64(nil), b.acked...), append([]uint64(nil), b.nacked...), append([]string(nil), b.canceled...)
+}
+
+func newTestJWT(t *testing.T) *jwt.JWT {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	return jwt.NewJWT(privateKey, &env.Env{JwtKid: "JWT_KID_1234"})
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	// Arrange
+	broker := newFakeBroker()
+	j := newTestJWT(t)
+	handler := NewWebSocketHandler(NewHub(broker, 4), NewJWTAuthenticator(j, "issuer", "gateway"), WebSocketConfig{})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	token, err := j.GenerateUserToken("1", "issuer", "gateway", time.Minute)
+	assert.Nil(t, err)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?access_token=" + token
+
+	t.Run("Test unauthenticated clients are rejected", func(t *testing.T) {
+		// Act
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+		// Assert
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Test notification tokens are rejected", func(t *testing.T) {
+		// Arrange, a token a client received in a notification for the same audience
+		notification, err := j.GenerateTokenWithExpiry(`{"title":"deposit"}`, "issuer", "gateway", "1", time.Minute)
+		assert.Nil(t, err)
+		// Act
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?access_token="+notification, nil)
+		// Assert
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Test every tab receives and the first ack reaches the broker", func(t *testing.T) {
+		// Arrange
+		tab1, _, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.Nil(t, err)
+		tab2, _, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			handler.hub.mu.Lock()
+			defer handler.hub.mu.Unlock()
+			return len(handler.hub.streams["1"].subs) == 2
+		}, time.Second, 5*time.Millisecond)
+		// Act
+		broker.publish("1", 7, "signed-token")
+		var frame1, frame2 Frame
+		assert.Nil(t, tab1.ReadJSON(&frame1))
+		assert.Nil(t, tab2.ReadJSON(&frame2))
+		acked, _, _ := broker.snapshot()
+		assert.Empty(t, acked)
+
+		assert.Nil(t, tab1.WriteJSON(Frame{Type: "ack", ID: frame1.ID}))
+		assert.Nil(t, tab2.WriteJSON(Frame{Type: "ack", ID: frame2.ID}))
+		// Assert
+		assert.Equal(t, Frame{Type: "notification", ID: "7", Token: "signed-token"}, frame1)
+		assert.Equal(t, frame1, frame2)
+		assert.Eventually(t, func() bool {
+			acked, _, _ := broker.snapshot()
+			return len(acked) == 1 && acked[0] == 7
+		}, time.Second, 5*time.Millisecond)
+
+		_ = tab1.Close()
+		_ = tab2.Close()
+		assert.Eventually(t, func() bool {
+			_, _, canceled := broker.snapshot()
+			return len(canceled) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Test disconnect requeues unacked messages", func(t *testing.T) {
+		// Arrange
+		tab, _, err := websocket.DefaultDialer.Dial(url, nil)
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			handler.hub.mu.Lock()
+			defer handler.hub.mu.Unlock()
+			_, ok := handler.hub.streams["1"]
+			return ok
+		}, time.Second, 5*time.Millisecond)
+		broker.publish("1", 8, "unacked-token")
+		var frame Frame
+		assert.Nil(t, tab.ReadJSON(&frame))
+		// Act
+		_ = tab.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		_ = tab.Close()
+		// Assert
+		assert.Eventually(t, func() bool {
+			_, nacked, canceled := broker.snapshot()
+			return len(nacked) == 1 && nacked[0] == 8 && len(canceled) == 2
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestHubQueue(t *testing.T) {
+	t.Run("Test a user never notified gets a queue", func(t *testing.T) {
+		// Arrange
+		broker := newFakeBroker()
+		hub := NewHub(broker, 4)
+		// Act
+		sub := hub.Subscribe("new")
+		broker.publish("new", 1, "token")
+		// Assert
+		select {
+		case msg := <-sub.C:
+			assert.Equal(t, Message{ID: "1", Token: "token"}, msg)
+		case <-sub.Done():
+			t.Fatalf("Expected the subscription to stay open, got %v", sub.Err())
+		}
+	})
+
+	t.Run("Test a slow cancel does not block the other users", func(t *testing.T) {
+		// Arrange
+		broker := newFakeBroker()
+		broker.cancelBlock = make(chan struct{})
+		defer close(broker.cancelBlock)
+		hub := NewHub(broker, 4)
+		sub := hub.Subscribe("1")
+		broker.consumer("1")
+		go sub.Close()
+		// Act
+		subscribed := make(chan *Subscription)
+		go func() { subscribed <- hub.Subscribe("2") }()
+		// Assert
+		select {
+		case other := <-subscribed:
+			assert.NotNil(t, other)
+		case <-time.After(time.Second):
+			t.Fatal("Expected Subscribe not to wait for the cancel")
+		}
+	})
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	// Arrange
+	broker := newFakeBroker()
+	hub := NewHub(broker, 1)
+	sub := hub.Subscribe("1")
+	// Act
+	broker.publish("1", 1, "a")
+	broker.publish("1", 2, "b")
+	// Assert
+	select {
+	case <-sub.Done():
+		assert.ErrorIs(t, sub.Err(), ErrSlowSubscriber)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the slow subscriber to be closed")
+	}
+}
+
+func TestHubRecall(t *testing.T) {
+	// Arrange
+	broker := newFakeBroker()
+	hub := NewHub(broker, 4)
+	sub := hub.Subscribe("1")
+	ch := broker.consumer("1")
+	// Act
+	ch <- amqp.Delivery{Acknowledger: broker, DeliveryTag: 1, MessageId: "a", Body: []byte("token")}
+	ch <- amqp.Delivery{Acknowledger: broker, DeliveryTag: 2, MessageId: "b", Body: []byte("tombstone"), Headers: amqp.Table{rabbitmq.RecalledIDHeader: "a"}}
+	original := <-sub.C
+	tombstone := <-sub.C
+	// Assert
+	assert.Equal(t, Message{ID: "a", Token: "token"}, original)
+	assert.Equal(t, Message{ID: "b", Token: "tombstone", RecalledID: "a"}, tombstone)
+	acked, _, _ := broker.snapshot()
+	assert.Equal(t, []uint64{1}, acked)
+	assert.Nil(t, sub.Ack("a"))
+	acked, _, _ = broker.snapshot()
+	assert.Equal(t, []uint64{1}, acked)
+}
+
+func TestHubEnvelope(t *testing.T) {
+	// Arrange
+	broker := newFakeBroker()
+	hub := NewHub(broker, 4)
+	sub := hub.Subscribe("1")
+	ch := broker.consumer("1")
+	envelope, err := entity.NewNotifyType("a", "deposit", "1", "", "token").Encode()
+	assert.Nil(t, err)
+	// Act
+	ch <- amqp.Delivery{Acknowledger: broker, DeliveryTag: 1, MessageId: "bad", Body: []byte(`{"version":99,"body":"token"}`)}
+	ch <- amqp.Delivery{Acknowledger: broker, DeliveryTag: 2, MessageId: "a", Body: envelope}
+	msg := <-sub.C
+	// Assert
+	assert.Equal(t, Message{ID: "a", Token: "token"}, msg)
+	_, nacked, _ := broker.snapshot()
+	assert.Equal(t, []uint64{1}, nacked)
+}
+
+func TestHubStreamClosed(t *testing.T) {
+	t.Run("Test a closed consumer ends the subscriptions", func(t *testing.T) {
+		// Arrange
+		broker := newFakeBroker()
+		hub := NewHub(broker, 4)
+		tab1 := hub.Subscribe("1")
+		tab2 := hub.Subscribe("1")
+		// Act
+		close(broker.consumer("1"))
+		// Assert
+		for _, sub := range []*Subscription{tab1, tab2} {
+			select {
+			case <-sub.Done():
+				assert.ErrorIs(t, sub.Err(), ErrStreamClosed)
+			case <-time.After(time.Second):
+				t.Fatal("Expected the subscription to be closed")
+			}
+		}
+		hub.mu.Lock()
+		assert.Empty(t, hub.streams)
+		hub.mu.Unlock()
+	})
+
+	t.Run("Test a failed consume ends the subscription", func(t *testing.T) {
+		// Arrange
+		broker := newFakeBroker()
+		broker.failConsume = true
+		hub := NewHub(broker, 4)
+		// Act
+		sub := hub.Subscribe("1")
+		// Assert
+		select {
+		case <-sub.Done():
+			assert.ErrorIs(t, sub.Err(), ErrStreamClosed)
+		case <-time.After(time.Second):
+			t.Fatal("Expected the subscription to be closed")
+		}
+	})
+}
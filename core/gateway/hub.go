@@ -0,0 +1,317 @@
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	// ErrSlowSubscriber is the close reason of a subscription that fell behind
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	// ErrStreamClosed is the close reason of the subscriptions of a consumer the broker failed or closed
+	ErrStreamClosed = errors.New("notification stream closed by the broker")
+)
+
+// Consumer consumes the queue of a user, it is implemented by rabbitmq.RabbitMQ
+type Consumer interface {
+	CreateUserQueue(userID string, temporary bool) error
+	ConsumeUserQueue(userID, consumerTag string, autoAck bool) <-chan amqp.Delivery
+	CancelConsumer(consumerTag string) error
+}
+
+// Message struct
+type Message struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	// RecalledID is set on tombstones to the ID of the notification to hide
+	RecalledID string `json:"recalled_id,omitempty"`
+}
+
+// Subscription struct
+type Subscription struct {
+	hub    *Hub
+	userID string
+	C      chan Message
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+// Done is closed when the subscription ends
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the hub ended the subscription, nil when the client closed it
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Ack acknowledges the message to the broker
+func (s *Subscription) Ack(messageID string) error {
+	return s.hub.ack(s.userID, messageID)
+}
+
+// Close removes the subscription from the hub
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s, nil)
+}
+
+// stream is the single broker consumer of a user shared by its subscriptions
+type stream struct {
+	consumerTag string
+	subs        map[*Subscription]struct{}
+	pending     map[string]amqp.Delivery
+}
+
+// Hub struct
+type Hub struct {
+	consumer Consumer
+	buffer   int
+	mu       sync.Mutex
+	streams  map[string]*stream
+}
+
+// NewHub creates a new Hub instance, buffer is the number of unacked messages per subscription
+func NewHub(consumer Consumer, buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = 16
+	}
+
+	return &Hub{
+		consumer: consumer,
+		buffer:   buffer,
+		streams:  make(map[string]*stream),
+	}
+}
+
+// Subscribe starts receiving the messages of the user, every subscription of a user gets every message
+func (h *Hub) Subscribe(userID string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		userID: userID,
+		C:      make(chan Message, h.buffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	st, ok := h.streams[userID]
+	if !ok {
+		st = &stream{
+			consumerTag: consumerTag(userID),
+			subs:        make(map[*Subscription]struct{}),
+			pending:     make(map[string]amqp.Delivery),
+		}
+		h.streams[userID] = st
+	}
+	st.subs[sub] = struct{}{}
+
+	// Messages still waiting for an ack are sent to new tabs too, they were decoded when delivered
+	released := false
+	for id, delivery := range st.pending {
+		msg, _ := newMessage(id, delivery)
+		released = h.send(st, sub, msg) || released
+	}
+	h.mu.Unlock()
+
+	// The consumer is started and canceled outside the lock so a slow broker does not block the other users
+	if released {
+		h.cancel(userID, st)
+	}
+	if !ok {
+		go h.run(userID, st)
+	}
+
+	return sub
+}
+
+// run declares and consumes the queue of the user and fans out its deliveries to the subscriptions,
+// they are ended with ErrStreamClosed when the consumer fails or the broker closes it.
+// The queue is declared first since a user who was never notified has none yet.
+func (h *Hub) run(userID string, st *stream) {
+	if err := h.consumer.CreateUserQueue(userID, false); err != nil {
+		h.closeStream(userID, st)
+		return
+	}
+	deliveries := h.consumer.ConsumeUserQueue(userID, st.consumerTag, false)
+	if deliveries == nil {
+		h.closeStream(userID, st)
+		return
+	}
+
+	// Every subscription may have left while the consumer started
+	h.mu.Lock()
+	released := h.streams[userID] != st
+	h.mu.Unlock()
+	if released {
+		if err := h.consumer.CancelConsumer(st.consumerTag); err != nil {
+			logutils.Error("Failed to cancel the gateway consumer", err, logutils.Fields{"user_id": userID})
+		}
+	}
+
+	for delivery := range deliveries {
+		id := delivery.MessageId
+		if id == "" {
+			id = strconv.FormatUint(delivery.DeliveryTag, 10)
+		}
+
+		h.mu.Lock()
+		if h.streams[userID] != st {
+			h.mu.Unlock()
+			_ = delivery.Nack(false, true)
+			continue
+		}
+		msg, err := newMessage(id, delivery)
+		if err != nil {
+			h.mu.Unlock()
+			// A body no client can verify is dropped instead of redelivered forever
+			logutils.Error("Dropping an invalid message", err, logutils.Fields{"user_id": userID, "id": id})
+			_ = delivery.Nack(false, false)
+			continue
+		}
+		// A recalled notification nobody acked yet is dropped, tabs that got it still get the tombstone
+		if original, ok := st.pending[msg.RecalledID]; ok && msg.RecalledID != "" {
+			delete(st.pending, msg.RecalledID)
+			_ = original.Ack(false)
+		}
+		st.pending[id] = delivery
+		released := false
+		for sub := range st.subs {
+			released = h.send(st, sub, msg) || released
+		}
+		h.mu.Unlock()
+
+		if released {
+			h.cancel(userID, st)
+		}
+	}
+
+	h.closeStream(userID, st)
+}
+
+// closeStream ends the subscriptions of a stream whose consumer stopped, unless it was released first
+func (h *Hub) closeStream(userID string, st *stream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.streams[userID] != st {
+		return
+	}
+	delete(h.streams, userID)
+
+	logutils.Warn("Gateway consumer stopped, closing its subscribers", logutils.Fields{"user_id": userID, "subscribers": len(st.subs)})
+	// The unacked deliveries are requeued by the broker with the channel
+	for sub := range st.subs {
+		sub.once.Do(func() {
+			sub.err = ErrStreamClosed
+			close(sub.done)
+		})
+	}
+	st.subs = nil
+}
+
+// send delivers without blocking, a subscription with a full buffer is closed.
+// It reports whether closing it released the stream, the caller cancels it once unlocked.
+func (h *Hub) send(st *stream, sub *Subscription, msg Message) bool {
+	select {
+	case sub.C <- msg:
+		return false
+	default:
+		logutils.Warn("Closing slow subscriber", logutils.Fields{"user_id": sub.userID})
+		delete(st.subs, sub)
+		sub.once.Do(func() {
+			sub.err = ErrSlowSubscriber
+			close(sub.done)
+		})
+		return h.release(sub.userID, st)
+	}
+}
+
+// ack acknowledges a pending message, later acks from other tabs are ignored
+func (h *Hub) ack(userID, messageID string) error {
+	h.mu.Lock()
+	st, ok := h.streams[userID]
+	if !ok {
+		h.mu.Unlock()
+		return nil
+	}
+	delivery, ok := st.pending[messageID]
+	delete(st.pending, messageID)
+	h.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return delivery.Ack(false)
+}
+
+// unsubscribe removes the subscription and stops the consumer after the last one
+func (h *Hub) unsubscribe(sub *Subscription, err error) {
+	h.mu.Lock()
+	sub.once.Do(func() {
+		sub.err = err
+		close(sub.done)
+	})
+
+	st, ok := h.streams[sub.userID]
+	released := false
+	if ok {
+		delete(st.subs, sub)
+		released = h.release(sub.userID, st)
+	}
+	h.mu.Unlock()
+
+	if released {
+		h.cancel(sub.userID, st)
+	}
+}
+
+// release removes a stream without subscriptions from the hub and reports whether it did,
+// the caller cancels it with cancel once the lock is released
+func (h *Hub) release(userID string, st *stream) bool {
+	if len(st.subs) > 0 || h.streams[userID] != st {
+		return false
+	}
+	delete(h.streams, userID)
+
+	return true
+}
+
+// cancel stops the consumer of a released stream and requeues its pending messages,
+// the hub no longer reaches the stream so its pending messages are read without the lock
+func (h *Hub) cancel(userID string, st *stream) {
+	// A consumer still starting is canceled by run once it sees the stream released
+	if err := h.consumer.CancelConsumer(st.consumerTag); err != nil && !errors.Is(err, rabbitmq.ErrUnknownConsumer) {
+		logutils.Error("Failed to cancel the gateway consumer", err, logutils.Fields{"user_id": userID})
+	}
+	for _, delivery := range st.pending {
+		_ = delivery.Nack(false, true)
+	}
+}
+
+// newMessage builds the message of a delivery from its envelope, reading the recalled ID of tombstones
+func newMessage(id string, delivery amqp.Delivery) (Message, error) {
+	envelope, err := entity.DecodeNotifyType(delivery.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	recalledID, _ := delivery.Headers[rabbitmq.RecalledIDHeader].(string)
+
+	return Message{ID: id, Token: envelope.Body, RecalledID: recalledID}, nil
+}
+
+func consumerTag(userID string) string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return "gateway-" + userID + "-" + hex.EncodeToString(id)
+}
@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// SSEConfig struct
+type SSEConfig struct {
+	// Heartbeat is how often a comment is sent to keep proxies from closing the stream
+	Heartbeat time.Duration
+	// Retry is the reconnection delay suggested to the client
+	Retry time.Duration
+}
+
+// SSEHandler struct
+type SSEHandler struct {
+	hub  *Hub
+	auth Authenticator
+	cfg  SSEConfig
+}
+
+// NewSSEHandler creates a new SSEHandler instance
+func NewSSEHandler(hub *Hub, auth Authenticator, cfg SSEConfig) *SSEHandler {
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.Retry <= 0 {
+		cfg.Retry = 3 * time.Second
+	}
+
+	return &SSEHandler{hub: hub, auth: auth, cfg: cfg}
+}
+
+// ServeHTTP streams the queue of the authenticated user as server-sent events.
+// A message is acked once it is flushed, messages up to Last-Event-ID are acked without being sent again.
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub := h.hub.Subscribe(userID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", h.cfg.Retry.Milliseconds())
+	flusher.Flush()
+
+	logutils.Info("SSE client connected", logutils.Fields{"user_id": userID, "last_event_id": lastEventID})
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case msg := <-sub.C:
+			if seen(msg.ID, lastEventID) {
+				_ = sub.Ack(msg.ID)
+				continue
+			}
+
+			if err := writeEvent(w, msg); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			if err := sub.Ack(msg.ID); err != nil {
+				logutils.Error("Failed to ack a message", err, logutils.Fields{"id": msg.ID})
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-sub.Done():
+			return
+
+		case <-r.Context().Done():
+			logutils.Info("SSE client disconnected", logutils.Fields{"user_id": userID})
+			return
+		}
+	}
+}
+
+// seen reports whether the message was sent before lastEventID, IDs from rabbitmq.NewMessageID sort by time
+func seen(id, lastEventID string) bool {
+	return lastEventID != "" && len(id) == len(lastEventID) && id <= lastEventID
+}
+
+// writeEvent writes a notification event, or a recall event whose data also carries the recalled ID
+func writeEvent(w io.Writer, msg Message) error {
+	if msg.RecalledID == "" {
+		_, err := fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", msg.ID, msg.Token)
+		return err
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, entity.RecallType, data)
+	return err
+}
@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestSSEHandler(t *testing.T) {
+	// Arrange
+	broker := newFakeBroker()
+	j := newTestJWT(t)
+	handler := NewSSEHandler(NewHub(broker, 4), NewJWTAuthenticator(j, "issuer", "gateway"), SSEConfig{Heartbeat: 50 * time.Millisecond})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	token, err := j.GenerateUserToken("1", "issuer", "gateway", time.Minute)
+	assert.Nil(t, err)
+
+	t.Run("Test unauthenticated clients are rejected", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Test resume from Last-Event-ID", func(t *testing.T) {
+		// Arrange
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Last-Event-ID", "0000000000000002aaaaaaaa")
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, []string{"retry: 3000"}, readEvent(t, reader))
+		// Act
+		publish := func(tag uint64, id, token string) {
+			broker.consumer("1") <- amqp.Delivery{Acknowledger: broker, DeliveryTag: tag, MessageId: id, Body: []byte(token)}
+		}
+		publish(1, "0000000000000001aaaaaaaa", "old-token")
+		publish(2, "0000000000000003aaaaaaaa", "new-token")
+		event := readEvent(t, reader)
+		for event[0] == ": heartbeat" {
+			event = readEvent(t, reader)
+		}
+		// Assert
+		assert.Equal(t, []string{"id: 0000000000000003aaaaaaaa", "event: notification", "data: new-token"}, event)
+		assert.Eventually(t, func() bool {
+			acked, _, _ := broker.snapshot()
+			return len(acked) == 2
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Test heartbeat comments", func(t *testing.T) {
+		// Arrange
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"?access_token="+token, nil)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		_ = readEvent(t, reader)
+		// Act
+		event := readEvent(t, reader)
+		// Assert
+		assert.Equal(t, []string{": heartbeat"}, event)
+	})
+}
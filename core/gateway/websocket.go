@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/gorilla/websocket"
+)
+
+// Frame struct
+type Frame struct {
+	// Type is notification or recall from the server, or ack from the client
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Token string `json:"token,omitempty"`
+	// RecalledID is the ID of the notification a recall frame hides
+	RecalledID string `json:"recalled_id,omitempty"`
+}
+
+// WebSocketConfig struct
+type WebSocketConfig struct {
+	// PingPeriod is how often the server pings, it must be lower than PongWait
+	PingPeriod time.Duration
+	// PongWait is how long the server waits for a pong or a frame before disconnecting
+	PongWait time.Duration
+	// WriteWait limits each write to the client
+	WriteWait   time.Duration
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WebSocketHandler struct
+type WebSocketHandler struct {
+	hub      *Hub
+	auth     Authenticator
+	cfg      WebSocketConfig
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates a new WebSocketHandler instance
+func NewWebSocketHandler(hub *Hub, auth Authenticator, cfg WebSocketConfig) *WebSocketHandler {
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = 60 * time.Second
+	}
+	if cfg.PingPeriod <= 0 || cfg.PingPeriod >= cfg.PongWait {
+		cfg.PingPeriod = cfg.PongWait * 9 / 10
+	}
+	if cfg.WriteWait <= 0 {
+		cfg.WriteWait = 10 * time.Second
+	}
+
+	return &WebSocketHandler{
+		hub:      hub,
+		auth:     auth,
+		cfg:      cfg,
+		upgrader: websocket.Upgrader{CheckOrigin: cfg.CheckOrigin},
+	}
+}
+
+// ServeHTTP streams the queue of the authenticated user, acking each message once the client confirms it
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.auth.Authenticate(r)
+	if err != nil {
+		http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logutils.Error("Failed to upgrade the connection", err, logutils.Fields{"user_id": userID})
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(userID)
+	defer sub.Close()
+
+	logutils.Info("WebSocket client connected", logutils.Fields{"user_id": userID})
+
+	closed := make(chan struct{})
+	go h.read(conn, sub, closed)
+
+	ping := time.NewTicker(h.cfg.PingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg := <-sub.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(h.cfg.WriteWait))
+			frame := Frame{Type: "notification", ID: msg.ID, Token: msg.Token}
+			if msg.RecalledID != "" {
+				frame.Type, frame.RecalledID = entity.RecallType, msg.RecalledID
+			}
+			if err := conn.WriteJSON(frame); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.cfg.WriteWait)); err != nil {
+				return
+			}
+
+		case <-sub.Done():
+			code, reason := websocket.CloseNormalClosure, ""
+			if errors.Is(sub.Err(), ErrSlowSubscriber) || errors.Is(sub.Err(), ErrStreamClosed) {
+				code, reason = websocket.CloseTryAgainLater, sub.Err().Error()
+			}
+			h.close(conn, code, reason)
+			return
+
+		case <-r.Context().Done():
+			h.close(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+
+		case <-closed:
+			logutils.Info("WebSocket client disconnected", logutils.Fields{"user_id": userID})
+			return
+		}
+	}
+}
+
+// read handles pongs and acks until the client disconnects
+func (h *WebSocketHandler) read(conn *websocket.Conn, sub *Subscription, closed chan<- struct{}) {
+	defer close(closed)
+
+	_ = conn.SetReadDeadline(time.Now().Add(h.cfg.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.cfg.PongWait))
+	})
+
+	for {
+		var frame Frame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(h.cfg.PongWait))
+
+		if frame.Type != "ack" {
+			continue
+		}
+		if err := sub.Ack(frame.ID); err != nil {
+			logutils.Error("Failed to ack a message", err, logutils.Fields{"id": frame.ID})
+		}
+	}
+}
+
+func (h *WebSocketHandler) close(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(h.cfg.WriteWait))
+}
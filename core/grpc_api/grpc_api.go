@@ -0,0 +1,217 @@
+package grpc_api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/api"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/gateway"
+	"github.com/Mona-bele/rote-notify/core/transaction"
+	"github.com/Mona-bele/rote-notify/pkg/pb/notifyv1"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UserTokenMetadata is the metadata carrying the user token of the streamed user
+const UserTokenMetadata = "x-user-token"
+
+// UserAuthenticator returns the user ID of a user token, it is implemented by gateway.JWTAuthenticator
+type UserAuthenticator interface {
+	AuthenticateToken(token string) (string, error)
+}
+
+// Server struct
+type Server struct {
+	notifyv1.UnimplementedNotifyServiceServer
+	notifier api.Notifier
+	hub      *gateway.Hub
+	users    UserAuthenticator
+}
+
+// NewServer creates a new Server instance, the hub streams the user queues of the users authenticated by users.
+// An API key alone does not stream a user queue, without users every stream is rejected.
+func NewServer(notifier api.Notifier, hub *gateway.Hub, users UserAuthenticator) *Server {
+	return &Server{notifier: notifier, hub: hub, users: users}
+}
+
+// NewGRPCServer creates a grpc.Server serving the NotifyService with the auth and deadline interceptors
+func NewGRPCServer(server *Server, keys api.APIKeys, deadlines Deadlines, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(UnaryAuthInterceptor(keys), UnaryDeadlineInterceptor(deadlines)),
+		grpc.ChainStreamInterceptor(StreamAuthInterceptor(keys)),
+	)
+
+	s := grpc.NewServer(opts...)
+	notifyv1.RegisterNotifyServiceServer(s, server)
+
+	return s
+}
+
+// Notify sends one notification to a user
+func (s *Server) Notify(ctx context.Context, req *notifyv1.NotifyRequest) (*notifyv1.NotifyResponse, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	st, err := s.notify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notifyv1.NotifyResponse{Status: st}, nil
+}
+
+// NotifyBatch sends several notifications, an invalid entry fails the whole batch before anything is sent
+func (s *Server) NotifyBatch(ctx context.Context, req *notifyv1.NotifyBatchRequest) (*notifyv1.NotifyBatchResponse, error) {
+	if len(req.GetNotifications()) == 0 || len(req.GetNotifications()) > api.MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch must have between 1 and %d notifications", api.MaxBatchSize)
+	}
+	for i, n := range req.GetNotifications() {
+		if err := validate(n); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "notification %d: %s", i, status.Convert(err).Message())
+		}
+	}
+
+	results := make([]*notifyv1.NotifyResult, len(req.GetNotifications()))
+	for i, n := range req.GetNotifications() {
+		st, err := s.notify(ctx, n)
+		results[i] = &notifyv1.NotifyResult{Index: int32(i), Sent: true, Status: st}
+		if err != nil {
+			st := status.Convert(err)
+			results[i].Sent = false
+			results[i].Code = st.Code().String()
+			results[i].Error = st.Message()
+		}
+	}
+
+	return &notifyv1.NotifyBatchResponse{Results: results}, nil
+}
+
+// DeleteUserQueue deletes the queue of a user
+func (s *Server) DeleteUserQueue(ctx context.Context, req *notifyv1.DeleteUserQueueRequest) (*notifyv1.DeleteUserQueueResponse, error) {
+	if req.GetUserId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := rabbitmq.ValidateUserID(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.notifier.DeleteNotificationsUserId(ctx, req.GetUserId()); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return &notifyv1.DeleteUserQueueResponse{}, nil
+}
+
+// StreamUserNotifications streams the queue of a user, acking each message once it is sent.
+// The UserTokenMetadata must authenticate the streamed user.
+func (s *Server) StreamUserNotifications(req *notifyv1.StreamUserNotificationsRequest, stream grpc.ServerStreamingServer[notifyv1.UserNotification]) error {
+	if req.GetUserId() == "" {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := rabbitmq.ValidateUserID(req.GetUserId()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.authorize(stream.Context(), req.GetUserId()); err != nil {
+		return err
+	}
+
+	sub := s.hub.Subscribe(req.GetUserId())
+	defer sub.Close()
+
+	for {
+		select {
+		case msg := <-sub.C:
+			if err := stream.Send(&notifyv1.UserNotification{Id: msg.ID, Token: msg.Token, RecalledId: msg.RecalledID}); err != nil {
+				return err
+			}
+			if err := sub.Ack(msg.ID); err != nil {
+				logutils.Error("Failed to ack a message", err, logutils.Fields{"id": msg.ID})
+			}
+
+		case <-sub.Done():
+			if errors.Is(sub.Err(), gateway.ErrSlowSubscriber) {
+				return status.Error(codes.ResourceExhausted, sub.Err().Error())
+			}
+			if errors.Is(sub.Err(), gateway.ErrStreamClosed) {
+				return status.Error(codes.Unavailable, sub.Err().Error())
+			}
+			return nil
+
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// authorize checks the user token of the metadata belongs to the user ID
+func (s *Server) authorize(ctx context.Context, userID string) error {
+	if s.users == nil {
+		return status.Error(codes.PermissionDenied, "streaming user notifications is disabled")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	authenticated, err := s.users.AuthenticateToken(first(md.Get(UserTokenMetadata)))
+	if err != nil {
+		return status.Error(codes.PermissionDenied, "missing or invalid user token")
+	}
+	if authenticated != userID {
+		return status.Error(codes.PermissionDenied, "user token does not belong to the user")
+	}
+
+	return nil
+}
+
+// notify sends the notification and returns its status, its error is mapped to a gRPC status.
+// A notification collapsed by the rate limit is accepted, the pending one carries it.
+func (s *Server) notify(ctx context.Context, req *notifyv1.NotifyRequest) (string, error) {
+	typeMessage := entity.NotifyTypeMessage(req.GetType())
+
+	var err error
+	if req.GetCorrelationId() != "" {
+		err = s.notifier.NotifyTransactionWithExpiry(ctx, req.GetUserId(), req.GetCorrelationId(), typeMessage, req.GetTtl().AsDuration())
+	} else {
+		err = s.notifier.NotifyUserIdWithExpiry(ctx, req.GetUserId(), typeMessage, req.GetTtl().AsDuration())
+	}
+
+	switch {
+	case err == nil && s.notifier.Aggregates(typeMessage):
+		return api.StatusAggregated, nil
+	case err == nil:
+		return api.StatusSent, nil
+	case errors.Is(err, ratelimit.ErrCollapsed):
+		return api.StatusCollapsed, nil
+	case errors.Is(err, ratelimit.ErrRateLimited):
+		return "", status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, transaction.ErrInvalidTransition), errors.Is(err, transaction.ErrFamilyMismatch):
+		return "", status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, transaction.ErrNotLifecycleType), errors.Is(err, rabbitmq.ErrInvalidUserID), errors.Is(err, entity.ErrUnknownType):
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return "", status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return "", status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// validate checks the required fields and the notification type
+func validate(req *notifyv1.NotifyRequest) error {
+	if req.GetUserId() == "" {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := rabbitmq.ValidateUserID(req.GetUserId()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !entity.NotifyTypeMessage(req.GetType()).IsRegistered() {
+		return status.Errorf(codes.InvalidArgument, "unknown notification type %q", req.GetType())
+	}
+	if req.GetTtl() != nil && (req.GetTtl().CheckValid() != nil || req.GetTtl().AsDuration() < 0) {
+		return status.Error(codes.InvalidArgument, "ttl must be a valid non-negative duration")
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package grpc_api
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/gateway"
+	"github.com/Mona-bele/rote-notify/pkg/pb/notifyv1"
+	"github.com/Mona-bele/rote-notify/pkg/ratelimit"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type fakeNotifier struct {
+	mu       sync.Mutex
+	sent     []string
+	expiries []time.Duration
+	deadline time.Time
+	err      error
+}
+
+func (f *fakeNotifier) NotifyUserIdWithExpiry(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, userID+"."+typeMessage.String())
+	f.expiries = append(f.expiries, expiry)
+	f.deadline, _ = ctx.Deadline()
+	return f.err
+}
+
+func (f *fakeNotifier) NotifyTransactionWithExpiry(ctx context.Context, userID, correlationID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	return f.NotifyUserIdWithExpiry(ctx, userID, typeMessage, expiry)
+}
+
+func (f *fakeNotifier) DeleteNotificationsUserId(ctx context.Context, userID string) error {
+	return f.err
+}
+
+func (f *fakeNotifier) Aggregates(typeMessage entity.NotifyTypeMessage) bool { return false }
+
+type fakeBroker struct {
+	mu     sync.Mutex
+	queues map[string]chan amqp.Delivery
+	acked  []uint64
+}
+
+func (b *fakeBroker) CreateUserQueue(userID string, temporary bool) error { return nil }
+
+func (b *fakeBroker) ConsumeUserQueue(userID, consumerTag string, autoAck bool) <-chan amqp.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queues[userID]
+}
+
+func (b *fakeBroker) CancelConsumer(consumerTag string) error { return nil }
+
+func (b *fakeBroker) Ack(tag uint64, multiple bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.acked = append(b.acked, tag)
+	return nil
+}
+
+func (b *fakeBroker) Nack(tag uint64, multiple, requeue bool) error { return nil }
+
+func (b *fakeBroker) Reject(tag uint64, requeue bool) error { return nil }
+
+// fakeUsers authenticates the tokens named user-<id>
+type fakeUsers struct{}
+
+func (fakeUsers) AuthenticateToken(token string) (string, error) {
+	userID, ok := strings.CutPrefix(token, "user-")
+	if !ok {
+		return "", gateway.ErrUnauthorized
+	}
+	return userID, nil
+}
+
+func newClient(t *testing.T, notifier *fakeNotifier, broker *fakeBroker) notifyv1.NotifyServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(NewServer(notifier, gateway.NewHub(broker, 4), fakeUsers{}), map[string]string{"secret-key": "payments"}, Deadlines{Default: time.Second, Max: 5 * time.Second})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return notifyv1.NewNotifyServiceClient(conn)
+}
+
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "secret-key")
+}
+
+func TestServer(t *testing.T) {
+	t.Run("Test Notify", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		client := newClient(t, notifier, &fakeBroker{})
+		// Act
+		_, err := client.Notify(authed(), &notifyv1.NotifyRequest{UserId: "1", Type: "deposit_success"})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1.deposit_success"}, notifier.sent)
+		assert.WithinDuration(t, time.Now().Add(time.Second), notifier.deadline, 500*time.Millisecond)
+	})
+
+	t.Run("Test Notify with a ttl", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		client := newClient(t, notifier, &fakeBroker{})
+		// Act
+		_, err := client.Notify(authed(), &notifyv1.NotifyRequest{UserId: "1", Type: "transfer_process", CorrelationId: "tx-1", Ttl: durationpb.New(10 * time.Minute)})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Minute}, notifier.expiries)
+	})
+
+	t.Run("Test Notify caps long deadlines", func(t *testing.T) {
+		// Arrange
+		notifier := &fakeNotifier{}
+		client := newClient(t, notifier, &fakeBroker{})
+		ctx, cancel := context.WithTimeout(authed(), time.Hour)
+		defer cancel()
+		// Act
+		_, err := client.Notify(ctx, &notifyv1.NotifyRequest{UserId: "1", Type: "deposit_success"})
+		// Assert
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), notifier.deadline, time.Second)
+	})
+
+	t.Run("Test Notify collapsed", func(t *testing.T) {
+		// Arrange
+		client := newClient(t, &fakeNotifier{err: ratelimit.ErrCollapsed}, &fakeBroker{})
+		// Act
+		resp, err := client.Notify(authed(), &notifyv1.NotifyRequest{UserId: "1", Type: "deposit"})
+		batch, batchErr := client.NotifyBatch(authed(), &notifyv1.NotifyBatchRequest{Notifications: []*notifyv1.NotifyRequest{
+			{UserId: "1", Type: "deposit"},
+		}})
+		// Assert, the pending notification carries the collapsed one so it is accepted
+		assert.Nil(t, err)
+		assert.Equal(t, "collapsed", resp.GetStatus())
+		assert.Nil(t, batchErr)
+		assert.True(t, batch.GetResults()[0].GetSent())
+		assert.Equal(t, "collapsed", batch.GetResults()[0].GetStatus())
+	})
+
+	t.Run("Test Notify requires an API key", func(t *testing.T) {
+		// Arrange
+		client := newClient(t, &fakeNotifier{}, &fakeBroker{})
+		// Act
+		_, err := client.Notify(context.Background(), &notifyv1.NotifyRequest{UserId: "1", Type: "deposit"})
+		// Assert
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Test Notify validation", func(t *testing.T) {
+		// Arrange
+		client := newClient(t, &fakeNotifier{}, &fakeBroker{})
+		// Act
+		_, errUser := client.Notify(authed(), &notifyv1.NotifyRequest{Type: "deposit"})
+		_, errType := client.Notify(authed(), &notifyv1.NotifyRequest{UserId: "1", Type: "unknown"})
+		_, errTTL := client.Notify(authed(), &notifyv1.NotifyRequest{UserId: "1", Type: "deposit", Ttl: durationpb.New(-time.Minute)})
+		// Assert
+		assert.Equal(t, codes.InvalidArgument, status.Code(errUser))
+		assert.Equal(t, codes.InvalidArgument, status.Code(errType))
+		assert.Equal(t, codes.InvalidArgument, status.Code(errTTL))
+	})
+
+	t.Run("Test NotifyBatch", func(t *testing.T) {
+		// Arrange
+		client := newClient(t, &fakeNotifier{err: ratelimit.ErrRateLimited}, &fakeBroker{})
+		// Act
+		resp, err := client.NotifyBatch(authed(), &notifyv1.NotifyBatchRequest{Notifications: []*notifyv1.NotifyRequest{
+			{UserId: "1", Type: "new_post"},
+		}})
+		// Assert
+		assert.Nil(t, err)
+		assert.Len(t, resp.GetResults(), 1)
+		assert.False(t, resp.GetResults()[0].GetSent())
+		assert.Equal(t, codes.ResourceExhausted.String(), resp.GetResults()[0].GetCode())
+	})
+
+	t.Run("Test StreamUserNotifications", func(t *testing.T) {
+		// Arrange
+		queue := make(chan amqp.Delivery, 1)
+		broker := &fakeBroker{queues: map[string]chan amqp.Delivery{"1": queue}}
+		client := newClient(t, &fakeNotifier{}, broker)
+		ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(authed(), UserTokenMetadata, "user-1"))
+		defer cancel()
+		stream, err := client.StreamUserNotifications(ctx, &notifyv1.StreamUserNotificationsRequest{UserId: "1"})
+		assert.Nil(t, err)
+		// Act
+		queue <- amqp.Delivery{Acknowledger: broker, DeliveryTag: 3, MessageId: "msg-1", Body: []byte("signed-token")}
+		msg, err := stream.Recv()
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "msg-1", msg.GetId())
+		assert.Equal(t, "signed-token", msg.GetToken())
+		assert.Eventually(t, func() bool {
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+			return len(broker.acked) == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+	t.Run("Test StreamUserNotifications requires a token of the user", func(t *testing.T) {
+		// Arrange
+		broker := &fakeBroker{queues: map[string]chan amqp.Delivery{"1": make(chan amqp.Delivery)}}
+		client := newClient(t, &fakeNotifier{}, broker)
+		tests := []struct {
+			name string
+			ctx  context.Context
+		}{
+			{name: "Test API key alone", ctx: authed()},
+			{name: "Test token of another user", ctx: metadata.AppendToOutgoingContext(authed(), UserTokenMetadata, "user-2")},
+			{name: "Test invalid token", ctx: metadata.AppendToOutgoingContext(authed(), UserTokenMetadata, "forged")},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Act
+				stream, err := client.StreamUserNotifications(tt.ctx, &notifyv1.StreamUserNotificationsRequest{UserId: "1"})
+				assert.Nil(t, err)
+				_, err = stream.Recv()
+				// Assert
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			})
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package grpc_api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Deadlines struct
+type Deadlines struct {
+	// Default is applied to unary calls sent without a deadline
+	Default time.Duration
+	// Max caps the deadline of unary calls
+	Max time.Duration
+}
+
+// UnaryAuthInterceptor rejects unary calls without a valid API key
+func UnaryAuthInterceptor(keys api.APIKeys) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authenticate(ctx, keys, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor rejects streams without a valid API key
+func StreamAuthInterceptor(keys api.APIKeys) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authenticate(ss.Context(), keys, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// UnaryDeadlineInterceptor applies the default deadline and caps longer ones
+func UnaryDeadlineInterceptor(deadlines Deadlines) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		deadline, ok := ctx.Deadline()
+		switch {
+		case !ok && deadlines.Default > 0:
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, deadlines.Default)
+			defer cancel()
+		case ok && deadlines.Max > 0 && time.Until(deadline) > deadlines.Max:
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, deadlines.Max)
+			defer cancel()
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// authenticate reads the API key from the x-api-key or authorization metadata
+func authenticate(ctx context.Context, keys api.APIKeys, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	key := first(md.Get("x-api-key"))
+	if key == "" {
+		key = strings.TrimPrefix(first(md.Get("authorization")), "Bearer ")
+	}
+
+	service, ok := keys.Service(key)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing or invalid API key")
+	}
+
+	logutils.Debug("gRPC request", logutils.Fields{"service": service, "method": method})
+
+	return nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
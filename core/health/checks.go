@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+)
+
+// ErrChannelClosed is returned when the publishing channel is missing or closed
+var ErrChannelClosed = errors.New("rabbitmq channel is closed")
+
+// ErrPublishStale is returned when the last successful publish is older than the allowed age
+var ErrPublishStale = errors.New("no successful publish")
+
+// Connection checks the RabbitMQ connection is open
+func Connection(rmq *rabbitmq.RabbitMQ) Check {
+	return func(ctx context.Context) (string, error) {
+		if rmq == nil || rmq.Conn == nil || rmq.Conn.IsClosed() {
+			return "", rabbitmq.ErrConnectionClosed
+		}
+
+		return "open", nil
+	}
+}
+
+// Channel checks the publishing channel is open
+func Channel(rmq *rabbitmq.RabbitMQ) Check {
+	return func(ctx context.Context) (string, error) {
+		if rmq == nil || rmq.Ch == nil || rmq.Ch.IsClosed() {
+			return "", ErrChannelClosed
+		}
+
+		return "open", nil
+	}
+}
+
+// Exchange checks the notifications exchange exists on the broker
+func Exchange(rmq *rabbitmq.RabbitMQ) Check {
+	return func(ctx context.Context) (string, error) {
+		if rmq == nil {
+			return "", rabbitmq.ErrConnectionClosed
+		}
+		if err := rmq.ExchangeExists(); err != nil {
+			return "", err
+		}
+
+		return "declared", nil
+	}
+}
+
+// LastPublish reports the time since the last successful publish, failing past maxAge unless it is 0.
+// A notifier that never published is up, it may just have had nothing to send.
+func LastPublish(rmq *rabbitmq.RabbitMQ, maxAge time.Duration) Check {
+	return func(ctx context.Context) (string, error) {
+		if rmq == nil {
+			return "", rabbitmq.ErrConnectionClosed
+		}
+
+		last := rmq.LastPublish()
+		if last.IsZero() {
+			return "never", nil
+		}
+
+		age := time.Since(last).Truncate(time.Second)
+		detail := fmt.Sprintf("%s ago", age)
+		if maxAge > 0 && age > maxAge {
+			return detail, fmt.Errorf("%w for %s", ErrPublishStale, age)
+		}
+
+		return detail, nil
+	}
+}
+
+// SigningKey checks the key signs a token it can verify
+func SigningKey(j *jwt.JWT) Check {
+	return func(ctx context.Context) (string, error) {
+		if j == nil {
+			return "", errors.New("signing key is not loaded")
+		}
+		if err := j.SelfTest(); err != nil {
+			return "", err
+		}
+
+		return "valid", nil
+	}
+}
+
+// AddNotifier adds the broker and signing key checks of a notifier.
+// The connection and channel are never reopened, so losing them fails the liveness.
+func (h *Health) AddNotifier(rmq *rabbitmq.RabbitMQ, j *jwt.JWT, maxPublishAge time.Duration) {
+	h.AddLiveness("rabbitmq_connection", Connection(rmq))
+	h.AddLiveness("rabbitmq_channel", Channel(rmq))
+	h.AddReadiness("rabbitmq_exchange", Exchange(rmq))
+	h.AddReadiness("last_publish", LastPublish(rmq, maxPublishAge))
+	h.AddReadiness("signing_key", SigningKey(j))
+}
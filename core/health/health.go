@@ -0,0 +1,196 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each check when the Health has no timeout
+const DefaultTimeout = 2 * time.Second
+
+// Status of a check or a report
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Check reports the state of a dependency, the detail is shown even when it is up
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result of a single check
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is up when every check is up
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Err joins the errors of the checks that are down, nil when the report is up
+func (r Report) Err() error {
+	var errs []error
+	for _, result := range r.Checks {
+		if result.Status == StatusDown {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Name, result.Error))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Health runs the liveness and readiness checks of the notifier
+type Health struct {
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+	timeout   time.Duration
+}
+
+// NewHealth creates a new Health instance, each check is bounded by the timeout
+func NewHealth(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Health{timeout: timeout}
+}
+
+// AddLiveness adds a check failing only when a restart is the fix, it is part of the readiness too
+func (h *Health) AddLiveness(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadiness adds a check failing while the notifier cannot send
+func (h *Health) AddReadiness(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, namedCheck{name: name, check: check})
+}
+
+// Liveness runs the liveness checks
+func (h *Health) Liveness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]namedCheck(nil), h.liveness...)
+	h.mu.RUnlock()
+
+	return h.run(ctx, checks)
+}
+
+// Readiness runs the liveness and readiness checks
+func (h *Health) Readiness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append(append([]namedCheck(nil), h.liveness...), h.readiness...)
+	h.mu.RUnlock()
+
+	return h.run(ctx, checks)
+}
+
+// Ready returns the error of the readiness checks, it fits api.NewServer
+func (h *Health) Ready(ctx context.Context) error {
+	return h.Readiness(ctx).Err()
+}
+
+// run runs the checks concurrently, keeping their order in the report
+func (h *Health) run(ctx context.Context, checks []namedCheck) Report {
+	report := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = h.runCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+
+	return report
+}
+
+// runCheck runs a check, reporting it down when it outlives the timeout
+func (h *Health) runCheck(ctx context.Context, c namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		detail, err := c.check(ctx)
+		done <- outcome{detail: detail, err: err}
+	}()
+
+	result := Result{Name: c.name, Status: StatusUp}
+	select {
+	case o := <-done:
+		result.Detail = o.detail
+		if o.err != nil {
+			result.Status = StatusDown
+			result.Error = o.err.Error()
+		}
+	case <-ctx.Done():
+		result.Status = StatusDown
+		result.Error = ctx.Err().Error()
+	}
+
+	return result
+}
+
+// LivenessHandler serves the liveness report, 503 when it is down
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Liveness(r.Context()))
+	})
+}
+
+// ReadinessHandler serves the readiness report, 503 when it is down
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Readiness(r.Context()))
+	})
+}
+
+// Register serves GET /healthz and GET /readyz on the mux
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.Handle("GET /healthz", h.LivenessHandler())
+	mux.Handle("GET /readyz", h.ReadinessHandler())
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func up(detail string) Check {
+	return func(ctx context.Context) (string, error) { return detail, nil }
+}
+
+func down(err error) Check {
+	return func(ctx context.Context) (string, error) { return "", err }
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Test a failing readiness check does not fail the liveness", func(t *testing.T) {
+		// Arrange
+		h := NewHealth(time.Second)
+		h.AddLiveness("connection", up("open"))
+		h.AddReadiness("exchange", down(errors.New("exchange not found")))
+		// Act
+		live := h.Liveness(context.Background())
+		ready := h.Readiness(context.Background())
+		// Assert
+		assert.Equal(t, StatusUp, live.Status)
+		assert.Equal(t, []Result{{Name: "connection", Status: StatusUp, Detail: "open"}}, live.Checks)
+		assert.Equal(t, StatusDown, ready.Status)
+		assert.Equal(t, []Result{
+			{Name: "connection", Status: StatusUp, Detail: "open"},
+			{Name: "exchange", Status: StatusDown, Error: "exchange not found"},
+		}, ready.Checks)
+		assert.EqualError(t, h.Ready(context.Background()), "exchange: exchange not found")
+	})
+
+	t.Run("Test a check outliving the timeout is down", func(t *testing.T) {
+		// Arrange
+		h := NewHealth(10 * time.Millisecond)
+		h.AddLiveness("slow", func(ctx context.Context) (string, error) {
+			time.Sleep(time.Second)
+			return "", nil
+		})
+		// Act
+		report := h.Liveness(context.Background())
+		// Assert
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	})
+
+	t.Run("Test the HTTP handlers", func(t *testing.T) {
+		// Arrange
+		h := NewHealth(time.Second)
+		h.AddLiveness("connection", up("open"))
+		h.AddReadiness("signing_key", down(errors.New("private key is missing")))
+		mux := http.NewServeMux()
+		h.Register(mux)
+		// Act
+		live := httptest.NewRecorder()
+		mux.ServeHTTP(live, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		ready := httptest.NewRecorder()
+		mux.ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report Report
+		_ = json.Unmarshal(ready.Body.Bytes(), &report)
+		// Assert
+		assert.Equal(t, http.StatusOK, live.Code)
+		assert.Equal(t, http.StatusServiceUnavailable, ready.Code)
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, "private key is missing", report.Checks[1].Error)
+	})
+}
+
+func TestChecks(t *testing.T) {
+	// Arrange
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	envJWT := &env.Env{JwtKid: "JWT_KID_1234"}
+
+	t.Run("Test the broker checks without a connection", func(t *testing.T) {
+		// Arrange
+		h := NewHealth(time.Second)
+		h.AddNotifier(&rabbitmq.RabbitMQ{}, jwt.NewJWT(privateKey, envJWT), time.Minute)
+		// Act
+		live := h.Liveness(context.Background())
+		ready := h.Readiness(context.Background())
+		// Assert
+		assert.Equal(t, StatusDown, live.Status)
+		assert.Equal(t, []Result{
+			{Name: "rabbitmq_connection", Status: StatusDown, Error: rabbitmq.ErrConnectionClosed.Error()},
+			{Name: "rabbitmq_channel", Status: StatusDown, Error: ErrChannelClosed.Error()},
+			{Name: "rabbitmq_exchange", Status: StatusDown, Error: rabbitmq.ErrConnectionClosed.Error()},
+			{Name: "last_publish", Status: StatusUp, Detail: "never"},
+			{Name: "signing_key", Status: StatusUp, Detail: "valid"},
+		}, ready.Checks)
+	})
+
+	t.Run("Test SigningKey", func(t *testing.T) {
+		// Act
+		_, validErr := SigningKey(jwt.NewJWT(privateKey, envJWT))(context.Background())
+		_, missingErr := SigningKey(jwt.NewJWT(nil, envJWT))(context.Background())
+		// Assert
+		assert.Nil(t, validErr)
+		assert.EqualError(t, missingErr, "private key is missing")
+	})
+}
@@ -0,0 +1,337 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	usersBucket = []byte("users")
+	// idsBucket maps each entry ID to its user, so entries can be recalled by ID alone
+	idsBucket     = []byte("ids")
+	entriesBucket = []byte("entries")
+	unreadBucket  = []byte("unread")
+)
+
+// BoltStore is a Store kept in a single file on disk
+type BoltStore struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// NewBoltStore opens or creates the inbox file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		logutils.Error("Failed to open the inbox", err, logutils.Fields{"path": path})
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(usersBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(idsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db, now: time.Now}, nil
+}
+
+// Add stores the entry in the user inbox as unread
+func (s *BoltStore) Add(ctx context.Context, entry Entry) error {
+	entry.Read = false
+	entry.ReadAt = time.Time{}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = s.now()
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		user, err := tx.Bucket(usersBucket).CreateBucketIfNotExists([]byte(entry.UserID))
+		if err != nil {
+			return err
+		}
+		entries, err := user.CreateBucketIfNotExists(entriesBucket)
+		if err != nil {
+			return err
+		}
+		unread, err := user.CreateBucketIfNotExists(unreadBucket)
+		if err != nil {
+			return err
+		}
+
+		if err := entries.Put([]byte(entry.ID), value); err != nil {
+			return err
+		}
+		if err := unread.Put([]byte(entry.ID), nil); err != nil {
+			return err
+		}
+		return tx.Bucket(idsBucket).Put([]byte(entry.ID), []byte(entry.UserID))
+	})
+}
+
+// List returns a page of the user inbox, newest first
+func (s *BoltStore) List(ctx context.Context, userID string, opts ListOptions) (Page, error) {
+	page := Page{Entries: []Entry{}}
+	limit := opts.limit()
+
+	now := s.now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil {
+			return nil
+		}
+		entries := user.Bucket(entriesBucket)
+
+		// The unread bucket holds the same keys, walking it skips the read entries
+		index := entries
+		if opts.UnreadOnly {
+			index = user.Bucket(unreadBucket)
+		}
+
+		c := index.Cursor()
+		var k []byte
+		if opts.Cursor == "" {
+			k, _ = c.Last()
+		} else if k, _ = c.Seek([]byte(opts.Cursor)); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+
+		for ; k != nil; k, _ = c.Prev() {
+			var entry Entry
+			if err := json.Unmarshal(entries.Get(k), &entry); err != nil {
+				return err
+			}
+			if entry.Expired(now) || (entry.Recalled && !opts.IncludeRecalled) {
+				continue
+			}
+
+			if len(page.Entries) == limit {
+				page.NextCursor = page.Entries[limit-1].ID
+				break
+			}
+			page.Entries = append(page.Entries, entry)
+		}
+
+		return nil
+	})
+
+	return page, err
+}
+
+// MarkRead marks the entry as read or unread
+func (s *BoltStore) MarkRead(ctx context.Context, userID, id string, read bool) error {
+	return s.update(userID, id, func(entry *Entry, unread *bolt.Bucket) error {
+		if !entry.Visible(s.now()) {
+			return ErrNotFound
+		}
+		if entry.Read == read {
+			return nil
+		}
+
+		entry.Read = read
+		if !read {
+			entry.ReadAt = time.Time{}
+			return unread.Put([]byte(id), nil)
+		}
+
+		entry.ReadAt = s.now()
+		return unread.Delete([]byte(id))
+	})
+}
+
+// Delete removes the entry from the user inbox
+func (s *BoltStore) Delete(ctx context.Context, userID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil || user.Bucket(entriesBucket).Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		if err := user.Bucket(entriesBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := user.Bucket(unreadBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(idsBucket).Delete([]byte(id))
+	})
+}
+
+// UnreadCount returns the number of unread entries of the user
+func (s *BoltStore) UnreadCount(ctx context.Context, userID string) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil {
+			return nil
+		}
+
+		now := s.now()
+		entries := user.Bucket(entriesBucket)
+		return user.Bucket(unreadBucket).ForEach(func(k, _ []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(entries.Get(k), &entry); err != nil {
+				return err
+			}
+			if entry.Visible(now) {
+				count++
+			}
+			return nil
+		})
+	})
+
+	return count, err
+}
+
+// Get returns the entry with the ID, ErrNotFound when no user has it
+func (s *BoltStore) Get(ctx context.Context, id string) (Entry, error) {
+	var entry Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		userID := tx.Bucket(idsBucket).Get([]byte(id))
+		if userID == nil {
+			return ErrNotFound
+		}
+		user := tx.Bucket(usersBucket).Bucket(userID)
+		if user == nil {
+			return ErrNotFound
+		}
+		value := user.Bucket(entriesBucket).Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &entry)
+	})
+
+	return entry, err
+}
+
+// Recall marks the entry recalled and removes it from the unread entries
+func (s *BoltStore) Recall(ctx context.Context, id, reason string) (Entry, error) {
+	var userID string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		userID = string(tx.Bucket(idsBucket).Get([]byte(id)))
+		return nil
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if userID == "" {
+		return Entry{}, ErrNotFound
+	}
+
+	var recalled Entry
+	err = s.update(userID, id, func(entry *Entry, unread *bolt.Bucket) error {
+		if entry.Recalled {
+			return ErrRecalled
+		}
+
+		entry.Recalled = true
+		entry.RecallReason = reason
+		entry.RecalledAt = s.now()
+		recalled = *entry
+		return unread.Delete([]byte(id))
+	})
+
+	return recalled, err
+}
+
+// Prune removes the expired entries, recalled ones included
+func (s *BoltStore) Prune(ctx context.Context) (int, error) {
+	pruned := 0
+	now := s.now()
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(idsBucket)
+
+		return tx.Bucket(usersBucket).ForEachBucket(func(userID []byte) error {
+			user := tx.Bucket(usersBucket).Bucket(userID)
+			entries := user.Bucket(entriesBucket)
+			if entries == nil {
+				return nil
+			}
+
+			var expired [][]byte
+			err := entries.ForEach(func(id, value []byte) error {
+				var entry Entry
+				if err := json.Unmarshal(value, &entry); err != nil {
+					return err
+				}
+				if entry.Expired(now) {
+					expired = append(expired, append([]byte(nil), id...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// Keys cannot be deleted while iterating the bucket
+			for _, id := range expired {
+				if err := entries.Delete(id); err != nil {
+					return err
+				}
+				if err := user.Bucket(unreadBucket).Delete(id); err != nil {
+					return err
+				}
+				if err := ids.Delete(id); err != nil {
+					return err
+				}
+				pruned++
+			}
+			return nil
+		})
+	})
+
+	return pruned, err
+}
+
+// Close closes the inbox file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// update loads the entry, applies fn and stores it back
+func (s *BoltStore) update(userID, id string, fn func(entry *Entry, unread *bolt.Bucket) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		user := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if user == nil {
+			return ErrNotFound
+		}
+
+		entries := user.Bucket(entriesBucket)
+		value := entries.Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry, user.Bucket(unreadBucket)); err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return entries.Put([]byte(id), value)
+	})
+}
@@ -0,0 +1,233 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) *BoltStore {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "inbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func addEntries(t *testing.T, store *BoltStore, userID string, n int) {
+	for i := 0; i < n; i++ {
+		err := store.Add(context.Background(), Entry{ID: fmt.Sprintf("%016x", i), UserID: userID, Type: "deposit", Title: "deposit"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBoltStoreList(t *testing.T) {
+	t.Run("Test List paginates newest first", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 5)
+		addEntries(t, store, "2", 1)
+		// Act
+		first, err := store.List(context.Background(), "1", ListOptions{Limit: 2})
+		second, _ := store.List(context.Background(), "1", ListOptions{Limit: 2, Cursor: first.NextCursor})
+		last, _ := store.List(context.Background(), "1", ListOptions{Limit: 2, Cursor: second.NextCursor})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0000000000000004", "0000000000000003"}, ids(first))
+		assert.Equal(t, []string{"0000000000000002", "0000000000000001"}, ids(second))
+		assert.Equal(t, []string{"0000000000000000"}, ids(last))
+		assert.Equal(t, "", last.NextCursor)
+	})
+
+	t.Run("Test List of an unknown user", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		// Act
+		page, err := store.List(context.Background(), "1", ListOptions{})
+		// Assert
+		assert.Nil(t, err)
+		assert.Empty(t, page.Entries)
+	})
+
+	t.Run("Test List only unread", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 3)
+		_ = store.MarkRead(context.Background(), "1", "0000000000000001", true)
+		// Act
+		page, err := store.List(context.Background(), "1", ListOptions{UnreadOnly: true})
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0000000000000002", "0000000000000000"}, ids(page))
+	})
+}
+
+func TestBoltStoreReadState(t *testing.T) {
+	t.Run("Test MarkRead and UnreadCount", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 3)
+		// Act
+		readErr := store.MarkRead(context.Background(), "1", "0000000000000000", true)
+		afterRead, _ := store.UnreadCount(context.Background(), "1")
+		unreadErr := store.MarkRead(context.Background(), "1", "0000000000000000", false)
+		afterUnread, _ := store.UnreadCount(context.Background(), "1")
+		missingErr := store.MarkRead(context.Background(), "1", "missing", true)
+		// Assert
+		assert.Nil(t, readErr)
+		assert.Nil(t, unreadErr)
+		assert.Equal(t, 2, afterRead)
+		assert.Equal(t, 3, afterUnread)
+		assert.ErrorIs(t, missingErr, ErrNotFound)
+	})
+
+	t.Run("Test Delete", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 2)
+		// Act
+		err := store.Delete(context.Background(), "1", "0000000000000001")
+		again := store.Delete(context.Background(), "1", "0000000000000001")
+		page, _ := store.List(context.Background(), "1", ListOptions{})
+		count, _ := store.UnreadCount(context.Background(), "1")
+		// Assert
+		assert.Nil(t, err)
+		assert.ErrorIs(t, again, ErrNotFound)
+		assert.Equal(t, []string{"0000000000000000"}, ids(page))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Test entries persist after reopening", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "inbox.db")
+		store, _ := NewBoltStore(path)
+		_ = store.Add(context.Background(), Entry{ID: "a", UserID: "1", Type: "deposit"})
+		_ = store.MarkRead(context.Background(), "1", "a", true)
+		_ = store.Close()
+		// Act
+		reopened, err := NewBoltStore(path)
+		page, _ := reopened.List(context.Background(), "1", ListOptions{})
+		_ = reopened.Close()
+		// Assert
+		assert.Nil(t, err)
+		assert.Len(t, page.Entries, 1)
+		assert.True(t, page.Entries[0].Read)
+		assert.False(t, page.Entries[0].ReadAt.IsZero())
+	})
+}
+
+func TestBoltStoreExpiry(t *testing.T) {
+	t.Run("Test expired entries are hidden", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		now := time.Now()
+		store.now = func() time.Time { return now }
+		_ = store.Add(context.Background(), Entry{ID: "a", UserID: "1", Type: "request_process", ExpiresAt: now.Add(time.Minute)})
+		_ = store.Add(context.Background(), Entry{ID: "b", UserID: "1", Type: "deposit"})
+		// Act
+		before, _ := store.List(context.Background(), "1", ListOptions{})
+		beforeCount, _ := store.UnreadCount(context.Background(), "1")
+		now = now.Add(time.Minute)
+		after, _ := store.List(context.Background(), "1", ListOptions{})
+		afterCount, _ := store.UnreadCount(context.Background(), "1")
+		markErr := store.MarkRead(context.Background(), "1", "a", true)
+		// Assert
+		assert.Equal(t, []string{"b", "a"}, ids(before))
+		assert.Equal(t, 2, beforeCount)
+		assert.Equal(t, []string{"b"}, ids(after))
+		assert.Equal(t, 1, afterCount)
+		assert.ErrorIs(t, markErr, ErrNotFound)
+	})
+}
+
+func TestBoltStoreRecall(t *testing.T) {
+	t.Run("Test Recall hides the entry", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 2)
+		// Act
+		entry, err := store.Recall(context.Background(), "0000000000000001", "sent by mistake")
+		again, againErr := store.Recall(context.Background(), "0000000000000001", "sent by mistake")
+		page, _ := store.List(context.Background(), "1", ListOptions{})
+		all, _ := store.List(context.Background(), "1", ListOptions{IncludeRecalled: true})
+		count, _ := store.UnreadCount(context.Background(), "1")
+		markErr := store.MarkRead(context.Background(), "1", "0000000000000001", true)
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "1", entry.UserID)
+		assert.True(t, entry.Recalled)
+		assert.Equal(t, "sent by mistake", entry.RecallReason)
+		assert.ErrorIs(t, againErr, ErrRecalled)
+		assert.Equal(t, Entry{}, again)
+		assert.Equal(t, []string{"0000000000000000"}, ids(page))
+		assert.Equal(t, []string{"0000000000000001", "0000000000000000"}, ids(all))
+		assert.Equal(t, 1, count)
+		assert.ErrorIs(t, markErr, ErrNotFound)
+	})
+
+	t.Run("Test Get finds hidden entries by ID", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 1)
+		_, _ = store.Recall(context.Background(), "0000000000000000", "sent by mistake")
+		// Act
+		entry, err := store.Get(context.Background(), "0000000000000000")
+		_, missingErr := store.Get(context.Background(), "missing")
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "1", entry.UserID)
+		assert.True(t, entry.Recalled)
+		assert.ErrorIs(t, missingErr, ErrNotFound)
+	})
+
+	t.Run("Test Recall of an unknown entry", func(t *testing.T) {
+		// Arrange
+		store := newTestStore(t)
+		addEntries(t, store, "1", 1)
+		_ = store.Delete(context.Background(), "1", "0000000000000000")
+		// Act
+		_, err := store.Recall(context.Background(), "0000000000000000", "")
+		// Assert
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func ids(page Page) []string {
+	var ids []string
+	for _, entry := range page.Entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestBoltStorePrune(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	_ = store.Add(context.Background(), Entry{ID: "a", UserID: "1", Type: "request_process", ExpiresAt: now.Add(time.Minute)})
+	_ = store.Add(context.Background(), Entry{ID: "b", UserID: "1", Type: "deposit", ExpiresAt: now.Add(time.Hour)})
+	_ = store.Add(context.Background(), Entry{ID: "c", UserID: "2", Type: "new_post"})
+	now = now.Add(2 * time.Minute)
+	// Act
+	pruned, err := store.Prune(context.Background())
+	prunedAgain, _ := store.Prune(context.Background())
+	_, getErr := store.Get(context.Background(), "a")
+	all, _ := store.List(context.Background(), "1", ListOptions{IncludeRecalled: true})
+	count, _ := store.UnreadCount(context.Background(), "2")
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, pruned)
+	assert.Equal(t, 0, prunedAgain)
+	assert.ErrorIs(t, getErr, ErrNotFound)
+	assert.Equal(t, []string{"b"}, ids(all))
+	assert.Equal(t, 1, count)
+}
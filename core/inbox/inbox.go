@@ -0,0 +1,125 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+const (
+	// DefaultLimit is the page size used when ListOptions.Limit is not set
+	DefaultLimit = 20
+	// MaxLimit is the largest page size
+	MaxLimit = 100
+	// DefaultPruneInterval is how often the services remove the expired entries
+	DefaultPruneInterval = time.Hour
+)
+
+var (
+	// ErrNotFound is returned when the entry does not exist in the user inbox
+	ErrNotFound = errors.New("inbox entry not found")
+	// ErrRecalled is returned when recalling an entry already recalled
+	ErrRecalled = errors.New("inbox entry already recalled")
+)
+
+// Entry struct
+type Entry struct {
+	// ID is the message ID published to the user queue, IDs sort by creation time
+	ID          string                   `json:"id"`
+	UserID      string                   `json:"user_id"`
+	Type        entity.NotifyTypeMessage `json:"type"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description"`
+	CreatedAt   time.Time                `json:"created_at"`
+	// ExpiresAt hides the entry once elapsed, zero never expires
+	ExpiresAt time.Time `json:"expires_at"`
+	Read      bool      `json:"read"`
+	ReadAt    time.Time `json:"read_at"`
+	// Recalled entries are hidden like expired ones
+	Recalled     bool      `json:"recalled"`
+	RecallReason string    `json:"recall_reason,omitempty"`
+	RecalledAt   time.Time `json:"recalled_at"`
+}
+
+// Expired reports whether the entry is no longer visible at now
+func (e Entry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Visible reports whether the entry is shown at now
+func (e Entry) Visible(now time.Time) bool {
+	return !e.Recalled && !e.Expired(now)
+}
+
+// ListOptions struct
+type ListOptions struct {
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	// Limit is the page size, DefaultLimit when 0 and capped at MaxLimit
+	Limit int
+	// UnreadOnly skips the entries already read
+	UnreadOnly bool
+	// IncludeRecalled lists the recalled entries too
+	IncludeRecalled bool
+}
+
+// Page struct
+type Page struct {
+	Entries []Entry `json:"entries"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Store keeps the notification history of each user, entries are listed newest first
+// and expired or recalled entries are neither listed nor counted
+type Store interface {
+	Add(ctx context.Context, entry Entry) error
+	List(ctx context.Context, userID string, opts ListOptions) (Page, error)
+	MarkRead(ctx context.Context, userID, id string, read bool) error
+	Delete(ctx context.Context, userID, id string) error
+	UnreadCount(ctx context.Context, userID string) (int, error)
+	// Get returns the entry, it is looked up by ID alone and returned even when hidden
+	Get(ctx context.Context, id string) (Entry, error)
+	// Recall marks the entry recalled, it is looked up by ID alone
+	Recall(ctx context.Context, id, reason string) (Entry, error)
+	// Prune removes the expired entries of every user
+	Prune(ctx context.Context) (int, error)
+	Close() error
+}
+
+// PruneEvery prunes the store on every interval until the context is done
+func PruneEvery(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := store.Prune(ctx)
+			if err != nil {
+				logutils.Error("Failed to prune the inbox", err, nil)
+				continue
+			}
+			if pruned > 0 {
+				logutils.Info("Expired inbox entries pruned", logutils.Fields{"pruned": pruned})
+			}
+		}
+	}
+}
+
+// limit returns the page size of the options
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		return MaxLimit
+	}
+
+	return o.Limit
+}
@@ -0,0 +1,48 @@
+package notifications_user_id
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+// WithCatalog takes the texts, TTL and channels of the types from the catalog, reloaded ones included,
+// the types it does not define keep the built-in ones
+func WithCatalog(provider catalog.Provider) Option {
+	return func(n *NotificationsUserId) {
+		n.catalog = provider
+	}
+}
+
+// text returns the title and message of the type in the default locale of the catalog
+func (n *NotificationsUserId) text(typeMessage entity.NotifyTypeMessage) catalog.Text {
+	if n.catalog == nil {
+		return catalog.Text{Title: typeMessage.GetTitle(), Message: typeMessage.GetNotifyTypeMessage()}
+	}
+
+	c := n.catalog.Catalog()
+	return c.Text(typeMessage, c.DefaultLocale)
+}
+
+// expiry returns the TTL of the type
+func (n *NotificationsUserId) expiry(typeMessage entity.NotifyTypeMessage) time.Duration {
+	if n.catalog != nil {
+		if def, ok := n.catalog.Catalog().Lookup(typeMessage); ok {
+			return def.GetTTL()
+		}
+	}
+
+	return typeMessage.GetExpiry()
+}
+
+// channelAllowed reports whether the catalog lets the channel deliver the type
+func (n *NotificationsUserId) channelAllowed(typeMessage entity.NotifyTypeMessage, name string) bool {
+	if n.catalog == nil {
+		return true
+	}
+
+	def, ok := n.catalog.Catalog().Lookup(typeMessage)
+	return !ok || len(def.Channels) == 0 || slices.Contains(def.Channels, name)
+}
@@ -0,0 +1,126 @@
+package notifications_user_id
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+)
+
+// ErrInvalidEnvelope is wrapped by the errors of ValidationInterceptor
+var ErrInvalidEnvelope = errors.New("invalid notification")
+
+// Envelope is the notification going through the interceptors, they may modify it before it is signed
+type Envelope struct {
+	UserID string
+	Type   entity.NotifyTypeMessage
+	Body   Body
+	// Expiry is the validity of the notification, 0 uses the one of the type
+	Expiry time.Duration
+	// CorrelationID is published in the envelope, see WithCorrelationID
+	CorrelationID string
+	// Headers are added to the published message
+	Headers map[string]string
+
+	sent bool
+}
+
+// Sent reports whether the envelope reached the publisher, false once an interceptor short-circuited it
+func (e *Envelope) Sent() bool {
+	return e.sent
+}
+
+// Handler signs and publishes the envelope
+type Handler func(ctx context.Context, envelope *Envelope) error
+
+// Interceptor wraps the rest of the pipeline. It may modify the envelope, return without calling next
+// to short-circuit it, or observe the error of next.
+type Interceptor func(ctx context.Context, envelope *Envelope, next Handler) error
+
+// Chain returns a handler running the interceptors in order before the final handler
+func Chain(final Handler, interceptors ...Interceptor) Handler {
+	handler := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, envelope *Envelope) error {
+			return interceptor(ctx, envelope, next)
+		}
+	}
+
+	return handler
+}
+
+// WithInterceptors runs the interceptors around signing and publishing every notification
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(n *NotificationsUserId) {
+		n.interceptors = append(n.interceptors, interceptors...)
+	}
+}
+
+// LoggingInterceptor logs the outcome and latency of the rest of the pipeline
+func LoggingInterceptor() Interceptor {
+	return func(ctx context.Context, envelope *Envelope, next Handler) error {
+		start := time.Now()
+		err := next(ctx, envelope)
+
+		fields := logutils.Fields{
+			"user_id":  envelope.UserID,
+			"type":     envelope.Type.String(),
+			"duration": time.Since(start).String(),
+		}
+		if err != nil {
+			logutils.Error("Notification pipeline failed", err, fields)
+			return err
+		}
+		if !envelope.Sent() {
+			logutils.Info("Notification short-circuited", fields)
+			return nil
+		}
+		logutils.Debug("Notification pipeline done", fields)
+
+		return nil
+	}
+}
+
+// MetricsInterceptor counts the notifications through the rest of the pipeline by type and outcome,
+// a notification an inner interceptor dropped without error is counted as short_circuited
+func MetricsInterceptor() Interceptor {
+	return func(ctx context.Context, envelope *Envelope, next Handler) error {
+		start := time.Now()
+		typeMessage := envelope.Type.String()
+
+		err := next(ctx, envelope)
+		outcome := notifyOutcome(err)
+		if err == nil && !envelope.Sent() {
+			outcome = "short_circuited"
+		}
+		metrics.Observe(metrics.Pipeline, metrics.PipelineDuration, start, typeMessage, outcome)
+
+		return err
+	}
+}
+
+// ValidationInterceptor rejects the envelopes that earlier interceptors left with an invalid user ID,
+// an unknown type, an empty title or description, or a negative expiry
+func ValidationInterceptor() Interceptor {
+	return func(ctx context.Context, envelope *Envelope, next Handler) error {
+		if err := validate(envelope.UserID, envelope.Type); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+		}
+
+		switch {
+		case envelope.Body.Title == "":
+			return fmt.Errorf("%w: title is empty", ErrInvalidEnvelope)
+		case envelope.Body.Description == "":
+			return fmt.Errorf("%w: description is empty", ErrInvalidEnvelope)
+		case envelope.Expiry < 0:
+			return fmt.Errorf("%w: negative expiry %s", ErrInvalidEnvelope, envelope.Expiry)
+		}
+
+		return next(ctx, envelope)
+	}
+}
@@ -0,0 +1,145 @@
+package notifications_user_id
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEnvelope() *Envelope {
+	return &Envelope{
+		UserID:  "1",
+		Type:    entity.DEPOSIT_SUCCESS,
+		Body:    Body{Title: entity.DEPOSIT_SUCCESS.String(), Description: "Deposit completed"},
+		Headers: map[string]string{},
+	}
+}
+
+// publisher is a final handler recording the envelopes it publishes
+type publisher struct {
+	published []Envelope
+	err       error
+}
+
+func (p *publisher) handle(ctx context.Context, envelope *Envelope) error {
+	if p.err != nil {
+		return p.err
+	}
+	envelope.sent = true
+	p.published = append(p.published, *envelope)
+	return nil
+}
+
+func TestChain(t *testing.T) {
+	t.Run("Test interceptors run in order and modify the envelope", func(t *testing.T) {
+		// Arrange
+		var order []string
+		p := &publisher{}
+		enrich := func(ctx context.Context, envelope *Envelope, next Handler) error {
+			order = append(order, "enrich")
+			envelope.Body.DeviceToken = "device-1"
+			envelope.Headers["x-tenant"] = "rote"
+			return next(ctx, envelope)
+		}
+		observe := func(ctx context.Context, envelope *Envelope, next Handler) error {
+			order = append(order, "observe")
+			return next(ctx, envelope)
+		}
+		handler := Chain(p.handle, enrich, observe)
+		// Act
+		err := handler(context.Background(), newTestEnvelope())
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"enrich", "observe"}, order)
+		assert.Len(t, p.published, 1)
+		assert.Equal(t, "device-1", p.published[0].Body.DeviceToken)
+		assert.Equal(t, map[string]string{"x-tenant": "rote"}, p.published[0].Headers)
+	})
+
+	t.Run("Test an interceptor short-circuits the pipeline", func(t *testing.T) {
+		// Arrange
+		p := &publisher{}
+		filter := func(ctx context.Context, envelope *Envelope, next Handler) error {
+			return nil
+		}
+		envelope := newTestEnvelope()
+		handler := Chain(p.handle, LoggingInterceptor(), filter)
+		// Act
+		err := handler(context.Background(), envelope)
+		// Assert
+		assert.Nil(t, err)
+		assert.False(t, envelope.Sent())
+		assert.Empty(t, p.published)
+	})
+
+	t.Run("Test an interceptor observes the error", func(t *testing.T) {
+		// Arrange
+		p := &publisher{err: errors.New("channel closed")}
+		var observed error
+		observe := func(ctx context.Context, envelope *Envelope, next Handler) error {
+			observed = next(ctx, envelope)
+			return observed
+		}
+		handler := Chain(p.handle, LoggingInterceptor(), observe)
+		// Act
+		err := handler(context.Background(), newTestEnvelope())
+		// Assert
+		assert.EqualError(t, err, "channel closed")
+		assert.Equal(t, err, observed)
+	})
+}
+
+func TestValidationInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(e *Envelope)
+		valid  bool
+	}{
+		{name: "valid", modify: func(e *Envelope) {}, valid: true},
+		{name: "empty user ID", modify: func(e *Envelope) { e.UserID = "" }},
+		{name: "unknown type", modify: func(e *Envelope) { e.Type = "UNKNOWN" }},
+		{name: "empty title", modify: func(e *Envelope) { e.Body.Title = "" }},
+		{name: "negative expiry", modify: func(e *Envelope) { e.Expiry = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run("Test "+tt.name, func(t *testing.T) {
+			// Arrange
+			p := &publisher{}
+			envelope := newTestEnvelope()
+			tt.modify(envelope)
+			// Act
+			err := Chain(p.handle, ValidationInterceptor())(context.Background(), envelope)
+			// Assert
+			if tt.valid {
+				assert.Nil(t, err)
+				assert.Len(t, p.published, 1)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidEnvelope)
+			assert.Empty(t, p.published)
+		})
+	}
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	// Arrange
+	typeMessage := entity.DEPOSIT_SUCCESS.String()
+	sent := testutil.ToFloat64(metrics.Pipeline.WithLabelValues(typeMessage, metrics.OutcomeSuccess))
+	shortCircuited := testutil.ToFloat64(metrics.Pipeline.WithLabelValues(typeMessage, "short_circuited"))
+	drop := func(ctx context.Context, envelope *Envelope, next Handler) error {
+		return nil
+	}
+	p := &publisher{}
+	// Act
+	_ = Chain(p.handle, MetricsInterceptor())(context.Background(), newTestEnvelope())
+	_ = Chain(p.handle, MetricsInterceptor(), drop)(context.Background(), newTestEnvelope())
+	// Assert
+	assert.Equal(t, sent+1, testutil.ToFloat64(metrics.Pipeline.WithLabelValues(typeMessage, metrics.OutcomeSuccess)))
+	assert.Equal(t, shortCircuited+1, testutil.ToFloat64(metrics.Pipeline.WithLabelValues(typeMessage, "short_circuited")))
+}
@@ -3,20 +3,109 @@ package notifications_user_id
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/aggregator"
+	"github.com/Mona-bele/rote-notify/core/audit"
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/channel"
 	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/health"
+	"github.com/Mona-bele/rote-notify/core/inbox"
+	"github.com/Mona-bele/rote-notify/core/transaction"
 	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
 	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/ratelimit"
 	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/Mona-bele/rote-notify/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // NotificationsUserId struct
 type NotificationsUserId struct {
-	env      *env.Env
-	RabbitMQ *rabbitmq.RabbitMQ
-	jwt      *jwt.JWT
+	env          *env.Env
+	RabbitMQ     *rabbitmq.RabbitMQ
+	jwt          *jwt.JWT
+	limiter      *ratelimit.Limiter
+	aggregator   *aggregator.Aggregator
+	transactions *transaction.Tracker
+	channels     []channel.Channel
+	inbox        inbox.Store
+	audit        audit.Log
+	catalog      catalog.Provider
+	interceptors []Interceptor
+	handler      Handler
+	// deliveries tracks the channel deliveries still running in the background
+	deliveries sync.WaitGroup
+}
+
+// DeliveryTimeout bounds the background delivery of a notification to a channel, retries included
+const DeliveryTimeout = 2 * time.Minute
+
+// Option configures a NotificationsUserId instance
+type Option func(n *NotificationsUserId)
+
+// WithRateLimit limits the notifications sent per user and per user and type
+func WithRateLimit(cfg ratelimit.Config) Option {
+	return func(n *NotificationsUserId) {
+		n.limiter = ratelimit.NewLimiter(cfg)
+	}
+}
+
+// WithTransactionMode sets how out-of-order transaction notifications are handled
+func WithTransactionMode(mode transaction.Mode) Option {
+	return func(n *NotificationsUserId) {
+		n.transactions = transaction.NewTracker(mode)
+	}
+}
+
+// WithChannels delivers the notifications accepted by each channel in the background after publishing them,
+// their failures are logged and never fail the notification
+func WithChannels(channels ...channel.Channel) Option {
+	return func(n *NotificationsUserId) {
+		n.channels = append(n.channels, channels...)
+	}
+}
+
+// WithInbox stores every published notification in the user inbox
+func WithInbox(store inbox.Store) Option {
+	return func(n *NotificationsUserId) {
+		n.inbox = store
+	}
+}
+
+// WithAudit records the outcome of every published notification in the audit log
+func WithAudit(log audit.Log) Option {
+	return func(n *NotificationsUserId) {
+		n.audit = log
+	}
+}
+
+// WithAggregation combines bursts of notifications of the same type into one summary,
+// a rule without Render renders the catalog text of the type like a single notification
+func WithAggregation(rules map[entity.NotifyTypeMessage]aggregator.Rule) Option {
+	return func(n *NotificationsUserId) {
+		render := aggregator.RenderText(func(typeMessage entity.NotifyTypeMessage) string {
+			return n.text(typeMessage).Message
+		})
+		rendered := make(map[entity.NotifyTypeMessage]aggregator.Rule, len(rules))
+		for typeMessage, rule := range rules {
+			if rule.Render == nil {
+				rule.Render = render
+			}
+			rendered[typeMessage] = rule
+		}
+
+		n.aggregator = aggregator.NewAggregator(rendered, func(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, count int, text string, expiry time.Duration) error {
+			return n.send(ctx, userID, typeMessage, text, expiry)
+		})
+	}
 }
 
 type Body struct {
@@ -37,7 +126,7 @@ func (b *Body) String() string {
 }
 
 // NewNotificationsUserId creates a new NotificationsUserId instance
-func NewNotificationsUserId(env *env.Env) *NotificationsUserId {
+func NewNotificationsUserId(env *env.Env, opts ...Option) *NotificationsUserId {
 
 	rmq := rabbitmq.NewRabbitMQ(env)
 	jwt, err := jwt.NewJWTFromEnv(env)
@@ -46,51 +135,368 @@ func NewNotificationsUserId(env *env.Env) *NotificationsUserId {
 		return nil
 	}
 
-	return &NotificationsUserId{
-		env:      env,
-		RabbitMQ: rmq,
-		jwt:      jwt,
+	n := &NotificationsUserId{
+		env:          env,
+		RabbitMQ:     rmq,
+		jwt:          jwt,
+		transactions: transaction.NewTracker(transaction.ModeReject),
 	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.handler = Chain(n.dispatch, n.interceptors...)
+
+	return n
 }
 
 // NotifyUserId notifies the user ID
-func (n *NotificationsUserId) NotifyUserId(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage) {
+func (n *NotificationsUserId) NotifyUserId(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage) error {
+	return n.NotifyUserIdWithExpiry(ctx, userID, typeMessage, 0)
+}
+
+// NotifyUserIdWithExpiry notifies the user ID with a validity overriding the one of the type,
+// a zero expiry keeps the validity of the type
+func (n *NotificationsUserId) NotifyUserIdWithExpiry(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	return n.notifyUserId(ctx, userID, typeMessage, expiry, func(error) {})
+}
+
+// notifyUserId notifies the user ID and calls settle once with the outcome of sending the notification,
+// which is known when its summary is flushed for an aggregated type
+func (n *NotificationsUserId) notifyUserId(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration, settle aggregator.SettleFunc) error {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "NotifyUserId", trace.WithAttributes(
+		attribute.String("rote.user_id", userID),
+		attribute.String("rote.type", typeMessage.String()),
+	))
 
-	n.RabbitMQ.CreateUserQueue(userID, false)
+	if err := validate(userID, typeMessage); err != nil {
+		logutils.Error("User ID notification rejected", err, logutils.Fields{"user_id": userID, "type": typeMessage.String()})
+		typeLabel := typeMessage.String()
+		if errors.Is(err, entity.ErrUnknownType) {
+			typeLabel = metrics.InvalidType
+		}
+		metrics.Observe(metrics.Notifications, metrics.NotifyDuration, start, typeLabel, notifyOutcome(err))
+		span.SetAttributes(attribute.String("rote.outcome", notifyOutcome(err)))
+		tracing.End(span, err)
+		settle(err)
+		return err
+	}
+
+	if n.aggregator != nil {
+		aggregated, err := n.aggregator.AddWithSettle(ctx, userID, typeMessage, expiry, settle)
+		if aggregated {
+			outcome := "aggregated"
+			if err != nil {
+				outcome = metrics.OutcomeError
+			}
+			metrics.Observe(metrics.Notifications, metrics.NotifyDuration, start, typeMessage.String(), outcome)
+			span.SetAttributes(attribute.String("rote.outcome", outcome))
+			tracing.End(span, err)
+			return err
+		}
+	}
+
+	err := n.send(ctx, userID, typeMessage, n.text(typeMessage).Message, expiry)
+	settle(err)
+	metrics.Observe(metrics.Notifications, metrics.NotifyDuration, start, typeMessage.String(), notifyOutcome(err))
+	span.SetAttributes(attribute.String("rote.outcome", notifyOutcome(err)))
+	tracing.End(span, err)
+
+	return err
+}
+
+// notifyOutcome is the outcome label of a notification, telling throttled ones apart from failures
+func notifyOutcome(err error) string {
+	switch {
+	case errors.Is(err, ratelimit.ErrCollapsed):
+		return "collapsed"
+	case errors.Is(err, ratelimit.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, rabbitmq.ErrInvalidUserID), errors.Is(err, entity.ErrUnknownType):
+		return "invalid"
+	default:
+		return metrics.Outcome(err)
+	}
+}
 
-	body := Body{
-		Title:       typeMessage.String(),
-		Description: typeMessage.GetNotifyTypeMessage(),
+// validate rejects user IDs unsafe in a queue name or routing key and unregistered types,
+// the error wraps rabbitmq.ErrInvalidUserID or entity.ErrUnknownType
+func validate(userID string, typeMessage entity.NotifyTypeMessage) error {
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		return err
 	}
 
-	token, err := n.jwt.GenerateToken(body.String(), n.env.JwtIssuer, n.env.JwtAudience, n.env.JwtSubject)
+	return typeMessage.Validate()
+}
+
+// NotifyTransaction notifies the user ID after checking the transaction lifecycle of the correlation ID.
+// The transition is reserved before sending so concurrent notifications of the transaction are checked
+// against it, the transaction only moves once the notification is sent so a failed one can be retried.
+// An aggregated type moves it when its summary is flushed.
+func (n *NotificationsUserId) NotifyTransaction(ctx context.Context, userID, correlationID string, typeMessage entity.NotifyTypeMessage) error {
+	return n.NotifyTransactionWithExpiry(ctx, userID, correlationID, typeMessage, 0)
+}
+
+// NotifyTransactionWithExpiry notifies the transaction with a validity overriding the one of the type,
+// a zero expiry keeps the validity of the type
+func (n *NotificationsUserId) NotifyTransactionWithExpiry(ctx context.Context, userID, correlationID string, typeMessage entity.NotifyTypeMessage, expiry time.Duration) error {
+	if err := validate(userID, typeMessage); err != nil {
+		logutils.Error("Transaction notification rejected", err, logutils.Fields{"user_id": userID, "correlation_id": correlationID})
+		return err
+	}
+
+	reservation, err := n.transactions.Reserve(correlationID, typeMessage)
+	if err != nil {
+		logutils.Error("Transaction notification rejected", err, logutils.Fields{"user_id": userID, "correlation_id": correlationID})
+		return err
+	}
+	if reservation.State.Flagged {
+		logutils.Warn("Sending flagged transaction notification", logutils.Fields{"user_id": userID, "correlation_id": correlationID, "type": typeMessage.String()})
+	}
+
+	return n.notifyUserId(WithCorrelationID(ctx, correlationID), userID, typeMessage, expiry, func(err error) {
+		if err != nil {
+			reservation.Rollback()
+			return
+		}
+		reservation.Commit()
+	})
+}
+
+// Aggregates reports whether the notifications of the type are collected into a summary sent later
+func (n *NotificationsUserId) Aggregates(typeMessage entity.NotifyTypeMessage) bool {
+	return n.aggregator != nil && n.aggregator.Aggregates(typeMessage)
+}
+
+// Transactions returns the lifecycle tracker of the transactions, see transaction.PruneEvery
+func (n *NotificationsUserId) Transactions() *transaction.Tracker {
+	return n.transactions
+}
+
+// TransactionState returns the current lifecycle state of the correlation ID
+func (n *NotificationsUserId) TransactionState(correlationID string) (transaction.State, bool) {
+	return n.transactions.State(correlationID)
+}
+
+// send runs the notification through the interceptors
+func (n *NotificationsUserId) send(ctx context.Context, userID string, typeMessage entity.NotifyTypeMessage, description string, expiry time.Duration) error {
+	return n.handler(ctx, &Envelope{
+		UserID: userID,
+		Type:   typeMessage,
+		Body: Body{
+			Title:       n.text(typeMessage).Title,
+			Description: description,
+		},
+		Expiry:        expiry,
+		CorrelationID: CorrelationID(ctx),
+		Headers:       map[string]string{},
+	})
+}
+
+// dispatch publishes the envelope through the rate limiter, it ends the interceptor chain
+func (n *NotificationsUserId) dispatch(ctx context.Context, envelope *Envelope) error {
+	if n.limiter == nil {
+		return n.notify(ctx, envelope)
+	}
+
+	err := n.limiter.Do(ctx, envelope.UserID, envelope.Type.String(), func(ctx context.Context) error {
+		return n.notify(ctx, envelope)
+	})
+	if err != nil {
+		logutils.Warn("User ID notification throttled", logutils.Fields{"user_id": envelope.UserID, "type": envelope.Type.String(), "error": err.Error()})
+	}
+
+	return err
+}
+
+// RateLimitStats returns the rate limiter counters
+func (n *NotificationsUserId) RateLimitStats() ratelimit.Stats {
+	if n.limiter == nil {
+		return ratelimit.Stats{}
+	}
+
+	return n.limiter.Stats()
+}
+
+// notify signs and publishes the envelope, a zero expiry uses the one of the type
+func (n *NotificationsUserId) notify(ctx context.Context, envelope *Envelope) error {
+	userID, typeMessage, body, expiry := envelope.UserID, envelope.Type, envelope.Body, envelope.Expiry
+
+	_, declareSpan := tracing.Tracer().Start(ctx, "declare")
+	err := n.RabbitMQ.CreateUserQueue(userID, false)
+	tracing.End(declareSpan, err)
+	if err != nil {
+		return err
+	}
+
+	if expiry <= 0 {
+		expiry = n.expiry(typeMessage)
+	}
+	createdAt := time.Now()
+
+	_, signSpan := tracing.Tracer().Start(ctx, "sign")
+	token, err := n.jwt.GenerateTokenWithExpiry(body.String(), n.env.JwtIssuer, n.env.JwtAudience, n.env.JwtSubject, expiry)
+	tracing.End(signSpan, err)
 	if err != nil {
 		logutils.Error("Failed to generate a JWT token", err, nil)
-		return
+		return err
 	}
 
-	message := rabbitmq.Message{
-		Type:       typeMessage.String(),
-		UserID:     userID,
-		RoutingKey: fmt.Sprintf("user.%s.%s", userID, typeMessage.String()),
-		Body:       []byte(token),
+	message, err := newMessage(userID, typeMessage.String(), envelope.CorrelationID, token, expiry)
+	if err != nil {
+		logutils.Error("Failed to encode the envelope", err, nil)
+		return err
+	}
+	for key, value := range envelope.Headers {
+		message.Headers[key] = value
 	}
 
+	// The consumer continues the trace from the publish span carried in the headers
+	publishCtx, publishSpan := tracing.Tracer().Start(ctx, "publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("rote.message_id", message.ID)))
+	tracing.Inject(publishCtx, message.Headers)
 	err = n.RabbitMQ.PublishMessage(message)
+	tracing.End(publishSpan, err)
+	auditErr := n.record(ctx, message, typeMessage, err)
 	if err != nil {
 		logutils.Error("Failed to publish a message", err, nil)
-		return
+		return errors.Join(err, auditErr)
 	}
+	envelope.sent = true
 
 	logutils.Info("User ID notified", logutils.Fields{"user_id": userID, "type": typeMessage.GetNotifyTypeMessage()})
+
+	// The entry is written once published, so a failed publish leaves no entry and its retry no duplicate
+	if n.inbox != nil {
+		err = n.inbox.Add(ctx, inbox.Entry{
+			ID:          message.ID,
+			UserID:      userID,
+			Type:        typeMessage,
+			Title:       body.Title,
+			Description: body.Description,
+			CreatedAt:   createdAt,
+			ExpiresAt:   createdAt.Add(expiry),
+		})
+		if err != nil {
+			logutils.Error("Failed to store the notification in the inbox", err, logutils.Fields{"user_id": userID, "id": message.ID})
+		}
+	}
+
+	n.deliver(ctx, channel.Notification{
+		UserID:      userID,
+		Type:        typeMessage,
+		Title:       body.Title,
+		Description: body.Description,
+		Token:       token,
+		Envelope:    message.Body,
+	})
+
+	// The message is published, a failed audit append, inbox write or channel must not make the caller send it again
+	return nil
+}
+
+// record appends the outcome of publishing the message to the audit log
+func (n *NotificationsUserId) record(ctx context.Context, message rabbitmq.Message, typeMessage entity.NotifyTypeMessage, publishErr error) error {
+	if n.audit == nil {
+		return nil
+	}
+
+	record := audit.Record{
+		UserID:      message.UserID,
+		Type:        typeMessage,
+		MessageID:   message.ID,
+		PayloadHash: audit.HashPayload(message.Body),
+		Outcome:     audit.OutcomePublished,
+	}
+	if publishErr != nil {
+		record.Outcome = audit.OutcomeFailed
+		record.Error = publishErr.Error()
+	}
+
+	return n.appendAudit(ctx, record)
+}
+
+// appendAudit appends the record to the audit log and counts the outcome in metrics.AuditAppends,
+// a failure after publishing is only reported there and in the logs
+func (n *NotificationsUserId) appendAudit(ctx context.Context, record audit.Record) error {
+	_, err := n.audit.Append(ctx, record)
+	metrics.AuditAppends.WithLabelValues(metrics.Outcome(err)).Inc()
+	if err != nil {
+		logutils.Error("Failed to append to the audit log", err, logutils.Fields{"user_id": record.UserID, "id": record.MessageID})
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// deliver sends the notification to every channel accepting its type in the background,
+// their errors are logged since the notification is already published
+func (n *NotificationsUserId) deliver(ctx context.Context, notification channel.Notification) {
+	// The deliveries outlive the request but keep its trace
+	ctx = context.WithoutCancel(ctx)
+	for _, ch := range n.channels {
+		if !ch.Accepts(notification.Type) || !n.channelAllowed(notification.Type, ch.Name()) {
+			continue
+		}
+
+		n.deliveries.Add(1)
+		go func(ch channel.Channel) {
+			defer n.deliveries.Done()
+			ctx, cancel := context.WithTimeout(ctx, DeliveryTimeout)
+			defer cancel()
+
+			if err := ch.Deliver(ctx, notification); err != nil {
+				logutils.Error("Failed to deliver a notification", err, logutils.Fields{"channel": ch.Name(), "user_id": notification.UserID})
+			}
+		}(ch)
+	}
+}
+
+// Inbox returns the inbox store, nil when WithInbox was not used
+func (n *NotificationsUserId) Inbox() inbox.Store {
+	return n.inbox
+}
+
+// Health returns the liveness and readiness checks of the broker and the signing key,
+// the readiness fails when nothing was published for maxPublishAge unless it is 0
+func (n *NotificationsUserId) Health(maxPublishAge time.Duration) *health.Health {
+	h := health.NewHealth(health.DefaultTimeout)
+	h.AddNotifier(n.RabbitMQ, n.jwt, maxPublishAge)
+	return h
 }
 
 // DeleteNotificationsUserId deletes the user ID
-func (n *NotificationsUserId) DeleteNotificationsUserId(ctx context.Context, userID string) {
-	n.RabbitMQ.DeleteUserQueue(userID)
+func (n *NotificationsUserId) DeleteNotificationsUserId(ctx context.Context, userID string) error {
+	if err := rabbitmq.ValidateUserID(userID); err != nil {
+		return err
+	}
+
+	return n.RabbitMQ.DeleteUserQueue(userID)
 }
 
-// CloseNotificationsUserId closes the RabbitMQ connection
+// CloseNotificationsUserId waits for the channel deliveries, then closes the inbox, the audit log and the RabbitMQ connection
 func (n *NotificationsUserId) CloseNotificationsUserId() {
+	if n.aggregator != nil {
+		if err := n.aggregator.Flush(context.Background()); err != nil {
+			logutils.Error("Failed to flush aggregated notifications", err, nil)
+		}
+	}
+	// The collapsed notifications are sent before the broker is closed
+	if n.limiter != nil {
+		if err := n.limiter.Close(); err != nil {
+			logutils.Error("Failed to send collapsed notifications", err, nil)
+		}
+	}
+	n.deliveries.Wait()
+	if n.inbox != nil {
+		if err := n.inbox.Close(); err != nil {
+			logutils.Error("Failed to close the inbox", err, nil)
+		}
+	}
+	if n.audit != nil {
+		if err := n.audit.Close(); err != nil {
+			logutils.Error("Failed to close the audit log", err, nil)
+		}
+	}
 	n.RabbitMQ.CloseRabbitMQ()
 }
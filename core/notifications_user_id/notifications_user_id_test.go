@@ -0,0 +1,157 @@
+package notifications_user_id
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/aggregator"
+	"github.com/Mona-bele/rote-notify/core/catalog"
+	"github.com/Mona-bele/rote-notify/core/channel"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/transaction"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingChannel fails every delivery once released
+type blockingChannel struct {
+	release   chan struct{}
+	delivered chan channel.Notification
+}
+
+func (c *blockingChannel) Name() string { return "blocking" }
+
+func (c *blockingChannel) Accepts(entity.NotifyTypeMessage) bool { return true }
+
+func (c *blockingChannel) Deliver(ctx context.Context, notification channel.Notification) error {
+	<-c.release
+	c.delivered <- notification
+	return errors.New("provider is down")
+}
+
+func TestDeliver(t *testing.T) {
+	// Arrange
+	ch := &blockingChannel{release: make(chan struct{}), delivered: make(chan channel.Notification, 1)}
+	n := &NotificationsUserId{channels: []channel.Channel{ch}}
+	ctx, cancel := context.WithCancel(context.Background())
+	// Act
+	start := time.Now()
+	n.deliver(ctx, channel.Notification{UserID: "1", Type: entity.DEPOSIT})
+	cancel()
+	returned := time.Since(start)
+	close(ch.release)
+	n.deliveries.Wait()
+	// Assert, the caller does not wait and the delivery outlives its context
+	assert.Less(t, returned, time.Second)
+	assert.Equal(t, "1", (<-ch.delivered).UserID)
+}
+
+func TestNotifyUserIdRejectedType(t *testing.T) {
+	// Arrange
+	n := &NotificationsUserId{}
+	before := testutil.ToFloat64(metrics.Notifications.WithLabelValues(metrics.InvalidType, "invalid"))
+	// Act
+	err := n.NotifyUserId(context.Background(), "1", "made_up_type")
+	// Assert, the rejected type does not become a label value
+	assert.ErrorIs(t, err, entity.ErrUnknownType)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.Notifications.WithLabelValues(metrics.InvalidType, "invalid")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.Notifications.WithLabelValues("made_up_type", "invalid")))
+}
+
+func TestRecallNotificationMetrics(t *testing.T) {
+	// Arrange
+	n := &NotificationsUserId{}
+	before := testutil.ToFloat64(metrics.Notifications.WithLabelValues(entity.RecallType, metrics.OutcomeError))
+	// Act
+	err := n.RecallNotification(context.Background(), "message-1", "sent by mistake")
+	// Assert, the recall is counted although it skips the interceptors
+	assert.ErrorIs(t, err, ErrRecallUnavailable)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.Notifications.WithLabelValues(entity.RecallType, metrics.OutcomeError)))
+}
+
+func TestNotifyTransactionConcurrent(t *testing.T) {
+	// Arrange, both terminal types follow PROCESS but only one may be sent
+	var mu sync.Mutex
+	var sent []entity.NotifyTypeMessage
+	n := &NotificationsUserId{
+		transactions: transaction.NewTracker(transaction.ModeReject),
+		handler: func(ctx context.Context, envelope *Envelope) error {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, envelope.Type)
+			return nil
+		},
+	}
+	assert.Nil(t, n.NotifyTransaction(context.Background(), "1", "tx-1", entity.DEPOSIT_PROCESS))
+	// Act
+	errs := make(chan error, 2)
+	for _, typeMessage := range []entity.NotifyTypeMessage{entity.DEPOSIT_SUCCESS, entity.DEPOSIT_CANCEL} {
+		go func() { errs <- n.NotifyTransaction(context.Background(), "1", "tx-1", typeMessage) }()
+	}
+	first, second := <-errs, <-errs
+	// Assert
+	assert.True(t, (first == nil) != (second == nil))
+	assert.ErrorIs(t, errors.Join(first, second), transaction.ErrInvalidTransition)
+	assert.Len(t, sent, 2)
+	state, _ := n.TransactionState("tx-1")
+	assert.Equal(t, sent[1], state.Current)
+}
+
+func TestNotifyTransactionRollback(t *testing.T) {
+	// Arrange
+	failing := true
+	n := &NotificationsUserId{
+		transactions: transaction.NewTracker(transaction.ModeReject),
+		handler: func(ctx context.Context, envelope *Envelope) error {
+			if failing {
+				return errors.New("broker is down")
+			}
+			return nil
+		},
+	}
+	// Act
+	failedErr := n.NotifyTransaction(context.Background(), "1", "tx-1", entity.TRANSFER_PROCESS)
+	_, tracked := n.TransactionState("tx-1")
+	failing = false
+	retryErr := n.NotifyTransaction(context.Background(), "1", "tx-1", entity.TRANSFER_PROCESS)
+	state, _ := n.TransactionState("tx-1")
+	// Assert, the failed send does not move the transaction so it can be retried
+	assert.NotNil(t, failedErr)
+	assert.False(t, tracked)
+	assert.Nil(t, retryErr)
+	assert.Equal(t, entity.TRANSFER_PROCESS, state.Current)
+}
+
+func TestNotifyTransactionAggregated(t *testing.T) {
+	// Arrange
+	c, err := catalog.Parse([]byte(`{"version": 1, "types": {"deposit_process": {"texts": {"en": {"message": "Your deposit is on its way"}}}}}`), catalog.FormatJSON)
+	assert.Nil(t, err)
+	var sent []*Envelope
+	n := &NotificationsUserId{
+		catalog:      c,
+		transactions: transaction.NewTracker(transaction.ModeReject),
+		handler: func(ctx context.Context, envelope *Envelope) error {
+			sent = append(sent, envelope)
+			return nil
+		},
+	}
+	WithAggregation(map[entity.NotifyTypeMessage]aggregator.Rule{entity.DEPOSIT_PROCESS: {Window: time.Hour}})(n)
+	// Act
+	notifyErr := n.NotifyTransaction(context.Background(), "1", "tx-1", entity.DEPOSIT_PROCESS)
+	_, trackedBeforeFlush := n.TransactionState("tx-1")
+	flushErr := n.aggregator.Flush(context.Background())
+	state, trackedAfterFlush := n.TransactionState("tx-1")
+	// Assert, the transaction moves once the summary rendered from the catalog is sent
+	assert.Nil(t, notifyErr)
+	assert.False(t, trackedBeforeFlush)
+	assert.Nil(t, flushErr)
+	assert.True(t, trackedAfterFlush)
+	assert.Equal(t, entity.DEPOSIT_PROCESS, state.Current)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Your deposit is on its way", sent[0].Body.Description)
+}
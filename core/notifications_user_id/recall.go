@@ -0,0 +1,106 @@
+package notifications_user_id
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/audit"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/inbox"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRecallUnavailable is returned when recalling without an inbox to find the notification
+var ErrRecallUnavailable = errors.New("recall needs an inbox, see WithInbox")
+
+// RecallNotification publishes a signed tombstone on the routing key of the user of the notification,
+// so consumers and gateways can hide the original, then marks it recalled in the inbox.
+// The entry is marked after publishing so a failed publish can be retried.
+// The tombstone is not a notification envelope so it skips the interceptors,
+// it is traced and counted in metrics.Notifications with the recall type instead.
+func (n *NotificationsUserId) RecallNotification(ctx context.Context, messageID, reason string) error {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "RecallNotification", trace.WithAttributes(
+		attribute.String("rote.message_id", messageID),
+	))
+
+	err := n.recall(ctx, messageID, reason)
+	metrics.Observe(metrics.Notifications, metrics.NotifyDuration, start, entity.RecallType, notifyOutcome(err))
+	span.SetAttributes(attribute.String("rote.outcome", notifyOutcome(err)))
+	tracing.End(span, err)
+
+	return err
+}
+
+// recall publishes the tombstone, marks the entry and records the recall in the audit log
+func (n *NotificationsUserId) recall(ctx context.Context, messageID, reason string) error {
+	if n.inbox == nil {
+		return ErrRecallUnavailable
+	}
+
+	entry, err := n.inbox.Get(ctx, messageID)
+	if err == nil && entry.Recalled {
+		err = inbox.ErrRecalled
+	}
+	if err != nil {
+		logutils.Error("Failed to recall the notification", err, logutils.Fields{"id": messageID})
+		return err
+	}
+
+	// The tombstone is useless once the original expired
+	expiry := time.Until(entry.ExpiresAt)
+	if entry.ExpiresAt.IsZero() || expiry <= 0 {
+		expiry = n.expiry(entry.Type)
+	}
+
+	payload, err := json.Marshal(entity.Tombstone{RecalledID: messageID, Reason: reason})
+	if err != nil {
+		return err
+	}
+
+	token, err := n.jwt.GenerateTokenWithExpiry(string(payload), n.env.JwtIssuer, n.env.JwtAudience, n.env.JwtSubject, expiry)
+	if err != nil {
+		logutils.Error("Failed to generate a JWT token", err, nil)
+		return err
+	}
+
+	tombstone, err := newMessage(entry.UserID, entity.RecallType, CorrelationID(ctx), token, expiry)
+	if err != nil {
+		return err
+	}
+	tombstone.Headers[rabbitmq.RecalledIDHeader] = messageID
+	err = n.RabbitMQ.PublishMessage(tombstone)
+	if err != nil {
+		logutils.Error("Failed to publish the tombstone", err, logutils.Fields{"id": messageID})
+		return err
+	}
+
+	// A concurrent recall may have marked it first, its tombstone hides the same notification
+	if _, err := n.inbox.Recall(ctx, messageID, reason); err != nil && !errors.Is(err, inbox.ErrRecalled) {
+		logutils.Error("Failed to mark the notification recalled", err, logutils.Fields{"id": messageID})
+		return err
+	}
+
+	logutils.Info("Notification recalled", logutils.Fields{"user_id": entry.UserID, "id": messageID, "reason": reason})
+
+	if n.audit == nil {
+		return nil
+	}
+
+	// The recall is recorded against the original message, hashing the tombstone
+	return n.appendAudit(ctx, audit.Record{
+		UserID:      entry.UserID,
+		Type:        entry.Type,
+		MessageID:   messageID,
+		PayloadHash: audit.HashPayload(tombstone.Body),
+		Outcome:     audit.OutcomeRecalled,
+		Reason:      reason,
+	})
+}
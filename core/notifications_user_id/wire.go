@@ -0,0 +1,42 @@
+package notifications_user_id
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+)
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context whose notifications carry the correlation ID in their envelope
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID of the context, empty when it has none
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+// newMessage wraps the signed token in the envelope published to the queue of the user
+func newMessage(userID, typeMessage, correlationID, token string, expiry time.Duration) (rabbitmq.Message, error) {
+	id := rabbitmq.NewMessageID()
+	body, err := entity.NewNotifyType(id, typeMessage, userID, correlationID, token).Encode()
+	if err != nil {
+		return rabbitmq.Message{}, err
+	}
+
+	return rabbitmq.Message{
+		ID:          id,
+		Type:        typeMessage,
+		UserID:      userID,
+		RoutingKey:  rabbitmq.RoutingKey(userID, typeMessage),
+		Body:        body,
+		ContentType: entity.NotifyTypeContentType,
+		Expiration:  expiry,
+		Headers:     map[string]string{},
+	}, nil
+}
@@ -0,0 +1,31 @@
+package notifications_user_id
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMessage(t *testing.T) {
+	// Arrange
+	ctx := WithCorrelationID(context.Background(), "tx-1")
+	// Act
+	message, err := newMessage("1", entity.DEPOSIT.String(), CorrelationID(ctx), "signed.jwt.token", time.Hour)
+	envelope, decodeErr := entity.DecodeNotifyType(message.Body)
+	// Assert
+	assert.Nil(t, err)
+	assert.Nil(t, decodeErr)
+	assert.Equal(t, "user.1.deposit", message.RoutingKey)
+	assert.Equal(t, entity.NotifyTypeContentType, message.ContentType)
+	assert.Equal(t, time.Hour, message.Expiration)
+	assert.Equal(t, entity.NotifyTypeVersion, envelope.Version)
+	assert.Equal(t, message.ID, envelope.ID)
+	assert.Equal(t, "deposit", envelope.Type)
+	assert.Equal(t, "1", envelope.UserID)
+	assert.Equal(t, "tx-1", envelope.CorrelationID)
+	assert.Equal(t, "signed.jwt.token", envelope.Body)
+	assert.Empty(t, CorrelationID(context.Background()))
+}
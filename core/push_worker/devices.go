@@ -0,0 +1,104 @@
+package push_worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Mona-bele/rote-notify/core/devices"
+)
+
+// Device struct
+type Device struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+}
+
+// DeviceStore looks up and removes the device tokens of a user
+type DeviceStore interface {
+	Devices(ctx context.Context, userID string) ([]Device, error)
+	RemoveDevice(ctx context.Context, userID, token string) error
+}
+
+// MemoryDeviceStore struct
+type MemoryDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string][]Device
+}
+
+// NewMemoryDeviceStore creates a new MemoryDeviceStore instance
+func NewMemoryDeviceStore() *MemoryDeviceStore {
+	return &MemoryDeviceStore{devices: make(map[string][]Device)}
+}
+
+// AddDevice adds a device to the user
+func (s *MemoryDeviceStore) AddDevice(userID string, device Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.devices[userID] {
+		if d.Token == device.Token {
+			return
+		}
+	}
+	s.devices[userID] = append(s.devices[userID], device)
+}
+
+// Devices returns the devices of the user
+func (s *MemoryDeviceStore) Devices(ctx context.Context, userID string) ([]Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Device(nil), s.devices[userID]...), nil
+}
+
+// RemoveDevice removes the device token from the user
+func (s *MemoryDeviceStore) RemoveDevice(ctx context.Context, userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := s.devices[userID][:0]
+	for _, d := range s.devices[userID] {
+		if d.Token != token {
+			devices = append(devices, d)
+		}
+	}
+	s.devices[userID] = devices
+
+	return nil
+}
+
+// RegistryDeviceStore sends to the active devices of a devices.Registry
+type RegistryDeviceStore struct {
+	registry devices.Registry
+}
+
+// NewRegistryDeviceStore creates a new RegistryDeviceStore instance
+func NewRegistryDeviceStore(registry devices.Registry) *RegistryDeviceStore {
+	return &RegistryDeviceStore{registry: registry}
+}
+
+// Devices returns the active devices of the user
+func (s *RegistryDeviceStore) Devices(ctx context.Context, userID string) ([]Device, error) {
+	registered, err := s.registry.Devices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Device, 0, len(registered))
+	for _, d := range registered {
+		result = append(result, Device{Platform: d.Platform, Token: d.Token})
+	}
+
+	return result, nil
+}
+
+// RemoveDevice unregisters the device token, a token already gone is not an error
+func (s *RegistryDeviceStore) RemoveDevice(ctx context.Context, userID, token string) error {
+	err := s.registry.Unregister(ctx, userID, token)
+	if errors.Is(err, devices.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
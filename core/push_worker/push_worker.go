@@ -0,0 +1,344 @@
+package push_worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/notifications_user_id"
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/push"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/Mona-bele/rote-notify/pkg/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Queue is the durable queue of the push workers, bound to the messages of every user so the
+	// gateways consuming the user queues keep getting them
+	Queue = "notifications_push"
+	// DevicesHeader restricts a retried message to the tokens of the devices that failed
+	DevicesHeader = "x-push-devices"
+	// RetriedHeader marks a message already retried, a second failure dead-letters it
+	RetriedHeader = "x-push-retried"
+)
+
+var (
+	// ErrNoProvider is returned when no provider is configured for the device platform
+	ErrNoProvider = errors.New("no push provider for platform")
+	// ErrInvalidMessage is wrapped by the errors of a message that cannot be decoded or verified
+	ErrInvalidMessage = errors.New("invalid push message")
+)
+
+// Worker struct
+type Worker struct {
+	env       *env.Env
+	RabbitMQ  *rabbitmq.RabbitMQ
+	jwt       *jwt.JWT
+	devices   DeviceStore
+	providers map[string]push.Provider
+	// deadLetter moves a delivery failing for good out of Queue
+	deadLetter func(delivery amqp.Delivery, reason string) error
+	// republish puts a copy of a delivery back in a queue with the headers added and acks it
+	republish func(delivery amqp.Delivery, queueName string, headers amqp.Table) error
+}
+
+// deviceError is the failure of a push to one device, a retry goes to the devices that failed
+type deviceError struct {
+	token string
+	err   error
+}
+
+func (e *deviceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deviceError) Unwrap() error {
+	return e.err
+}
+
+// NewWorker creates a new Worker instance, providers are keyed by device platform
+func NewWorker(env *env.Env, rmq *rabbitmq.RabbitMQ, j *jwt.JWT, devices DeviceStore, providers map[string]push.Provider) *Worker {
+	return &Worker{
+		env:        env,
+		RabbitMQ:   rmq,
+		jwt:        j,
+		devices:    devices,
+		providers:  providers,
+		deadLetter: rmq.DeadLetter,
+		republish:  rmq.Republish,
+	}
+}
+
+// NewWorkerFromEnv creates a new Worker instance connected to RabbitMQ
+func NewWorkerFromEnv(env *env.Env, devices DeviceStore, providers map[string]push.Provider) (*Worker, error) {
+	j, err := jwt.NewJWTFromEnv(env)
+	if err != nil {
+		logutils.Error("Failed to create a new JWT instance", err, nil)
+		return nil, err
+	}
+
+	return NewWorker(env, rabbitmq.NewRabbitMQ(env), j, devices, providers), nil
+}
+
+// Run consumes Queue with concurrency handlers until the context is done or the consumer stops, then waits
+// for the deliveries in flight. Each delivery is acked once handled, the devices with a retryable failure
+// get one retry and the other failures are moved to rabbitmq.DeadLetterQueue.
+// The messages of every user go through Queue, so devices registered while running get them too.
+func (w *Worker) Run(ctx context.Context, concurrency int) error {
+	if err := w.RabbitMQ.CreateBoundQueue(Queue, rabbitmq.RoutingKey("*", "*")); err != nil {
+		return err
+	}
+
+	consumerTag := "push-worker-" + rabbitmq.NewMessageID()
+	msgs := w.RabbitMQ.ConsumeQueue(Queue, consumerTag, false)
+
+	// Canceling closes the deliveries channel, the handlers read it until then
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := w.RabbitMQ.CancelConsumer(consumerTag); err != nil {
+				logutils.Error("Failed to cancel the push consumer", err, nil)
+			}
+		case <-stopped:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				w.process(ctx, msg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() == nil {
+		return errors.New("push consumer stopped")
+	}
+
+	return nil
+}
+
+// process handles and settles a delivery, one read once the context is done is left to the broker to requeue
+func (w *Worker) process(ctx context.Context, msg amqp.Delivery) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	// A push already shown on the device cannot be taken back
+	if msg.Type == entity.RecallType {
+		if err := msg.Ack(false); err != nil {
+			logutils.Error("Failed to ack a tombstone", err, logutils.Fields{"id": msg.MessageId})
+		}
+		return
+	}
+
+	userID, err := deliveryUserID(msg)
+	if err == nil {
+		err = w.HandleDelivery(ctx, userID, msg)
+	} else {
+		err = fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+	if err != nil {
+		logutils.Error("Failed to deliver a push notification", err, logutils.Fields{"user_id": userID, "id": msg.MessageId})
+	}
+	if err := w.settle(msg, err); err != nil {
+		logutils.Error("Failed to settle a push notification", err, logutils.Fields{"user_id": userID, "id": msg.MessageId})
+	}
+}
+
+// deliveryUserID reads the user of a delivery from its routing key, a retried one keeps it in a header
+func deliveryUserID(delivery amqp.Delivery) (string, error) {
+	routingKey := delivery.RoutingKey
+	if original, ok := delivery.Headers[rabbitmq.OriginalRoutingKeyHeader].(string); ok {
+		routingKey = original
+	}
+	userID, _, err := rabbitmq.ParseRoutingKey(routingKey)
+
+	return userID, err
+}
+
+// settle acks a handled delivery, retries once the devices with a retryable failure
+// and dead-letters the other failures
+func (w *Worker) settle(delivery amqp.Delivery, err error) error {
+	switch {
+	case err == nil:
+		return delivery.Ack(false)
+	case Retryable(err) && delivery.Headers[RetriedHeader] != true:
+		headers := amqp.Table{RetriedHeader: true}
+		if tokens := failedDevices(err); len(tokens) > 0 {
+			headers[DevicesHeader] = tokens
+		}
+		return w.republish(delivery, Queue, headers)
+	default:
+		return w.deadLetter(delivery, err.Error())
+	}
+}
+
+// failedDevices returns the tokens of the devices with a retryable failure,
+// none when a retryable failure is not tied to a device so the retry goes to every device
+func failedDevices(err error) []interface{} {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	var tokens []interface{}
+	for _, e := range errs {
+		if !Retryable(e) {
+			continue
+		}
+		var deviceErr *deviceError
+		if !errors.As(e, &deviceErr) {
+			return nil
+		}
+		tokens = append(tokens, deviceErr.token)
+	}
+
+	return tokens
+}
+
+// Retryable reports whether handling the message may succeed later. Invalid messages, missing providers
+// and provider rejections other than 429 and 5xx are permanent, a joined error is retryable when one is.
+func Retryable(err error) bool {
+	if errors.Is(err, ErrInvalidMessage) {
+		return false
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if Retryable(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var providerErr *push.ProviderError
+	switch {
+	case errors.Is(err, ErrNoProvider):
+		return false
+	case errors.As(err, &providerErr):
+		return providerErr.StatusCode == http.StatusTooManyRequests || providerErr.StatusCode >= http.StatusInternalServerError
+	default:
+		return true
+	}
+}
+
+// HandleDelivery handles the delivery in a span continuing the trace of its publisher,
+// the type of a legacy body is read from the delivery and a retry only goes to the devices of DevicesHeader
+func (w *Worker) HandleDelivery(ctx context.Context, userID string, delivery amqp.Delivery) error {
+	ctx = tracing.ExtractDelivery(ctx, delivery)
+	ctx, span := tracing.Tracer().Start(ctx, "deliver", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("rote.user_id", userID),
+			attribute.String("rote.message_id", delivery.MessageId),
+		))
+
+	envelope, err := entity.DecodeNotifyType(delivery.Body)
+	if err == nil {
+		if envelope.Type == "" {
+			envelope.Type = delivery.Type
+		}
+		err = w.handle(ctx, userID, envelope, retryDevices(delivery))
+	} else {
+		err = fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+	tracing.End(span, err)
+
+	return err
+}
+
+// Handle verifies the signed body of the envelope and sends it to every device of the user
+func (w *Worker) Handle(ctx context.Context, userID string, message []byte) error {
+	envelope, err := entity.DecodeNotifyType(message)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	return w.handle(ctx, userID, envelope, nil)
+}
+
+// retryDevices returns the tokens of DevicesHeader, nil for every device
+func retryDevices(delivery amqp.Delivery) map[string]bool {
+	tokens, ok := delivery.Headers[DevicesHeader].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	only := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if token, ok := token.(string); ok {
+			only[token] = true
+		}
+	}
+
+	return only
+}
+
+// handle sends the envelope to the devices of the user, only to the ones of only when it is not nil
+func (w *Worker) handle(ctx context.Context, userID string, envelope entity.NotifyType, only map[string]bool) error {
+	token, err := w.jwt.ParseToken(envelope.Body, w.env.JwtIssuer, w.env.JwtAudience, w.env.JwtSubject)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	var body notifications_user_id.Body
+	if err := json.Unmarshal([]byte(w.jwt.GetPayload(token)), &body); err != nil {
+		logutils.Error("Failed to unmarshal the body", err, nil)
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	devices, err := w.devices.Devices(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// The title is the text of the catalog, apps route on the type
+	notification := push.Notification{
+		Title: body.Title,
+		Body:  body.Description,
+		Data:  map[string]string{"type": envelope.Type},
+	}
+
+	var errs []error
+	for _, device := range devices {
+		if body.DeviceToken != "" && body.DeviceToken != device.Token {
+			continue
+		}
+		if only != nil && !only[device.Token] {
+			continue
+		}
+
+		provider, ok := w.providers[device.Platform]
+		if !ok {
+			errs = append(errs, &deviceError{token: device.Token, err: fmt.Errorf("%w: %s", ErrNoProvider, device.Platform)})
+			continue
+		}
+
+		err := provider.Send(ctx, device.Token, notification)
+		if errors.Is(err, push.ErrUnregistered) {
+			logutils.Warn("Removing dead device token", logutils.Fields{"user_id": userID, "provider": provider.Name()})
+			errs = append(errs, w.devices.RemoveDevice(ctx, userID, device.Token))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, &deviceError{token: device.Token, err: err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,360 @@
+package push_worker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Mona-bele/rote-notify/core/devices"
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/Mona-bele/rote-notify/core/notifications_user_id"
+	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/push"
+	"github.com/Mona-bele/rote-notify/pkg/rabbitmq"
+	"github.com/Mona-bele/rote-notify/pkg/security/jwt"
+	"github.com/Mona-bele/rote-notify/pkg/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type received struct {
+	mu     sync.Mutex
+	tokens []string
+	bodies []string
+}
+
+func (r *received) add(token, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, token)
+	r.bodies = append(r.bodies, body)
+}
+
+func newTestWorker(t *testing.T, devices DeviceStore, providers map[string]push.Provider) (*Worker, *jwt.JWT, *env.Env) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	envWorker := &env.Env{JwtKid: "JWT_KID_1234", JwtIssuer: "issuer", JwtAudience: "audience", JwtSubject: "subject"}
+	j := jwt.NewJWT(privateKey, envWorker)
+
+	return NewWorker(envWorker, nil, j, devices, providers), j, envWorker
+}
+
+func TestWorkerHandle(t *testing.T) {
+	// Arrange
+	fcmReceived := &received{}
+	fcmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/rote/messages:send", r.URL.Path)
+		assert.Equal(t, "Bearer fcm-token", r.Header.Get("Authorization"))
+
+		var req fcmRequestTest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Message.Token == "android-dead" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			return
+		}
+		fcmReceived.add(req.Message.Token, req.Message.Notification.Body)
+		_, _ = w.Write([]byte(`{"name":"projects/rote/messages/1"}`))
+	}))
+	defer fcmServer.Close()
+
+	apnsReceived := &received{}
+	apnsServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 2, r.ProtoMajor)
+		assert.Equal(t, "com.rote.app", r.Header.Get("apns-topic"))
+
+		token := strings.TrimPrefix(r.URL.Path, "/3/device/")
+		if token == "ios-dead" {
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		apnsReceived.add(token, payload["aps"].(map[string]any)["alert"].(map[string]any)["body"].(string))
+	}))
+	apnsServer.EnableHTTP2 = true
+	apnsServer.StartTLS()
+	defer apnsServer.Close()
+
+	devices := NewMemoryDeviceStore()
+	devices.AddDevice("1", Device{Platform: push.PlatformAndroid, Token: "android-ok"})
+	devices.AddDevice("1", Device{Platform: push.PlatformAndroid, Token: "android-dead"})
+	devices.AddDevice("1", Device{Platform: push.PlatformIOS, Token: "ios-ok"})
+	devices.AddDevice("1", Device{Platform: push.PlatformIOS, Token: "ios-dead"})
+
+	worker, j, envWorker := newTestWorker(t, devices, map[string]push.Provider{
+		push.PlatformAndroid: push.NewFCM(push.FCMConfig{Endpoint: fcmServer.URL, ProjectID: "rote", Token: push.StaticToken("fcm-token")}),
+		push.PlatformIOS:     push.NewAPNs(push.APNsConfig{Endpoint: apnsServer.URL, Topic: "com.rote.app", Client: apnsServer.Client()}),
+	})
+
+	body := notifications_user_id.Body{Title: "deposit_success", Description: "Deposit completed"}
+	token, err := j.GenerateToken(body.String(), envWorker.JwtIssuer, envWorker.JwtAudience, envWorker.JwtSubject)
+	assert.Nil(t, err)
+
+	t.Run("Test Handle delivers and removes dead tokens", func(t *testing.T) {
+		// Act
+		err := worker.Handle(context.Background(), "1", []byte(token))
+		remaining, _ := devices.Devices(context.Background(), "1")
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"android-ok"}, fcmReceived.tokens)
+		assert.Equal(t, []string{"Deposit completed"}, fcmReceived.bodies)
+		assert.Equal(t, []string{"ios-ok"}, apnsReceived.tokens)
+		assert.Equal(t, []Device{
+			{Platform: push.PlatformAndroid, Token: "android-ok"},
+			{Platform: push.PlatformIOS, Token: "ios-ok"},
+		}, remaining)
+	})
+
+	t.Run("Test Handle reads the envelope", func(t *testing.T) {
+		// Arrange
+		envelope, err := entity.NewNotifyType("id-1", "deposit_success", "3", "tx-1", token).Encode()
+		assert.Nil(t, err)
+		devices.AddDevice("3", Device{Platform: push.PlatformAndroid, Token: "android-envelope"})
+		// Act
+		err = worker.Handle(context.Background(), "3", envelope)
+		// Assert
+		assert.Nil(t, err)
+		assert.Contains(t, fcmReceived.tokens, "android-envelope")
+	})
+
+	t.Run("Test Handle rejects a newer envelope", func(t *testing.T) {
+		// Act
+		err := worker.Handle(context.Background(), "1", []byte(`{"version":99,"body":"`+token+`"}`))
+		// Assert
+		assert.ErrorIs(t, err, entity.ErrUnsupportedVersion)
+	})
+
+	t.Run("Test Handle rejects an invalid token", func(t *testing.T) {
+		// Act
+		err := worker.Handle(context.Background(), "1", []byte("invalidToken"))
+		// Assert
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Test Handle reports a missing provider", func(t *testing.T) {
+		// Arrange
+		devices.AddDevice("2", Device{Platform: push.PlatformWeb, Token: "web"})
+		// Act
+		err := worker.Handle(context.Background(), "2", []byte(token))
+		// Assert
+		assert.ErrorIs(t, err, ErrNoProvider)
+	})
+}
+
+func TestWorkerHandleDelivery(t *testing.T) {
+	// Arrange
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	worker, j, envWorker := newTestWorker(t, NewMemoryDeviceStore(), nil)
+	body := notifications_user_id.Body{Title: "deposit_success", Description: "Deposit completed"}
+	token, err := j.GenerateToken(body.String(), envWorker.JwtIssuer, envWorker.JwtAudience, envWorker.JwtSubject)
+	assert.Nil(t, err)
+
+	ctx, upstream := tracing.Tracer().Start(context.Background(), "upstream")
+	headers := map[string]string{}
+	tracing.Inject(ctx, headers)
+	upstream.End()
+
+	table := amqp.Table{}
+	for key, value := range headers {
+		table[key] = value
+	}
+
+	t.Run("Test HandleDelivery continues the trace of the publisher", func(t *testing.T) {
+		// Act
+		err := worker.HandleDelivery(context.Background(), "1", amqp.Delivery{MessageId: "id-1", Headers: table, Body: []byte(token)})
+		// Assert
+		assert.Nil(t, err)
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "deliver", spans[1].Name)
+		assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+		assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	})
+}
+
+type fcmRequestTest struct {
+	Message struct {
+		Token        string `json:"token"`
+		Notification struct {
+			Body string `json:"body"`
+		} `json:"notification"`
+	} `json:"message"`
+}
+
+func TestRegistryDeviceStore(t *testing.T) {
+	// Arrange
+	registry, err := devices.NewBoltRegistry(filepath.Join(t.TempDir(), "devices.db"), 0)
+	assert.Nil(t, err)
+	defer registry.Close()
+	_, _ = registry.Register(context.Background(), "1", devices.Device{Platform: push.PlatformAndroid, Token: "phone", Locale: "pt-BR"})
+	_, _ = registry.Register(context.Background(), "1", devices.Device{Platform: push.PlatformWeb, Token: "browser"})
+	store := NewRegistryDeviceStore(registry)
+	// Act
+	removeErr := store.RemoveDevice(context.Background(), "1", "browser")
+	removeAgainErr := store.RemoveDevice(context.Background(), "1", "browser")
+	remaining, err := store.Devices(context.Background(), "1")
+	// Assert
+	assert.Nil(t, removeErr)
+	assert.Nil(t, removeAgainErr)
+	assert.Nil(t, err)
+	assert.Equal(t, []Device{{Platform: push.PlatformAndroid, Token: "phone"}}, remaining)
+}
+
+// recordingProvider records the notifications it sends
+type recordingProvider struct {
+	sent []push.Notification
+}
+
+func (p *recordingProvider) Name() string { return "recording" }
+
+func (p *recordingProvider) Send(ctx context.Context, token string, notification push.Notification) error {
+	p.sent = append(p.sent, notification)
+	return nil
+}
+
+func TestWorkerHandleType(t *testing.T) {
+	// Arrange
+	devices := NewMemoryDeviceStore()
+	devices.AddDevice("1", Device{Platform: push.PlatformAndroid, Token: "android"})
+	provider := &recordingProvider{}
+	worker, j, envWorker := newTestWorker(t, devices, map[string]push.Provider{push.PlatformAndroid: provider})
+	body := notifications_user_id.Body{Title: "Deposit received", Description: "Deposit completed"}
+	token, err := j.GenerateToken(body.String(), envWorker.JwtIssuer, envWorker.JwtAudience, envWorker.JwtSubject)
+	assert.Nil(t, err)
+	envelope, err := entity.NewNotifyType("id-1", "deposit_success", "1", "", token).Encode()
+	assert.Nil(t, err)
+	// Act
+	envelopeErr := worker.HandleDelivery(context.Background(), "1", amqp.Delivery{Body: envelope})
+	legacyErr := worker.HandleDelivery(context.Background(), "1", amqp.Delivery{Type: "deposit", Body: []byte(token)})
+	// Assert, the type comes from the envelope and the title is catalog text
+	assert.Nil(t, envelopeErr)
+	assert.Nil(t, legacyErr)
+	assert.Len(t, provider.sent, 2)
+	assert.Equal(t, "deposit_success", provider.sent[0].Data["type"])
+	assert.Equal(t, "deposit", provider.sent[1].Data["type"])
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "Test invalid message", err: fmt.Errorf("%w: %w", ErrInvalidMessage, errors.New("token is expired"))},
+		{name: "Test missing provider", err: fmt.Errorf("%w: web", ErrNoProvider)},
+		{name: "Test provider rejection", err: &push.ProviderError{StatusCode: http.StatusBadRequest}},
+		{name: "Test provider throttling", err: &push.ProviderError{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		{name: "Test provider outage", err: &push.ProviderError{StatusCode: http.StatusServiceUnavailable}, retryable: true},
+		{name: "Test network error", err: errors.New("connection reset"), retryable: true},
+		{name: "Test joined with a retryable error", err: errors.Join(fmt.Errorf("%w: web", ErrNoProvider), &push.ProviderError{StatusCode: http.StatusBadGateway}), retryable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.retryable, Retryable(tt.err))
+		})
+	}
+}
+
+// acknowledger records how deliveries are settled
+type acknowledger struct {
+	acked, requeued, rejected int
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	if requeue {
+		a.requeued++
+	} else {
+		a.rejected++
+	}
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestWorkerSettle(t *testing.T) {
+	// Arrange
+	ack := &acknowledger{}
+	var deadLettered []string
+	var retries []amqp.Table
+	worker := &Worker{
+		deadLetter: func(delivery amqp.Delivery, reason string) error {
+			deadLettered = append(deadLettered, reason)
+			return delivery.Ack(false)
+		},
+		republish: func(delivery amqp.Delivery, queueName string, headers amqp.Table) error {
+			assert.Equal(t, Queue, queueName)
+			retries = append(retries, headers)
+			return delivery.Ack(false)
+		},
+	}
+	outage := &push.ProviderError{Provider: "fcm", StatusCode: http.StatusServiceUnavailable, Reason: "UNAVAILABLE"}
+	devicesErr := errors.Join(&deviceError{token: "phone", err: outage}, &deviceError{token: "browser", err: fmt.Errorf("%w: web", ErrNoProvider)})
+	// Act
+	_ = worker.settle(amqp.Delivery{Acknowledger: ack}, nil)
+	_ = worker.settle(amqp.Delivery{Acknowledger: ack}, outage)
+	_ = worker.settle(amqp.Delivery{Acknowledger: ack}, devicesErr)
+	_ = worker.settle(amqp.Delivery{Acknowledger: ack, Headers: amqp.Table{RetriedHeader: true}}, outage)
+	_ = worker.settle(amqp.Delivery{Acknowledger: ack}, fmt.Errorf("%w: web", ErrNoProvider))
+	// Assert, only the device with a retryable failure is retried and nothing is requeued
+	assert.Equal(t, 0, ack.requeued)
+	assert.Equal(t, 0, ack.rejected)
+	assert.Equal(t, 5, ack.acked)
+	assert.Equal(t, []amqp.Table{
+		{RetriedHeader: true},
+		{RetriedHeader: true, DevicesHeader: []interface{}{"phone"}},
+	}, retries)
+	assert.Equal(t, []string{outage.Error(), "no push provider for platform: web"}, deadLettered)
+}
+
+func TestWorkerRetryDevices(t *testing.T) {
+	// Arrange
+	devices := NewMemoryDeviceStore()
+	devices.AddDevice("1", Device{Platform: push.PlatformAndroid, Token: "phone"})
+	devices.AddDevice("1", Device{Platform: push.PlatformAndroid, Token: "tablet"})
+	provider := &recordingProvider{}
+	worker, j, envWorker := newTestWorker(t, devices, map[string]push.Provider{push.PlatformAndroid: provider})
+	body := notifications_user_id.Body{Title: "Deposit received", Description: "Deposit completed"}
+	token, err := j.GenerateToken(body.String(), envWorker.JwtIssuer, envWorker.JwtAudience, envWorker.JwtSubject)
+	assert.Nil(t, err)
+	delivery := amqp.Delivery{
+		RoutingKey: Queue,
+		Type:       "deposit",
+		Body:       []byte(token),
+		Headers:    amqp.Table{rabbitmq.OriginalRoutingKeyHeader: "user.1.deposit", DevicesHeader: []interface{}{"tablet"}},
+	}
+	// Act
+	userID, userErr := deliveryUserID(delivery)
+	err = worker.HandleDelivery(context.Background(), userID, delivery)
+	// Assert, the retry skips the device that already got it
+	assert.Nil(t, userErr)
+	assert.Equal(t, "1", userID)
+	assert.Nil(t, err)
+	assert.Len(t, provider.sent, 1)
+}
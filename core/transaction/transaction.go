@@ -0,0 +1,291 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/core/entity"
+)
+
+const (
+	// DefaultRetention is how long the services remember a finished transaction
+	DefaultRetention = 24 * time.Hour
+	// DefaultPruneInterval is how often the services forget the finished transactions
+	DefaultPruneInterval = time.Hour
+)
+
+// Mode defines what happens to an out-of-order notification
+type Mode string
+
+const (
+	// ModeReject returns a TransitionError and keeps the current state
+	ModeReject Mode = "reject"
+	// ModeFlag logs the notification, marks the state as flagged and moves on
+	ModeFlag Mode = "flag"
+)
+
+var (
+	// ErrInvalidTransition is wrapped by every TransitionError
+	ErrInvalidTransition = errors.New("invalid transaction transition")
+	// ErrNotLifecycleType is returned for types that have no lifecycle
+	ErrNotLifecycleType = errors.New("notification type has no transaction lifecycle")
+	// ErrFamilyMismatch is returned when a transaction receives a type of another family
+	ErrFamilyMismatch = errors.New("notification type belongs to another transaction family")
+)
+
+// TransitionError struct
+type TransitionError struct {
+	CorrelationID string
+	From          entity.NotifyTypeMessage
+	To            entity.NotifyTypeMessage
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %s cannot follow %q for transaction %s", ErrInvalidTransition, e.To, e.From, e.CorrelationID)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// State struct
+type State struct {
+	CorrelationID string                   `json:"correlation_id"`
+	Family        entity.Family            `json:"family"`
+	Current       entity.NotifyTypeMessage `json:"current"`
+	Terminal      bool                     `json:"terminal"`
+	Flagged       bool                     `json:"flagged"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+}
+
+// Tracker struct
+type Tracker struct {
+	mode         Mode
+	now          func() time.Time
+	mu           sync.RWMutex
+	transactions map[string]*transaction
+}
+
+// transaction is the committed state of a transaction and the reservations still being sent
+type transaction struct {
+	state State
+	// committed is the sequence of the reservation of the state, 0 before the first commit
+	committed uint64
+	// sequence numbers the reservations in the order they were made
+	sequence uint64
+	pending  []*Reservation
+}
+
+// head is the state the next notification moves from, the one of the latest reservation
+func (tx *transaction) head() State {
+	if tx == nil {
+		return State{}
+	}
+	if n := len(tx.pending); n > 0 && tx.pending[n-1].sequence > tx.committed {
+		return tx.pending[n-1].State
+	}
+
+	return tx.state
+}
+
+// Reservation is a transition checked and held until the notification is sent, see Tracker.Reserve
+type Reservation struct {
+	tracker  *Tracker
+	sequence uint64
+	done     bool
+	// State is the state the transaction moves to once committed
+	State State
+}
+
+// NewTracker creates a new Tracker instance
+func NewTracker(mode Mode) *Tracker {
+	if mode == "" {
+		mode = ModeReject
+	}
+
+	return &Tracker{
+		mode:         mode,
+		now:          time.Now,
+		transactions: make(map[string]*transaction),
+	}
+}
+
+// Check returns the state the type would move the transaction to, without storing it
+func (t *Tracker) Check(correlationID string, typeMessage entity.NotifyTypeMessage) (State, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	state, _, err := t.next(correlationID, typeMessage)
+	return state, err
+}
+
+// Reserve checks the type against the transaction and holds the transition until it is committed or
+// rolled back, the next notifications are checked against it so two conflicting ones cannot both pass.
+// The state returned by State only moves once the reservation is committed.
+func (t *Tracker) Reserve(correlationID string, typeMessage entity.NotifyTypeMessage) (*Reservation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reservation, _, err := t.reserve(correlationID, typeMessage)
+	return reservation, err
+}
+
+// Advance moves the transaction to the type, according to the tracker mode
+func (t *Tracker) Advance(correlationID string, typeMessage entity.NotifyTypeMessage) (State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reservation, state, err := t.reserve(correlationID, typeMessage)
+	if err != nil {
+		return state, err
+	}
+	reservation.commit()
+
+	return reservation.State, nil
+}
+
+// reserve adds a reservation of the type, the caller holds the lock
+func (t *Tracker) reserve(correlationID string, typeMessage entity.NotifyTypeMessage) (*Reservation, State, error) {
+	state, outOfOrder, err := t.next(correlationID, typeMessage)
+	if err != nil {
+		return nil, state, err
+	}
+
+	tx, ok := t.transactions[correlationID]
+	if !ok {
+		tx = &transaction{}
+		t.transactions[correlationID] = tx
+	}
+	if outOfOrder {
+		logutils.Warn("Out-of-order transaction notification", logutils.Fields{"correlation_id": correlationID, "from": tx.head().Current.String(), "to": state.Current.String()})
+	}
+
+	tx.sequence++
+	reservation := &Reservation{tracker: t, sequence: tx.sequence, State: state}
+	tx.pending = append(tx.pending, reservation)
+
+	return reservation, state, nil
+}
+
+// next returns the state after the type and whether it is out of order, the caller holds the lock
+func (t *Tracker) next(correlationID string, typeMessage entity.NotifyTypeMessage) (State, bool, error) {
+	lifecycle, ok := typeMessage.GetLifecycle()
+	if !ok {
+		return State{}, false, fmt.Errorf("%w: %s", ErrNotLifecycleType, typeMessage)
+	}
+
+	state := t.transactions[correlationID].head()
+	if state.Family != "" && state.Family != lifecycle.Family {
+		return state, false, fmt.Errorf("%w: %s is %s, transaction %s is %s", ErrFamilyMismatch, typeMessage, lifecycle.Family, correlationID, state.Family)
+	}
+
+	next := lifecycle.Resolve(typeMessage)
+	outOfOrder := !lifecycle.CanTransition(state.Current, next)
+	if outOfOrder && t.mode == ModeReject {
+		return state, false, &TransitionError{CorrelationID: correlationID, From: state.Current, To: next}
+	}
+
+	return State{
+		CorrelationID: correlationID,
+		Family:        lifecycle.Family,
+		Current:       next,
+		Terminal:      lifecycle.IsTerminal(next),
+		Flagged:       state.Flagged || outOfOrder,
+		UpdatedAt:     t.now(),
+	}, outOfOrder, nil
+}
+
+// Commit moves the transaction to the state of the reservation, unless a later reservation was committed first
+func (r *Reservation) Commit() {
+	r.tracker.mu.Lock()
+	defer r.tracker.mu.Unlock()
+
+	r.commit()
+}
+
+// Rollback drops the reservation of a notification that was not sent,
+// the reservations made after it were checked against it and are kept
+func (r *Reservation) Rollback() {
+	r.tracker.mu.Lock()
+	defer r.tracker.mu.Unlock()
+
+	tx, ok := r.settle()
+	if ok && tx.committed == 0 && len(tx.pending) == 0 {
+		delete(r.tracker.transactions, r.State.CorrelationID)
+	}
+}
+
+// commit stores the state of the reservation, the caller holds the lock
+func (r *Reservation) commit() {
+	tx, ok := r.settle()
+	if !ok || r.sequence < tx.committed {
+		return
+	}
+	tx.state = r.State
+	tx.state.UpdatedAt = r.tracker.now()
+	tx.committed = r.sequence
+}
+
+// settle removes the reservation from the pending ones once, the caller holds the lock
+func (r *Reservation) settle() (*transaction, bool) {
+	tx, ok := r.tracker.transactions[r.State.CorrelationID]
+	if r.done || !ok {
+		return nil, false
+	}
+	r.done = true
+	tx.pending = slices.DeleteFunc(tx.pending, func(pending *Reservation) bool { return pending == r })
+
+	return tx, true
+}
+
+// State returns the current state of the transaction
+func (t *Tracker) State(correlationID string) (State, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tx, ok := t.transactions[correlationID]
+	if !ok || tx.committed == 0 {
+		return State{}, false
+	}
+
+	return tx.state, true
+}
+
+// Prune forgets terminal transactions not updated within the retention
+func (t *Tracker) Prune(retention time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pruned := 0
+	limit := t.now().Add(-retention)
+	for id, tx := range t.transactions {
+		if tx.state.Terminal && tx.state.UpdatedAt.Before(limit) && len(tx.pending) == 0 {
+			delete(t.transactions, id)
+			pruned++
+		}
+	}
+
+	return pruned
+}
+
+// PruneEvery prunes the tracker on every interval until the context is done
+func PruneEvery(ctx context.Context, t *Tracker, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if pruned := t.Prune(retention); pruned > 0 {
+				logutils.Info("Finished transactions pruned", logutils.Fields{"pruned": pruned})
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package transaction
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/core/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	t.Run("Test Advance rejects out-of-order notifications", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeReject)
+		// Act
+		_, errProcess := tracker.Advance("tx-1", entity.DEPOSIT_PROCESS)
+		_, errCancel := tracker.Advance("tx-1", entity.DEPOSIT_CANCEL)
+		_, errSuccess := tracker.Advance("tx-1", entity.DEPOSIT_SUCCESS)
+		state, ok := tracker.State("tx-1")
+		// Assert
+		assert.Nil(t, errProcess)
+		assert.Nil(t, errCancel)
+		assert.ErrorIs(t, errSuccess, ErrInvalidTransition)
+		var transitionErr *TransitionError
+		assert.True(t, errors.As(errSuccess, &transitionErr))
+		assert.Equal(t, entity.DEPOSIT_CANCEL, transitionErr.From)
+		assert.True(t, ok)
+		assert.Equal(t, entity.DEPOSIT_CANCEL, state.Current)
+		assert.True(t, state.Terminal)
+	})
+
+	t.Run("Test Check does not move the transaction", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeReject)
+		_, _ = tracker.Advance("tx-1", entity.DEPOSIT_PROCESS)
+		// Act
+		checked, err := tracker.Check("tx-1", entity.DEPOSIT_SUCCESS)
+		state, _ := tracker.State("tx-1")
+		_, errAgain := tracker.Check("tx-1", entity.DEPOSIT_SUCCESS)
+		// Assert
+		assert.Nil(t, err)
+		assert.Nil(t, errAgain)
+		assert.Equal(t, entity.DEPOSIT_SUCCESS, checked.Current)
+		assert.Equal(t, entity.DEPOSIT_PROCESS, state.Current)
+	})
+
+	t.Run("Test Reserve holds the transition until committed", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeReject)
+		_, _ = tracker.Advance("tx-1", entity.DEPOSIT_PROCESS)
+		// Act
+		reservation, err := tracker.Reserve("tx-1", entity.DEPOSIT_SUCCESS)
+		_, errConflict := tracker.Reserve("tx-1", entity.DEPOSIT_CANCEL)
+		reserved, _ := tracker.State("tx-1")
+		reservation.Commit()
+		committed, _ := tracker.State("tx-1")
+		// Assert
+		assert.Nil(t, err)
+		assert.ErrorIs(t, errConflict, ErrInvalidTransition)
+		assert.Equal(t, entity.DEPOSIT_PROCESS, reserved.Current)
+		assert.Equal(t, entity.DEPOSIT_SUCCESS, committed.Current)
+	})
+
+	t.Run("Test Rollback releases the transition", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeReject)
+		_, _ = tracker.Advance("tx-1", entity.DEPOSIT_PROCESS)
+		reservation, _ := tracker.Reserve("tx-1", entity.DEPOSIT_SUCCESS)
+		// Act
+		reservation.Rollback()
+		state, err := tracker.Advance("tx-1", entity.DEPOSIT_CANCEL)
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, entity.DEPOSIT_CANCEL, state.Current)
+	})
+
+	t.Run("Test Advance flags out-of-order notifications", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeFlag)
+		_, _ = tracker.Advance("tx-1", entity.WITHDRAW_CANCEL)
+		// Act
+		state, err := tracker.Advance("tx-1", entity.WITHDRAW_SUCCESS)
+		// Assert
+		assert.Nil(t, err)
+		assert.True(t, state.Flagged)
+		assert.Equal(t, entity.WITHDRAW_SUCCESS, state.Current)
+	})
+
+	t.Run("Test Advance rejects other families and types", func(t *testing.T) {
+		// Arrange
+		tracker := NewTracker(ModeFlag)
+		_, _ = tracker.Advance("tx-1", entity.TRANSFER_PROCESS)
+		// Act
+		_, errFamily := tracker.Advance("tx-1", entity.DEPOSIT_SUCCESS)
+		_, errType := tracker.Advance("tx-2", entity.NEW_POST)
+		// Assert
+		assert.ErrorIs(t, errFamily, ErrFamilyMismatch)
+		assert.ErrorIs(t, errType, ErrNotLifecycleType)
+	})
+
+	t.Run("Test Prune forgets old terminal transactions", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		tracker := NewTracker(ModeReject)
+		tracker.now = func() time.Time { return now }
+		_, _ = tracker.Advance("tx-1", entity.REQUEST_EXCHANGE)
+		_, _ = tracker.Advance("tx-2", entity.TRANSFER_SUCCESS)
+		now = now.Add(time.Hour)
+		// Act
+		pruned := tracker.Prune(time.Minute)
+		_, okOpen := tracker.State("tx-1")
+		_, okDone := tracker.State("tx-2")
+		// Assert
+		assert.Equal(t, 1, pruned)
+		assert.True(t, okOpen)
+		assert.False(t, okDone)
+	})
+}
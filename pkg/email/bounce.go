@@ -0,0 +1,60 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+)
+
+// Bounce classifies why a message was not delivered
+type Bounce string
+
+const (
+	// BounceNone means the message was accepted
+	BounceNone Bounce = ""
+	// BounceSoft is a temporary failure, the message can be retried later
+	BounceSoft Bounce = "soft"
+	// BounceHard is a permanent failure, the address should not be used again
+	BounceHard Bounce = "hard"
+)
+
+// SendError struct
+type SendError struct {
+	Bounce   Bounce
+	Code     int
+	Attempts int
+	Err      error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("email %s bounce after %d attempts: %v", e.Bounce, e.Attempts, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the bounce class and SMTP reply code of an error
+func Classify(err error) (Bounce, int) {
+	if err == nil {
+		return BounceNone, 0
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		switch {
+		case protoErr.Code >= 500:
+			// 552 is "mailbox full" in practice, which usually clears up
+			if protoErr.Code == 552 {
+				return BounceSoft, protoErr.Code
+			}
+			return BounceHard, protoErr.Code
+		case protoErr.Code >= 400:
+			return BounceSoft, protoErr.Code
+		}
+		return BounceHard, protoErr.Code
+	}
+
+	// Connection and timeout errors are worth retrying
+	return BounceSoft, 0
+}
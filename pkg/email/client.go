@@ -0,0 +1,162 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+)
+
+// ErrStartTLSRequired is returned when the server does not offer STARTTLS and it is required
+var ErrStartTLSRequired = errors.New("smtp server does not support STARTTLS")
+
+// Config struct
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// TLSConfig is used for STARTTLS, a config for Host is used when nil
+	TLSConfig *tls.Config
+	// RequireTLS fails the delivery when the server does not offer STARTTLS
+	RequireTLS bool
+	// MaxAttempts is the number of tries for soft bounces, defaults to 3
+	MaxAttempts int
+	// Backoff is the wait before the first retry, it doubles on each retry
+	Backoff time.Duration
+	// Timeout limits each attempt, defaults to 30 seconds
+	Timeout time.Duration
+}
+
+// Client struct
+type Client struct {
+	cfg Config
+}
+
+// NewClient creates a new SMTP Client instance
+func NewClient(cfg Config) *Client {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{ServerName: cfg.Host}
+	}
+
+	return &Client{cfg: cfg}
+}
+
+// Send delivers the message, retrying soft bounces with exponential backoff
+func (c *Client) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = c.cfg.From
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	backoff := c.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err = c.send(ctx, msg.From, msg.To, data)
+		if err == nil {
+			return nil
+		}
+
+		bounce, code := Classify(err)
+		if bounce == BounceHard || attempt >= c.cfg.MaxAttempts || errors.Is(err, ErrStartTLSRequired) {
+			logutils.Error("Failed to send an email", err, logutils.Fields{"bounce": string(bounce), "code": code, "attempts": attempt})
+			return &SendError{Bounce: bounce, Code: code, Attempts: attempt, Err: err}
+		}
+
+		logutils.Warn("Retrying an email", logutils.Fields{"code": code, "attempt": attempt, "error": err.Error()})
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &SendError{Bounce: BounceSoft, Code: code, Attempts: attempt, Err: errors.Join(err, ctx.Err())}
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send runs one SMTP session
+func (c *Client) send(ctx context.Context, from string, to []string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(c.cfg.TLSConfig); err != nil {
+			return err
+		}
+	} else if c.cfg.RequireTLS {
+		return ErrStartTLSRequired
+	}
+
+	if c.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,216 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpServer is a minimal in-process SMTP server supporting STARTTLS and AUTH PLAIN
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	// replies maps a recipient to the RCPT replies returned on each attempt
+	replies map[string][]string
+
+	mu       sync.Mutex
+	attempts map[string]int
+	auth     string
+	secured  bool
+	messages []string
+}
+
+func newSMTPServer(t *testing.T, replies map[string][]string) (*smtpServer, *tls.Config) {
+	tlsServer := httptest.NewTLSServer(nil)
+	t.Cleanup(tlsServer.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	s := &smtpServer{listener: listener, tls: tlsServer.TLS, replies: replies, attempts: make(map[string]int)}
+	go s.serve()
+
+	clientTLS := tlsServer.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	clientTLS.ServerName = "127.0.0.1"
+
+	return s, clientTLS
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *smtpServer) session(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+
+	secured := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			if secured {
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			} else {
+				reply("250-localhost")
+				reply("250 STARTTLS")
+			}
+		case "STARTTLS":
+			reply("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			secured = true
+			s.mu.Lock()
+			s.secured = true
+			s.mu.Unlock()
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = line
+			s.mu.Unlock()
+			reply("235 Authentication successful")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			rcpt := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			s.mu.Lock()
+			attempt := s.attempts[rcpt]
+			s.attempts[rcpt]++
+			s.mu.Unlock()
+			if replies, ok := s.replies[rcpt]; ok && attempt < len(replies) {
+				reply(replies[attempt])
+				continue
+			}
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	server, clientTLS := newSMTPServer(t, map[string][]string{
+		"retry@example.com":   {"451 Try again later"},
+		"unknown@example.com": {"550 No such user"},
+		"full@example.com":    {"452 Mailbox full", "452 Mailbox full", "452 Mailbox full"},
+	})
+	client := NewClient(Config{
+		Host:       "127.0.0.1",
+		Port:       server.port(),
+		Username:   "user",
+		Password:   "secret",
+		From:       "notify@rote.example",
+		TLSConfig:  clientTLS,
+		RequireTLS: true,
+		Backoff:    time.Millisecond,
+	})
+	msg := Message{Subject: "Withdraw completed", Text: "Withdraw completed", HTML: "<h1>Withdraw completed</h1>"}
+
+	t.Run("Test Send over STARTTLS with auth", func(t *testing.T) {
+		// Arrange
+		msg.To = []string{"user@example.com"}
+		// Act
+		err := client.Send(context.Background(), msg)
+		// Assert
+		assert.Nil(t, err)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.True(t, server.secured)
+		assert.Equal(t, "AUTH PLAIN AHVzZXIAc2VjcmV0", server.auth)
+		assert.Len(t, server.messages, 1)
+		assert.Contains(t, server.messages[0], "multipart/alternative")
+		assert.Contains(t, server.messages[0], "text/plain; charset=utf-8")
+		assert.Contains(t, server.messages[0], "<h1>Withdraw completed</h1>")
+	})
+
+	t.Run("Test Send retries a soft bounce", func(t *testing.T) {
+		// Arrange
+		msg.To = []string{"retry@example.com"}
+		// Act
+		err := client.Send(context.Background(), msg)
+		// Assert
+		assert.Nil(t, err)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		assert.Equal(t, 2, server.attempts["retry@example.com"])
+	})
+
+	t.Run("Test Send does not retry a hard bounce", func(t *testing.T) {
+		// Arrange
+		msg.To = []string{"unknown@example.com"}
+		// Act
+		err := client.Send(context.Background(), msg)
+		// Assert
+		var sendErr *SendError
+		assert.True(t, errors.As(err, &sendErr))
+		assert.Equal(t, BounceHard, sendErr.Bounce)
+		assert.Equal(t, 550, sendErr.Code)
+		assert.Equal(t, 1, sendErr.Attempts)
+	})
+
+	t.Run("Test Send gives up after the max attempts", func(t *testing.T) {
+		// Arrange
+		msg.To = []string{"full@example.com"}
+		// Act
+		err := client.Send(context.Background(), msg)
+		// Assert
+		var sendErr *SendError
+		assert.True(t, errors.As(err, &sendErr))
+		assert.Equal(t, BounceSoft, sendErr.Bounce)
+		assert.Equal(t, 3, sendErr.Attempts)
+	})
+}
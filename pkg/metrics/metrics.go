@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "rote_notify"
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// InvalidType is the type label of notifications rejected for an unregistered type,
+// so callers cannot grow the label values
+const InvalidType = "invalid"
+
+// Registry holds every rote-notify collector, it is served by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Notifications counts the NotifyUserId and RecallNotification calls by type and outcome
+	Notifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Notifications sent to users by type and outcome.",
+	}, []string{"type", "outcome"})
+	// NotifyDuration observes the NotifyUserId latency by type and outcome
+	NotifyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "notify_duration_seconds",
+		Help:      "Latency of sending a notification to a user.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type", "outcome"})
+
+	// Pipeline counts the notifications through the interceptors of MetricsInterceptor by type and outcome
+	Pipeline = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pipeline_total",
+		Help:      "Notifications through the interceptor chain by type and outcome.",
+	}, []string{"type", "outcome"})
+	// PipelineDuration observes the latency of the interceptor chain by type and outcome
+	PipelineDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pipeline_duration_seconds",
+		Help:      "Latency of the interceptor chain, signing and publishing included.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type", "outcome"})
+
+	// Published counts the messages published to RabbitMQ by type and outcome
+	Published = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "published_total",
+		Help:      "Messages published to the exchange by type and outcome.",
+	}, []string{"type", "outcome"})
+	// PublishDuration observes the publish latency by type and outcome
+	PublishDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "publish_duration_seconds",
+		Help:      "Latency of publishing a message to the exchange.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type", "outcome"})
+
+	// QueuesCreated counts the user queue declarations by outcome
+	QueuesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "queues_created_total",
+		Help:      "User queue declarations by outcome.",
+	}, []string{"outcome"})
+	// QueueCreateDuration observes the user queue declaration latency by outcome
+	QueueCreateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "queue_create_duration_seconds",
+		Help:      "Latency of declaring and binding a user queue.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	// Consumers counts the consumers started by outcome
+	Consumers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "consumers_started_total",
+		Help:      "Consumers started on user queues by outcome.",
+	}, []string{"outcome"})
+	// ConsumersActive is the number of consumers started and not canceled by connection
+	ConsumersActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "consumers_active",
+		Help:      "Consumers currently receiving deliveries by connection.",
+	}, []string{"connection"})
+	// Consumed counts the deliveries received by consumers by type
+	Consumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "consumed_total",
+		Help:      "Deliveries received by consumers by type.",
+	}, []string{"type"})
+	// ConsumeLatency observes the time from publishing a message to its delivery by type
+	ConsumeLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "consume_latency_seconds",
+		Help:      "Time from publishing a message to its delivery to a consumer.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 12),
+	}, []string{"type"})
+
+	// ConnectionUp is 1 while the RabbitMQ connection is open by connection
+	ConnectionUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "connection_up",
+		Help:      "Whether the RabbitMQ connection is open.",
+	}, []string{"connection"})
+	// ChannelsOpen is the number of open RabbitMQ channels by connection
+	ChannelsOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "channels_open",
+		Help:      "RabbitMQ channels currently open by connection.",
+	}, []string{"connection"})
+
+	// TokensSigned counts the signed notification tokens by outcome
+	TokensSigned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jwt",
+		Name:      "tokens_signed_total",
+		Help:      "Notification tokens signed by outcome.",
+	}, []string{"outcome"})
+	// SignDuration observes the token signing latency by outcome
+	SignDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "jwt",
+		Name:      "sign_duration_seconds",
+		Help:      "Latency of signing a notification token.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 12),
+	}, []string{"outcome"})
+
+	// AuditAppends counts the audit log appends by outcome, a failure leaves a published notification unaudited
+	AuditAppends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "audit",
+		Name:      "appends_total",
+		Help:      "Audit log appends by outcome.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Notifications, NotifyDuration,
+		Pipeline, PipelineDuration,
+		Published, PublishDuration,
+		QueuesCreated, QueueCreateDuration,
+		Consumers, ConsumersActive,
+		Consumed, ConsumeLatency,
+		ConnectionUp, ChannelsOpen,
+		TokensSigned, SignDuration,
+		AuditAppends,
+	)
+}
+
+// Handler serves the collectors of Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Outcome returns OutcomeSuccess for a nil error and OutcomeError otherwise
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+
+	return OutcomeSuccess
+}
+
+// Observe counts the call and observes its duration since start with the same labels
+func Observe(counter *prometheus.CounterVec, histogram *prometheus.HistogramVec, start time.Time, labels ...string) {
+	counter.WithLabelValues(labels...).Inc()
+	histogram.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+// ForgetConnection removes the gauges of a closed connection
+func ForgetConnection(connection string) {
+	ConnectionUp.DeleteLabelValues(connection)
+	ChannelsOpen.DeleteLabelValues(connection)
+	ConsumersActive.DeleteLabelValues(connection)
+}
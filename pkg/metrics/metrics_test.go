@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, OutcomeSuccess, Outcome(nil))
+	assert.Equal(t, OutcomeError, Outcome(errors.New("broker down")))
+}
+
+func TestObserve(t *testing.T) {
+	// Arrange
+	before := testutil.ToFloat64(Published.WithLabelValues("withdraw_success", OutcomeError))
+	// Act
+	Observe(Published, PublishDuration, time.Now(), "withdraw_success", OutcomeError)
+	// Assert
+	assert.Equal(t, before+1, testutil.ToFloat64(Published.WithLabelValues("withdraw_success", OutcomeError)))
+	assert.Equal(t, 1, testutil.CollectAndCount(PublishDuration, "rote_notify_rabbitmq_publish_duration_seconds"))
+}
+
+func TestHandler(t *testing.T) {
+	// Arrange
+	Notifications.WithLabelValues("deposit", OutcomeSuccess).Inc()
+	ConnectionUp.WithLabelValues("1").Set(1)
+	recorder := httptest.NewRecorder()
+	// Act
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `rote_notify_notifications_total{outcome="success",type="deposit"}`)
+	assert.Contains(t, recorder.Body.String(), `rote_notify_rabbitmq_connection_up{connection="1"} 1`)
+	assert.Contains(t, recorder.Body.String(), "go_goroutines")
+}
+
+func TestForgetConnection(t *testing.T) {
+	// Arrange
+	ConnectionUp.WithLabelValues("1").Set(1)
+	ConnectionUp.WithLabelValues("2").Set(0)
+	ChannelsOpen.WithLabelValues("2").Inc()
+	// Act
+	ForgetConnection("2")
+	// Assert, the other connection keeps its gauges
+	assert.Equal(t, 1, testutil.CollectAndCount(ConnectionUp))
+	assert.Equal(t, 1.0, testutil.ToFloat64(ConnectionUp.WithLabelValues("1")))
+	assert.Equal(t, 0, testutil.CollectAndCount(ChannelsOpen))
+}
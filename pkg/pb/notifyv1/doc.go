@@ -0,0 +1,4 @@
+// Package notifyv1 holds the code generated from proto/notify/v1/notify.proto
+package notifyv1
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=github.com/Mona-bele/rote-notify --go-grpc_out=../../.. --go-grpc_opt=module=github.com/Mona-bele/rote-notify notify/v1/notify.proto
@@ -0,0 +1,589 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: notify/v1/notify.proto
+
+package notifyv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NotifyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	CorrelationId string                 `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Ttl           *durationpb.Duration   `protobuf:"bytes,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotifyRequest) Reset() {
+	*x = NotifyRequest{}
+	mi := &file_notify_v1_notify_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotifyRequest) ProtoMessage() {}
+
+func (x *NotifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotifyRequest.ProtoReflect.Descriptor instead.
+func (*NotifyRequest) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NotifyRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *NotifyRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *NotifyRequest) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *NotifyRequest) GetTtl() *durationpb.Duration {
+	if x != nil {
+		return x.Ttl
+	}
+	return nil
+}
+
+type NotifyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotifyResponse) Reset() {
+	*x = NotifyResponse{}
+	mi := &file_notify_v1_notify_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotifyResponse) ProtoMessage() {}
+
+func (x *NotifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotifyResponse.ProtoReflect.Descriptor instead.
+func (*NotifyResponse) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NotifyResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type NotifyBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Notifications []*NotifyRequest       `protobuf:"bytes,1,rep,name=notifications,proto3" json:"notifications,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotifyBatchRequest) Reset() {
+	*x = NotifyBatchRequest{}
+	mi := &file_notify_v1_notify_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotifyBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotifyBatchRequest) ProtoMessage() {}
+
+func (x *NotifyBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotifyBatchRequest.ProtoReflect.Descriptor instead.
+func (*NotifyBatchRequest) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *NotifyBatchRequest) GetNotifications() []*NotifyRequest {
+	if x != nil {
+		return x.Notifications
+	}
+	return nil
+}
+
+type NotifyResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Sent          bool                   `protobuf:"varint,2,opt,name=sent,proto3" json:"sent,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Status        string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotifyResult) Reset() {
+	*x = NotifyResult{}
+	mi := &file_notify_v1_notify_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotifyResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotifyResult) ProtoMessage() {}
+
+func (x *NotifyResult) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotifyResult.ProtoReflect.Descriptor instead.
+func (*NotifyResult) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *NotifyResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *NotifyResult) GetSent() bool {
+	if x != nil {
+		return x.Sent
+	}
+	return false
+}
+
+func (x *NotifyResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *NotifyResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *NotifyResult) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type NotifyBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*NotifyResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NotifyBatchResponse) Reset() {
+	*x = NotifyBatchResponse{}
+	mi := &file_notify_v1_notify_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NotifyBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NotifyBatchResponse) ProtoMessage() {}
+
+func (x *NotifyBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NotifyBatchResponse.ProtoReflect.Descriptor instead.
+func (*NotifyBatchResponse) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NotifyBatchResponse) GetResults() []*NotifyResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type DeleteUserQueueRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserQueueRequest) Reset() {
+	*x = DeleteUserQueueRequest{}
+	mi := &file_notify_v1_notify_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserQueueRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserQueueRequest) ProtoMessage() {}
+
+func (x *DeleteUserQueueRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserQueueRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserQueueRequest) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteUserQueueRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type DeleteUserQueueResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteUserQueueResponse) Reset() {
+	*x = DeleteUserQueueResponse{}
+	mi := &file_notify_v1_notify_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteUserQueueResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserQueueResponse) ProtoMessage() {}
+
+func (x *DeleteUserQueueResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserQueueResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserQueueResponse) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{6}
+}
+
+type StreamUserNotificationsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUserNotificationsRequest) Reset() {
+	*x = StreamUserNotificationsRequest{}
+	mi := &file_notify_v1_notify_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUserNotificationsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUserNotificationsRequest) ProtoMessage() {}
+
+func (x *StreamUserNotificationsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUserNotificationsRequest.ProtoReflect.Descriptor instead.
+func (*StreamUserNotificationsRequest) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamUserNotificationsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type UserNotification struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Token         string                 `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	RecalledId    string                 `protobuf:"bytes,3,opt,name=recalled_id,json=recalledId,proto3" json:"recalled_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserNotification) Reset() {
+	*x = UserNotification{}
+	mi := &file_notify_v1_notify_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserNotification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserNotification) ProtoMessage() {}
+
+func (x *UserNotification) ProtoReflect() protoreflect.Message {
+	mi := &file_notify_v1_notify_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserNotification.ProtoReflect.Descriptor instead.
+func (*UserNotification) Descriptor() ([]byte, []int) {
+	return file_notify_v1_notify_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UserNotification) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UserNotification) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *UserNotification) GetRecalledId() string {
+	if x != nil {
+		return x.RecalledId
+	}
+	return ""
+}
+
+var File_notify_v1_notify_proto protoreflect.FileDescriptor
+
+const file_notify_v1_notify_proto_rawDesc = "" +
+	"\n" +
+	"\x16notify/v1/notify.proto\x12\tnotify.v1\x1a\x1egoogle/protobuf/duration.proto\"\x90\x01\n" +
+	"\rNotifyRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12%\n" +
+	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\x12+\n" +
+	"\x03ttl\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x03ttl\"(\n" +
+	"\x0eNotifyResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\"T\n" +
+	"\x12NotifyBatchRequest\x12>\n" +
+	"\rnotifications\x18\x01 \x03(\v2\x18.notify.v1.NotifyRequestR\rnotifications\"z\n" +
+	"\fNotifyResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04sent\x18\x02 \x01(\bR\x04sent\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\"H\n" +
+	"\x13NotifyBatchResponse\x121\n" +
+	"\aresults\x18\x01 \x03(\v2\x17.notify.v1.NotifyResultR\aresults\"1\n" +
+	"\x16DeleteUserQueueRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"\x19\n" +
+	"\x17DeleteUserQueueResponse\"9\n" +
+	"\x1eStreamUserNotificationsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"Y\n" +
+	"\x10UserNotification\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05token\x18\x02 \x01(\tR\x05token\x12\x1f\n" +
+	"\vrecalled_id\x18\x03 \x01(\tR\n" +
+	"recalledId2\xdb\x02\n" +
+	"\rNotifyService\x12=\n" +
+	"\x06Notify\x12\x18.notify.v1.NotifyRequest\x1a\x19.notify.v1.NotifyResponse\x12L\n" +
+	"\vNotifyBatch\x12\x1d.notify.v1.NotifyBatchRequest\x1a\x1e.notify.v1.NotifyBatchResponse\x12X\n" +
+	"\x0fDeleteUserQueue\x12!.notify.v1.DeleteUserQueueRequest\x1a\".notify.v1.DeleteUserQueueResponse\x12c\n" +
+	"\x17StreamUserNotifications\x12).notify.v1.StreamUserNotificationsRequest\x1a\x1b.notify.v1.UserNotification0\x01B;Z9github.com/Mona-bele/rote-notify/pkg/pb/notifyv1;notifyv1b\x06proto3"
+
+var (
+	file_notify_v1_notify_proto_rawDescOnce sync.Once
+	file_notify_v1_notify_proto_rawDescData []byte
+)
+
+func file_notify_v1_notify_proto_rawDescGZIP() []byte {
+	file_notify_v1_notify_proto_rawDescOnce.Do(func() {
+		file_notify_v1_notify_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_notify_v1_notify_proto_rawDesc), len(file_notify_v1_notify_proto_rawDesc)))
+	})
+	return file_notify_v1_notify_proto_rawDescData
+}
+
+var file_notify_v1_notify_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_notify_v1_notify_proto_goTypes = []any{
+	(*NotifyRequest)(nil),                  // 0: notify.v1.NotifyRequest
+	(*NotifyResponse)(nil),                 // 1: notify.v1.NotifyResponse
+	(*NotifyBatchRequest)(nil),             // 2: notify.v1.NotifyBatchRequest
+	(*NotifyResult)(nil),                   // 3: notify.v1.NotifyResult
+	(*NotifyBatchResponse)(nil),            // 4: notify.v1.NotifyBatchResponse
+	(*DeleteUserQueueRequest)(nil),         // 5: notify.v1.DeleteUserQueueRequest
+	(*DeleteUserQueueResponse)(nil),        // 6: notify.v1.DeleteUserQueueResponse
+	(*StreamUserNotificationsRequest)(nil), // 7: notify.v1.StreamUserNotificationsRequest
+	(*UserNotification)(nil),               // 8: notify.v1.UserNotification
+	(*durationpb.Duration)(nil),            // 9: google.protobuf.Duration
+}
+var file_notify_v1_notify_proto_depIdxs = []int32{
+	9, // 0: notify.v1.NotifyRequest.ttl:type_name -> google.protobuf.Duration
+	0, // 1: notify.v1.NotifyBatchRequest.notifications:type_name -> notify.v1.NotifyRequest
+	3, // 2: notify.v1.NotifyBatchResponse.results:type_name -> notify.v1.NotifyResult
+	0, // 3: notify.v1.NotifyService.Notify:input_type -> notify.v1.NotifyRequest
+	2, // 4: notify.v1.NotifyService.NotifyBatch:input_type -> notify.v1.NotifyBatchRequest
+	5, // 5: notify.v1.NotifyService.DeleteUserQueue:input_type -> notify.v1.DeleteUserQueueRequest
+	7, // 6: notify.v1.NotifyService.StreamUserNotifications:input_type -> notify.v1.StreamUserNotificationsRequest
+	1, // 7: notify.v1.NotifyService.Notify:output_type -> notify.v1.NotifyResponse
+	4, // 8: notify.v1.NotifyService.NotifyBatch:output_type -> notify.v1.NotifyBatchResponse
+	6, // 9: notify.v1.NotifyService.DeleteUserQueue:output_type -> notify.v1.DeleteUserQueueResponse
+	8, // 10: notify.v1.NotifyService.StreamUserNotifications:output_type -> notify.v1.UserNotification
+	7, // [7:11] is the sub-list for method output_type
+	3, // [3:7] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_notify_v1_notify_proto_init() }
+func file_notify_v1_notify_proto_init() {
+	if File_notify_v1_notify_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_notify_v1_notify_proto_rawDesc), len(file_notify_v1_notify_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_notify_v1_notify_proto_goTypes,
+		DependencyIndexes: file_notify_v1_notify_proto_depIdxs,
+		MessageInfos:      file_notify_v1_notify_proto_msgTypes,
+	}.Build()
+	File_notify_v1_notify_proto = out.File
+	file_notify_v1_notify_proto_goTypes = nil
+	file_notify_v1_notify_proto_depIdxs = nil
+}
@@ -0,0 +1,239 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: notify/v1/notify.proto
+
+package notifyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NotifyService_Notify_FullMethodName                  = "/notify.v1.NotifyService/Notify"
+	NotifyService_NotifyBatch_FullMethodName             = "/notify.v1.NotifyService/NotifyBatch"
+	NotifyService_DeleteUserQueue_FullMethodName         = "/notify.v1.NotifyService/DeleteUserQueue"
+	NotifyService_StreamUserNotifications_FullMethodName = "/notify.v1.NotifyService/StreamUserNotifications"
+)
+
+// NotifyServiceClient is the client API for NotifyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NotifyServiceClient interface {
+	Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error)
+	NotifyBatch(ctx context.Context, in *NotifyBatchRequest, opts ...grpc.CallOption) (*NotifyBatchResponse, error)
+	DeleteUserQueue(ctx context.Context, in *DeleteUserQueueRequest, opts ...grpc.CallOption) (*DeleteUserQueueResponse, error)
+	StreamUserNotifications(ctx context.Context, in *StreamUserNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UserNotification], error)
+}
+
+type notifyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotifyServiceClient(cc grpc.ClientConnInterface) NotifyServiceClient {
+	return &notifyServiceClient{cc}
+}
+
+func (c *notifyServiceClient) Notify(ctx context.Context, in *NotifyRequest, opts ...grpc.CallOption) (*NotifyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotifyResponse)
+	err := c.cc.Invoke(ctx, NotifyService_Notify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyServiceClient) NotifyBatch(ctx context.Context, in *NotifyBatchRequest, opts ...grpc.CallOption) (*NotifyBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NotifyBatchResponse)
+	err := c.cc.Invoke(ctx, NotifyService_NotifyBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyServiceClient) DeleteUserQueue(ctx context.Context, in *DeleteUserQueueRequest, opts ...grpc.CallOption) (*DeleteUserQueueResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserQueueResponse)
+	err := c.cc.Invoke(ctx, NotifyService_DeleteUserQueue_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notifyServiceClient) StreamUserNotifications(ctx context.Context, in *StreamUserNotificationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UserNotification], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotifyService_ServiceDesc.Streams[0], NotifyService_StreamUserNotifications_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamUserNotificationsRequest, UserNotification]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotifyService_StreamUserNotificationsClient = grpc.ServerStreamingClient[UserNotification]
+
+// NotifyServiceServer is the server API for NotifyService service.
+// All implementations must embed UnimplementedNotifyServiceServer
+// for forward compatibility.
+type NotifyServiceServer interface {
+	Notify(context.Context, *NotifyRequest) (*NotifyResponse, error)
+	NotifyBatch(context.Context, *NotifyBatchRequest) (*NotifyBatchResponse, error)
+	DeleteUserQueue(context.Context, *DeleteUserQueueRequest) (*DeleteUserQueueResponse, error)
+	StreamUserNotifications(*StreamUserNotificationsRequest, grpc.ServerStreamingServer[UserNotification]) error
+	mustEmbedUnimplementedNotifyServiceServer()
+}
+
+// UnimplementedNotifyServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNotifyServiceServer struct{}
+
+func (UnimplementedNotifyServiceServer) Notify(context.Context, *NotifyRequest) (*NotifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Notify not implemented")
+}
+func (UnimplementedNotifyServiceServer) NotifyBatch(context.Context, *NotifyBatchRequest) (*NotifyBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NotifyBatch not implemented")
+}
+func (UnimplementedNotifyServiceServer) DeleteUserQueue(context.Context, *DeleteUserQueueRequest) (*DeleteUserQueueResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserQueue not implemented")
+}
+func (UnimplementedNotifyServiceServer) StreamUserNotifications(*StreamUserNotificationsRequest, grpc.ServerStreamingServer[UserNotification]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUserNotifications not implemented")
+}
+func (UnimplementedNotifyServiceServer) mustEmbedUnimplementedNotifyServiceServer() {}
+func (UnimplementedNotifyServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeNotifyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotifyServiceServer will
+// result in compilation errors.
+type UnsafeNotifyServiceServer interface {
+	mustEmbedUnimplementedNotifyServiceServer()
+}
+
+func RegisterNotifyServiceServer(s grpc.ServiceRegistrar, srv NotifyServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNotifyServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NotifyService_ServiceDesc, srv)
+}
+
+func _NotifyService_Notify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyServiceServer).Notify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyService_Notify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyServiceServer).Notify(ctx, req.(*NotifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyService_NotifyBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NotifyBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyServiceServer).NotifyBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyService_NotifyBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyServiceServer).NotifyBatch(ctx, req.(*NotifyBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyService_DeleteUserQueue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserQueueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotifyServiceServer).DeleteUserQueue(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotifyService_DeleteUserQueue_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotifyServiceServer).DeleteUserQueue(ctx, req.(*DeleteUserQueueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotifyService_StreamUserNotifications_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamUserNotificationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NotifyServiceServer).StreamUserNotifications(m, &grpc.GenericServerStream[StreamUserNotificationsRequest, UserNotification]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotifyService_StreamUserNotificationsServer = grpc.ServerStreamingServer[UserNotification]
+
+// NotifyService_ServiceDesc is the grpc.ServiceDesc for NotifyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotifyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notify.v1.NotifyService",
+	HandlerType: (*NotifyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Notify",
+			Handler:    _NotifyService_Notify_Handler,
+		},
+		{
+			MethodName: "NotifyBatch",
+			Handler:    _NotifyService_NotifyBatch_Handler,
+		},
+		{
+			MethodName: "DeleteUserQueue",
+			Handler:    _NotifyService_DeleteUserQueue_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUserNotifications",
+			Handler:       _NotifyService_StreamUserNotifications_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "notify/v1/notify.proto",
+}
@@ -0,0 +1,116 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	APNsEndpoint        = "https://api.push.apple.com"
+	APNsSandboxEndpoint = "https://api.sandbox.push.apple.com"
+)
+
+// APNsConfig struct
+type APNsConfig struct {
+	// Endpoint is the APNs base URL, APNsEndpoint is used when empty
+	Endpoint string
+	// Topic is the bundle ID of the app
+	Topic string
+	// Token returns the provider authentication token
+	Token TokenSource
+	// Client must support HTTP/2 to reach Apple, a client forcing HTTP/2 is used when nil
+	Client *http.Client
+}
+
+// APNs struct
+type APNs struct {
+	cfg APNsConfig
+}
+
+// NewAPNs creates a new APNs HTTP/2 provider
+func NewAPNs(cfg APNsConfig) *APNs {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = APNsEndpoint
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Transport: &http.Transport{ForceAttemptHTTP2: true}}
+	}
+
+	return &APNs{cfg: cfg}
+}
+
+// Name returns the provider name
+func (a *APNs) Name() string {
+	return "apns"
+}
+
+type apnsPayload struct {
+	Aps  apnsAps           `json:"aps"`
+	Data map[string]string `json:"data,omitempty"`
+}
+
+type apnsAps struct {
+	Alert apnsAlert `json:"alert"`
+	Sound string    `json:"sound,omitempty"`
+}
+
+type apnsAlert struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Send sends the notification to the APNs device token
+func (a *APNs) Send(ctx context.Context, token string, notification Notification) error {
+	payload, err := json.Marshal(apnsPayload{
+		Aps:  apnsAps{Alert: apnsAlert{Title: notification.Title, Body: notification.Body}, Sound: "default"},
+		Data: notification.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.Endpoint+"/3/device/"+token, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", a.cfg.Topic)
+	req.Header.Set("apns-push-type", "alert")
+
+	if a.cfg.Token != nil {
+		providerToken, err := a.cfg.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "bearer "+providerToken)
+	}
+
+	resp, err := a.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if body.Reason == "" {
+		body.Reason = http.StatusText(resp.StatusCode)
+	}
+
+	return &ProviderError{
+		Provider:   a.Name(),
+		StatusCode: resp.StatusCode,
+		Reason:     body.Reason,
+		Dead:       resp.StatusCode == http.StatusGone || body.Reason == "BadDeviceToken" || body.Reason == "Unregistered",
+	}
+}
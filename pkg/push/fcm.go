@@ -0,0 +1,127 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const FCMEndpoint = "https://fcm.googleapis.com"
+
+// FCMConfig struct
+type FCMConfig struct {
+	// Endpoint is the FCM base URL, FCMEndpoint is used when empty
+	Endpoint  string
+	ProjectID string
+	// Token returns the OAuth2 access token of the service account
+	Token  TokenSource
+	Client *http.Client
+}
+
+// FCM struct
+type FCM struct {
+	cfg FCMConfig
+}
+
+// NewFCM creates a new FCM HTTP v1 provider
+func NewFCM(cfg FCMConfig) *FCM {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = FCMEndpoint
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &FCM{cfg: cfg}
+}
+
+// Name returns the provider name
+func (f *FCM) Name() string {
+	return "fcm"
+}
+
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// Send sends the notification to the FCM registration token
+func (f *FCM) Send(ctx context.Context, token string, notification Notification) error {
+	payload, err := json.Marshal(fcmRequest{Message: fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: notification.Title, Body: notification.Body},
+		Data:         notification.Data,
+	}})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/projects/%s/messages:send", f.cfg.Endpoint, f.cfg.ProjectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if f.cfg.Token != nil {
+		accessToken, err := f.cfg.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := f.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var body fcmError
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+
+	reason := body.Error.Status
+	for _, detail := range body.Error.Details {
+		if detail.ErrorCode != "" {
+			reason = detail.ErrorCode
+		}
+	}
+	if reason == "" {
+		reason = http.StatusText(resp.StatusCode)
+	}
+
+	return &ProviderError{
+		Provider:   f.Name(),
+		StatusCode: resp.StatusCode,
+		Reason:     reason,
+		Dead:       resp.StatusCode == http.StatusNotFound || reason == "UNREGISTERED",
+	}
+}
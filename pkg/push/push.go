@@ -0,0 +1,59 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+// ErrUnregistered is wrapped by errors for device tokens the provider no longer accepts
+var ErrUnregistered = errors.New("device token is unregistered")
+
+// Notification struct
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Provider sends a notification to a device token
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, token string, notification Notification) error
+}
+
+// TokenSource returns the bearer token used to authenticate to a provider
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken returns a TokenSource that always returns the token
+func StaticToken(token string) TokenSource {
+	return func(ctx context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// ProviderError struct
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Reason     string
+	Dead       bool
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Reason)
+}
+
+func (e *ProviderError) Unwrap() error {
+	if e.Dead {
+		return ErrUnregistered
+	}
+
+	return nil
+}
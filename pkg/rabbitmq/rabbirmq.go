@@ -1,10 +1,18 @@
 package rabbitmq
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Mona-bele/logutils-go/logutils"
 	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -12,20 +20,55 @@ const (
 	exchangeName          = "ex_notifications_user_id"
 	exchangeType          = "topic"
 	TtlAmpqExpired365Days = int32(1471228928)
+	// RecalledIDHeader holds the ID of the message a tombstone recalls
+	RecalledIDHeader = "x-recalled-id"
+	// DeadLetterQueue keeps the messages consumers failed to handle, for inspection
+	DeadLetterQueue = "notifications_dead_letter"
+	// DeadLetterReasonHeader is set on the messages of DeadLetterQueue, OriginalRoutingKeyHeader on the ones
+	// a consumer dead-lettered or republished
+	DeadLetterReasonHeader   = "x-dead-letter-reason"
+	OriginalRoutingKeyHeader = "x-original-routing-key"
 )
 
+var (
+	// ErrConnectionClosed is returned when the RabbitMQ connection is missing or closed
+	ErrConnectionClosed = errors.New("rabbitmq connection is closed")
+	// ErrUnknownConsumer is returned when canceling a consumer that is not consuming
+	ErrUnknownConsumer = errors.New("unknown consumer")
+)
+
+// connections numbers the RabbitMQ instances, it labels their gauges
+var connections atomic.Uint64
+
 // RabbitMQ struct
 type RabbitMQ struct {
 	Conn *amqp.Connection
 	Ch   *amqp.Channel
+	// Prefetch limits the unacked deliveries of each ConsumeQueue consumer, unlimited when 0
+	Prefetch int
+	// name labels the gauges of the connection
+	name string
+	// lastPublish is the Unix time in nanoseconds of the last successful publish
+	lastPublish atomic.Int64
+
+	mu sync.Mutex
+	// consumers are the channels of the consumers of ConsumeQueue by consumer tag
+	consumers map[string]*amqp.Channel
 }
 
 // Message struct
 type Message struct {
+	ID         string `json:"id"`
 	Type       string `json:"type"`
 	UserID     string `json:"user_id"`
 	RoutingKey string `json:"routing_key"`
 	Body       []byte `json:"body"`
+	// ContentType of the body, text/plain when empty
+	ContentType string `json:"content_type,omitempty"`
+	// Expiration drops the message from the queue once elapsed, 0 keeps it until consumed
+	Expiration time.Duration `json:"expiration"`
+	// Headers are readable by consumers without verifying the body
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // CloseRabbitMQ closes the RabbitMQ connection
@@ -39,26 +82,31 @@ func (r *RabbitMQ) CloseRabbitMQ() {
 	if err != nil {
 		logutils.Error("Failed to close the connection", err, nil)
 	}
+	metrics.ForgetConnection(r.name)
 	logutils.Info("RabbitMQ connection closed", nil)
 }
 
-// NewRabbitMQ creates a new RabbitMQ instance
+// NewRabbitMQ creates a new RabbitMQ instance, its gauges are labelled with a number unique in the process
 func NewRabbitMQ(env *env.Env) *RabbitMQ {
-	conn, ch := connectRabbitMQ(env)
-	return &RabbitMQ{Conn: conn, Ch: ch}
+	name := strconv.FormatUint(connections.Add(1), 10)
+	conn, ch := connectRabbitMQ(env, name)
+	return &RabbitMQ{Conn: conn, Ch: ch, name: name}
 }
 
 // connectRabbitMQ to RabbitMQ
-func connectRabbitMQ(env *env.Env) (*amqp.Connection, *amqp.Channel) {
+func connectRabbitMQ(env *env.Env, name string) (*amqp.Connection, *amqp.Channel) {
 	conn, err := amqp.Dial(env.RabbitmqUrl)
 	if err != nil {
 		logutils.Error("Failed to connect to RabbitMQ", err, nil)
 	}
 
+	watchConnection(conn, name)
+
 	ch, err := conn.Channel()
 	if err != nil {
 		logutils.Error("Failed to open a channel", err, nil)
 	}
+	watchChannel(ch, name)
 
 	err = ch.ExchangeDeclare(exchangeName, exchangeType, true, false, false, false, nil)
 	if err != nil {
@@ -70,9 +118,81 @@ func connectRabbitMQ(env *env.Env) (*amqp.Connection, *amqp.Channel) {
 	return conn, ch
 }
 
-// CreateUserQueue Create a user-specific queue
-func (r *RabbitMQ) CreateUserQueue(userID string, temporary bool) {
-	queueName := "user_" + userID
+// watchConnection keeps the connection gauge up to date
+func watchConnection(conn *amqp.Connection, name string) {
+	if conn == nil {
+		return
+	}
+
+	metrics.ConnectionUp.WithLabelValues(name).Set(1)
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		metrics.ConnectionUp.WithLabelValues(name).Set(0)
+	}()
+}
+
+// watchChannel keeps the open channels gauge up to date
+func watchChannel(ch *amqp.Channel, name string) {
+	if ch == nil {
+		return
+	}
+
+	metrics.ChannelsOpen.WithLabelValues(name).Inc()
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		metrics.ChannelsOpen.WithLabelValues(name).Dec()
+	}()
+}
+
+// watchConsumer keeps the gauges of a channel with a single consumer up to date, the consumer ends with it
+func watchConsumer(ch *amqp.Channel, name string) {
+	metrics.ChannelsOpen.WithLabelValues(name).Inc()
+	metrics.ConsumersActive.WithLabelValues(name).Inc()
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		metrics.ChannelsOpen.WithLabelValues(name).Dec()
+		metrics.ConsumersActive.WithLabelValues(name).Dec()
+	}()
+}
+
+// LastPublish returns the time of the last successful publish, zero if none
+func (r *RabbitMQ) LastPublish() time.Time {
+	nanos := r.lastPublish.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+// ExchangeExists checks the notifications exchange is declared
+func (r *RabbitMQ) ExchangeExists() error {
+	if r.Conn == nil || r.Conn.IsClosed() {
+		return ErrConnectionClosed
+	}
+
+	// A failed passive declare closes its channel, so it must not be the publishing one
+	ch, err := r.Conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	return ch.ExchangeDeclarePassive(exchangeName, exchangeType, true, false, false, false, nil)
+}
+
+// CreateUserQueue Create a user-specific queue bound to the messages of the user
+func (r *RabbitMQ) CreateUserQueue(userID string, temporary bool) error {
+	if err := ValidateUserID(userID); err != nil {
+		logutils.Error("Refused to create a queue", err, nil)
+		return err
+	}
+
+	queueName := QueueName(userID)
+	start := time.Now()
 
 	args := make(amqp.Table)
 	if temporary {
@@ -80,54 +200,339 @@ func (r *RabbitMQ) CreateUserQueue(userID string, temporary bool) {
 	}
 	args["x-message-ttl"] = TtlAmpqExpired365Days
 
-	q, err := r.Ch.QueueDeclare(queueName, !temporary, false, false, false, args)
-	if err != nil {
-		logutils.Error("Failed to declare a queue", err, nil)
+	q, declareErr := r.Ch.QueueDeclare(queueName, !temporary, false, false, false, args)
+	if declareErr != nil {
+		logutils.Error("Failed to declare a queue", declareErr, nil)
 	}
 
-	err = r.Ch.QueueBind(q.Name, fmt.Sprintf("user.%s.*", userID), exchangeName, false, nil)
+	err := r.Ch.QueueBind(q.Name, RoutingKey(userID, "*"), exchangeName, false, nil)
 	if err != nil {
 		logutils.Error("Failed to bind a queue", err, nil)
 	}
-
-	args = nil
+	err = errors.Join(declareErr, err)
+	metrics.Observe(metrics.QueuesCreated, metrics.QueueCreateDuration, start, metrics.Outcome(err))
+	if err != nil {
+		return err
+	}
 
 	logutils.Info("Queue created", map[string]interface{}{"queue": q.Name})
+
+	return nil
+}
+
+// CreateBoundQueue Create a durable queue bound to the messages of the binding key, shared by the consumers of a service
+func (r *RabbitMQ) CreateBoundQueue(queueName, bindingKey string) error {
+	if _, err := r.Ch.QueueDeclare(queueName, true, false, false, false, nil); err != nil {
+		logutils.Error("Failed to declare a queue", err, nil)
+		return err
+	}
+	if err := r.Ch.QueueBind(queueName, bindingKey, exchangeName, false, nil); err != nil {
+		logutils.Error("Failed to bind a queue", err, nil)
+		return err
+	}
+	logutils.Info("Queue created", map[string]interface{}{"queue": queueName, "binding_key": bindingKey})
+
+	return nil
 }
 
 // DeleteUserQueue Delete a user-specific queue
-func (r *RabbitMQ) DeleteUserQueue(userID string) {
-	queueName := "user_" + userID
+func (r *RabbitMQ) DeleteUserQueue(userID string) error {
+	if err := ValidateUserID(userID); err != nil {
+		return err
+	}
+
+	queueName := QueueName(userID)
 	_, err := r.Ch.QueueDelete(queueName, false, false, false)
 	if err != nil {
 		logutils.Error("Failed to delete a queue", err, nil)
+		return err
 	}
 	logutils.Info("Queue deleted", map[string]interface{}{"queue": queueName})
+
+	return nil
+}
+
+// NewMessageID returns a unique message ID, IDs of later messages sort after earlier ones
+func NewMessageID() string {
+	random := make([]byte, 4)
+	_, _ = rand.Read(random)
+
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(random))
 }
 
-// PublishMessage Publish a message to the exchange
+// UserQueueStats Inspect a user-specific queue without creating it
+func (r *RabbitMQ) UserQueueStats(userID string) (amqp.Queue, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return amqp.Queue{}, err
+	}
+
+	queueName := QueueName(userID)
+	q, err := r.Ch.QueueDeclarePassive(queueName, true, false, false, false, nil)
+	if err != nil {
+		logutils.Error("Failed to inspect a queue", err, nil)
+		return amqp.Queue{}, err
+	}
+
+	return q, nil
+}
+
+// PurgeUserQueue Remove every message of a user-specific queue
+func (r *RabbitMQ) PurgeUserQueue(userID string) (int, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return 0, err
+	}
+
+	queueName := QueueName(userID)
+	count, err := r.Ch.QueuePurge(queueName, false)
+	if err != nil {
+		logutils.Error("Failed to purge a queue", err, nil)
+		return 0, err
+	}
+	logutils.Info("Queue purged", map[string]interface{}{"queue": queueName, "messages": count})
+
+	return count, nil
+}
+
+// PublishMessage Publish a message to the exchange, an empty routing key is derived from the user ID and type
 func (r *RabbitMQ) PublishMessage(message Message) error {
-	err := r.Ch.Publish(exchangeName, message.RoutingKey, false, false, amqp.Publishing{
-		ContentType: "text/plain",
+	if err := errors.Join(ValidateUserID(message.UserID), ValidateType(message.Type)); err != nil {
+		logutils.Error("Refused to publish a message", err, nil)
+		return err
+	}
+	routingKey := RoutingKey(message.UserID, message.Type)
+	if message.RoutingKey == "" {
+		message.RoutingKey = routingKey
+	}
+	if message.RoutingKey != routingKey {
+		return &ValidationError{Kind: ErrInvalidRoutingKey, Value: message.RoutingKey, Reason: "must be " + routingKey}
+	}
+
+	if message.ID == "" {
+		message.ID = NewMessageID()
+	}
+
+	if message.ContentType == "" {
+		message.ContentType = "text/plain"
+	}
+
+	publishing := amqp.Publishing{
+		ContentType: message.ContentType,
+		MessageId:   message.ID,
+		Timestamp:   time.Now(),
+		Type:        message.Type,
 		Body:        message.Body,
-	})
+	}
+	if len(message.Headers) > 0 {
+		publishing.Headers = amqp.Table{}
+		for key, value := range message.Headers {
+			publishing.Headers[key] = value
+		}
+	}
+	if message.Expiration > 0 {
+		// The per-message TTL is given in milliseconds
+		publishing.Expiration = strconv.FormatInt(message.Expiration.Milliseconds(), 10)
+	}
+
+	start := time.Now()
+	err := r.Ch.Publish(exchangeName, message.RoutingKey, false, false, publishing)
+	metrics.Observe(metrics.Published, metrics.PublishDuration, start, message.Type, metrics.Outcome(err))
 	if err != nil {
 		logutils.Error("Failed to publish a message", err, nil)
 		return err
 	}
+	r.lastPublish.Store(time.Now().UnixNano())
 	logutils.Info("Message published", map[string]interface{}{"routing_key": message.RoutingKey})
 
 	return nil
 }
 
-// ConsumeMessages Consume messages from the exchange
+// ConsumeMessages Consume messages from a user-specific queue, they are acked on delivery
+// An invalid user ID is logged and gets a closed channel, callers validate it first to get the error.
 func (r *RabbitMQ) ConsumeMessages(userID string) <-chan amqp.Delivery {
-	queueName := "user_" + userID
-	msgs, err := r.Ch.Consume(queueName, "", true, false, false, false, nil)
+	return r.ConsumeUserQueue(userID, "", true)
+}
+
+// ConsumeUserQueue Consume messages from a user-specific queue with ConsumeQueue, the queue is not declared
+// An invalid user ID is logged and gets a closed channel, callers validate it first to get the error.
+func (r *RabbitMQ) ConsumeUserQueue(userID, consumerTag string, autoAck bool) <-chan amqp.Delivery {
+	if err := ValidateUserID(userID); err != nil {
+		logutils.Error("Refused to consume messages", err, nil)
+		metrics.Consumers.WithLabelValues(metrics.OutcomeError).Inc()
+		return closedDeliveries()
+	}
+
+	return r.ConsumeQueue(QueueName(userID), consumerTag, autoAck)
+}
+
+// ConsumeQueue Consume messages from a queue on a channel of its own, so a missing queue closes only that channel
+// Without autoAck each delivery must be acked, CancelConsumer closes the channel and the broker requeues the unacked ones.
+// A failed consume is logged and gets a closed channel, an empty consumer tag cannot be canceled.
+// The deliveries are counted on the way, so callers read the channel until it is closed.
+func (r *RabbitMQ) ConsumeQueue(queueName, consumerTag string, autoAck bool) <-chan amqp.Delivery {
+	msgs, err := r.consume(queueName, consumerTag, autoAck)
+	metrics.Consumers.WithLabelValues(metrics.Outcome(err)).Inc()
 	if err != nil {
-		logutils.Error("Failed to consume messages", err, nil)
+		logutils.Error("Failed to consume messages", err, map[string]interface{}{"queue": queueName})
+		return closedDeliveries()
 	}
 	logutils.Info("Consuming messages", map[string]interface{}{"queue": queueName})
 
-	return msgs
+	return observeDeliveries(msgs)
+}
+
+// consume opens the channel of a consumer and starts it, the channel is kept by consumer tag to cancel it
+func (r *RabbitMQ) consume(queueName, consumerTag string, autoAck bool) (<-chan amqp.Delivery, error) {
+	if r.Conn == nil || r.Conn.IsClosed() {
+		return nil, ErrConnectionClosed
+	}
+
+	ch, err := r.Conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if r.Prefetch > 0 {
+		if err := ch.Qos(r.Prefetch, 0, false); err != nil {
+			_ = ch.Close()
+			return nil, err
+		}
+	}
+	msgs, err := ch.Consume(queueName, consumerTag, autoAck, false, false, false, nil)
+	if err != nil {
+		// The broker closes the channel of a failed consume, closing it again only reports that
+		_ = ch.Close()
+		return nil, err
+	}
+	watchConsumer(ch, r.name)
+
+	if consumerTag != "" {
+		r.mu.Lock()
+		if r.consumers == nil {
+			r.consumers = make(map[string]*amqp.Channel)
+		}
+		r.consumers[consumerTag] = ch
+		r.mu.Unlock()
+	}
+
+	return msgs, nil
+}
+
+// TapUserMessages Consume copies of the messages published to a user from a temporary queue of this connection
+// The queue of the user keeps its messages, the temporary queue is deleted with the channel reading it.
+func (r *RabbitMQ) TapUserMessages(userID string) (<-chan amqp.Delivery, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return nil, err
+	}
+
+	q, err := r.Ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		logutils.Error("Failed to declare a queue", err, nil)
+		return nil, err
+	}
+	if err := r.Ch.QueueBind(q.Name, RoutingKey(userID, "*"), exchangeName, false, nil); err != nil {
+		logutils.Error("Failed to bind a queue", err, nil)
+		return nil, err
+	}
+
+	msgs, err := r.consume(q.Name, "", true)
+	if err != nil {
+		logutils.Error("Failed to register a consumer", err, logutils.Fields{"queue": q.Name})
+		return nil, err
+	}
+
+	return observeDeliveries(msgs), nil
+}
+
+// closedDeliveries is the channel of a consume that failed
+func closedDeliveries() <-chan amqp.Delivery {
+	closed := make(chan amqp.Delivery)
+	close(closed)
+
+	return closed
+}
+
+// observeDeliveries forwards the deliveries, counting them and observing their latency since publishing
+func observeDeliveries(msgs <-chan amqp.Delivery) <-chan amqp.Delivery {
+	observed := make(chan amqp.Delivery)
+	go func() {
+		defer close(observed)
+		for msg := range msgs {
+			metrics.Consumed.WithLabelValues(msg.Type).Inc()
+			if !msg.Timestamp.IsZero() {
+				metrics.ConsumeLatency.WithLabelValues(msg.Type).Observe(time.Since(msg.Timestamp).Seconds())
+			}
+			observed <- msg
+		}
+	}()
+
+	return observed
+}
+
+// DeadLetter Move a delivery to DeadLetterQueue with the reason in its headers, then ack it.
+// The user queues are declared without a dead letter exchange, so consumers move the messages themselves.
+func (r *RabbitMQ) DeadLetter(delivery amqp.Delivery, reason string) error {
+	if _, err := r.Ch.QueueDeclare(DeadLetterQueue, true, false, false, false, nil); err != nil {
+		logutils.Error("Failed to declare the dead letter queue", err, nil)
+		return err
+	}
+
+	if err := r.publishCopy(delivery, DeadLetterQueue, amqp.Table{DeadLetterReasonHeader: reason}); err != nil {
+		logutils.Error("Failed to dead-letter a message", err, nil)
+		return err
+	}
+	logutils.Warn("Message dead-lettered", logutils.Fields{"id": delivery.MessageId, "routing_key": delivery.RoutingKey, "reason": reason})
+
+	return delivery.Ack(false)
+}
+
+// Republish Publish a copy of a delivery to a queue with the headers added, then ack it.
+// A consumer retries a message this way without redelivering it to the other queues of its routing key.
+func (r *RabbitMQ) Republish(delivery amqp.Delivery, queueName string, headers amqp.Table) error {
+	if err := r.publishCopy(delivery, queueName, headers); err != nil {
+		logutils.Error("Failed to republish a message", err, nil)
+		return err
+	}
+
+	return delivery.Ack(false)
+}
+
+// publishCopy publishes the delivery straight to the queue, its routing key is kept in OriginalRoutingKeyHeader
+func (r *RabbitMQ) publishCopy(delivery amqp.Delivery, queueName string, headers amqp.Table) error {
+	copied := amqp.Table{OriginalRoutingKeyHeader: delivery.RoutingKey}
+	for key, value := range delivery.Headers {
+		copied[key] = value
+	}
+	for key, value := range headers {
+		copied[key] = value
+	}
+
+	return r.Ch.Publish("", queueName, false, false, amqp.Publishing{
+		ContentType:  delivery.ContentType,
+		MessageId:    delivery.MessageId,
+		Timestamp:    delivery.Timestamp,
+		Type:         delivery.Type,
+		Expiration:   delivery.Expiration,
+		Headers:      copied,
+		Body:         delivery.Body,
+		DeliveryMode: amqp.Persistent,
+	})
+}
+
+// CancelConsumer Stop the deliveries of a consumer, closing its channel
+func (r *RabbitMQ) CancelConsumer(consumerTag string) error {
+	r.mu.Lock()
+	ch, ok := r.consumers[consumerTag]
+	delete(r.consumers, consumerTag)
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownConsumer, consumerTag)
+	}
+
+	// A channel the broker already closed has no consumer left to cancel
+	if err := ch.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		logutils.Error("Failed to cancel the consumer", err, nil)
+		return err
+	}
+	logutils.Info("Consumer canceled", map[string]interface{}{"consumer": consumerTag})
+
+	return nil
 }
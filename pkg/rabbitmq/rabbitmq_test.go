@@ -0,0 +1,29 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveDeliveries(t *testing.T) {
+	// Arrange
+	before := testutil.ToFloat64(metrics.Consumed.WithLabelValues("deposit_success"))
+	msgs := make(chan amqp.Delivery, 2)
+	msgs <- amqp.Delivery{Type: "deposit_success", Timestamp: time.Now().Add(-time.Second)}
+	msgs <- amqp.Delivery{Type: "deposit_success"}
+	close(msgs)
+	// Act
+	var received []amqp.Delivery
+	for msg := range observeDeliveries(msgs) {
+		received = append(received, msg)
+	}
+	// Assert, a delivery without timestamp is counted but not observed
+	assert.Len(t, received, 2)
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.Consumed.WithLabelValues("deposit_success")))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.ConsumeLatency, "rote_notify_rabbitmq_consume_latency_seconds"))
+}
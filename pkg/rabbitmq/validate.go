@@ -0,0 +1,98 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxUserIDLength keeps the queue name of the user under the 255 bytes allowed by AMQP
+	MaxUserIDLength = 128
+	// MaxTypeLength bounds the type word of the routing key
+	MaxTypeLength = 64
+)
+
+var (
+	// ErrInvalidUserID is wrapped by the ValidationError of a user ID
+	ErrInvalidUserID = errors.New("invalid user ID")
+	// ErrInvalidType is wrapped by the ValidationError of a message type
+	ErrInvalidType = errors.New("invalid message type")
+	// ErrInvalidRoutingKey is wrapped by the ValidationError of a routing key not matching its message
+	ErrInvalidRoutingKey = errors.New("invalid routing key")
+)
+
+// A topic routing key splits words on '.' and matches '*' and '#', so a user ID or type holding them
+// could bind a queue to the traffic of other users
+var (
+	userIDPattern = regexp.MustCompile(`^[A-Za-z0-9_:@-]+$`)
+	typePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// ValidationError is returned for a user ID or type unsafe in a queue name or routing key
+type ValidationError struct {
+	// Kind is ErrInvalidUserID, ErrInvalidType or ErrInvalidRoutingKey
+	Kind   error
+	Value  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Kind, e.Value, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Kind
+}
+
+// ValidateUserID checks the user ID is letters, digits, '_', '-', ':' or '@'
+func ValidateUserID(userID string) error {
+	switch {
+	case userID == "":
+		return &ValidationError{Kind: ErrInvalidUserID, Value: userID, Reason: "is empty"}
+	case len(userID) > MaxUserIDLength:
+		return &ValidationError{Kind: ErrInvalidUserID, Value: userID, Reason: fmt.Sprintf("is longer than %d bytes", MaxUserIDLength)}
+	case !userIDPattern.MatchString(userID):
+		return &ValidationError{Kind: ErrInvalidUserID, Value: userID, Reason: "must be letters, digits, '_', '-', ':' or '@'"}
+	}
+
+	return nil
+}
+
+// ValidateType checks the type is a single routing key word of letters, digits, '_' or '-'
+func ValidateType(typeMessage string) error {
+	switch {
+	case typeMessage == "":
+		return &ValidationError{Kind: ErrInvalidType, Value: typeMessage, Reason: "is empty"}
+	case len(typeMessage) > MaxTypeLength:
+		return &ValidationError{Kind: ErrInvalidType, Value: typeMessage, Reason: fmt.Sprintf("is longer than %d bytes", MaxTypeLength)}
+	case !typePattern.MatchString(typeMessage):
+		return &ValidationError{Kind: ErrInvalidType, Value: typeMessage, Reason: "must be letters, digits, '_' or '-'"}
+	}
+
+	return nil
+}
+
+// QueueName returns the name of the queue of the user
+func QueueName(userID string) string {
+	return "user_" + userID
+}
+
+// RoutingKey returns the routing key of a message of the type to the user
+func RoutingKey(userID, typeMessage string) string {
+	return fmt.Sprintf("user.%s.%s", userID, typeMessage)
+}
+
+// ParseRoutingKey returns the user ID and type of a routing key built by RoutingKey
+func ParseRoutingKey(routingKey string) (string, string, error) {
+	words := strings.Split(routingKey, ".")
+	if len(words) != 3 || words[0] != "user" {
+		return "", "", &ValidationError{Kind: ErrInvalidRoutingKey, Value: routingKey, Reason: "must be user.<id>.<type>"}
+	}
+	if err := errors.Join(ValidateUserID(words[1]), ValidateType(words[2])); err != nil {
+		return "", "", err
+	}
+
+	return words[1], words[2], nil
+}
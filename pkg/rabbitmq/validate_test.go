@@ -0,0 +1,99 @@
+package rabbitmq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUserID(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		valid  bool
+	}{
+		{name: "Test numeric", userID: "42", valid: true},
+		{name: "Test UUID", userID: "9b2c6f1e-7a1d-4c43-9a53-1f0e5e0b8f11", valid: true},
+		{name: "Test namespaced", userID: "tenant:user_1@app", valid: true},
+		{name: "Test empty", userID: ""},
+		{name: "Test dot splits the routing key", userID: "1.deposit"},
+		{name: "Test star wildcard", userID: "*"},
+		{name: "Test hash wildcard", userID: "#"},
+		{name: "Test space", userID: "user 1"},
+		{name: "Test too long", userID: strings.Repeat("a", MaxUserIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUserID(tt.userID)
+			if tt.valid {
+				assert.Nil(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			assert.ErrorIs(t, err, ErrInvalidUserID)
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.userID, validationErr.Value)
+		})
+	}
+}
+
+func TestValidateType(t *testing.T) {
+	assert.Nil(t, ValidateType("deposit_success"))
+	assert.ErrorIs(t, ValidateType(""), ErrInvalidType)
+	assert.ErrorIs(t, ValidateType("deposit.success"), ErrInvalidType)
+	assert.ErrorIs(t, ValidateType("#"), ErrInvalidType)
+}
+
+func TestParseRoutingKey(t *testing.T) {
+	// Act
+	userID, typeMessage, err := ParseRoutingKey(RoutingKey("1", "deposit"))
+	_, _, queueErr := ParseRoutingKey("notifications_push")
+	_, _, wildcardErr := ParseRoutingKey("user.*.deposit")
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "1", userID)
+	assert.Equal(t, "deposit", typeMessage)
+	assert.ErrorIs(t, queueErr, ErrInvalidRoutingKey)
+	assert.ErrorIs(t, wildcardErr, ErrInvalidUserID)
+}
+
+func TestEntryPointsRejectInvalidInput(t *testing.T) {
+	// Arrange, the checks run before the broker is used
+	r := &RabbitMQ{}
+
+	t.Run("Test queue operations", func(t *testing.T) {
+		// Act
+		createErr := r.CreateUserQueue("#", false)
+		deleteErr := r.DeleteUserQueue("1.*")
+		_, statsErr := r.UserQueueStats("")
+		_, purgeErr := r.PurgeUserQueue("a b")
+		_, tapErr := r.TapUserMessages("1.#")
+		// Assert
+		assert.ErrorIs(t, createErr, ErrInvalidUserID)
+		assert.ErrorIs(t, deleteErr, ErrInvalidUserID)
+		assert.ErrorIs(t, statsErr, ErrInvalidUserID)
+		assert.ErrorIs(t, purgeErr, ErrInvalidUserID)
+		assert.ErrorIs(t, tapErr, ErrInvalidUserID)
+	})
+
+	t.Run("Test PublishMessage", func(t *testing.T) {
+		// Act
+		userErr := r.PublishMessage(Message{UserID: "1.*", Type: "deposit"})
+		typeErr := r.PublishMessage(Message{UserID: "1", Type: "#"})
+		routingErr := r.PublishMessage(Message{UserID: "1", Type: "deposit", RoutingKey: "user.2.deposit"})
+		// Assert
+		assert.ErrorIs(t, userErr, ErrInvalidUserID)
+		assert.ErrorIs(t, typeErr, ErrInvalidType)
+		assert.ErrorIs(t, routingErr, ErrInvalidRoutingKey)
+	})
+
+	t.Run("Test ConsumeMessages closes the channel", func(t *testing.T) {
+		// Act
+		_, open := <-r.ConsumeMessages("#")
+		_, userOpen := <-r.ConsumeUserQueue("1.*", "tag", false)
+		// Assert
+		assert.False(t, open)
+		assert.False(t, userOpen)
+	})
+}
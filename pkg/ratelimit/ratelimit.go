@@ -0,0 +1,347 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+)
+
+// Policy defines what happens to a notification that exceeds the limit
+type Policy string
+
+const (
+	// PolicyDrop rejects the notification with ErrRateLimited
+	PolicyDrop Policy = "drop"
+	// PolicyDelay blocks until a token is available or the context is done
+	PolicyDelay Policy = "delay"
+	// PolicyCollapse keeps only the latest notification per user and type
+	// and sends it once a token is available
+	PolicyCollapse Policy = "collapse"
+)
+
+var (
+	// ErrRateLimited is returned when a notification is dropped by the limiter
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrCollapsed is returned when a notification is collapsed into a pending one
+	ErrCollapsed = errors.New("rate limit exceeded: notification collapsed")
+)
+
+// sweepInterval is how often the idle buckets are evicted
+const sweepInterval = time.Minute
+
+// Config struct, a rate of 0 or less does not limit
+type Config struct {
+	// UserRate is the number of notifications per second allowed for a user
+	UserRate float64
+	// UserBurst is the maximum number of notifications a user can receive at once
+	UserBurst int
+	// TypeRate is the number of notifications per second allowed for a user and type
+	TypeRate float64
+	// TypeBurst is the maximum number of notifications of one type a user can receive at once
+	TypeBurst int
+	// Policy is applied when a notification exceeds the limit
+	Policy Policy
+}
+
+// Stats struct
+type Stats struct {
+	Allowed   uint64 `json:"allowed"`
+	Dropped   uint64 `json:"dropped"`
+	Delayed   uint64 `json:"delayed"`
+	Collapsed uint64 `json:"collapsed"`
+}
+
+// bucket is a token bucket, tokens may go negative when reserved ahead of time
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// collapsed is the latest notification collapsed for a user and type, waiting for its timer
+type collapsed struct {
+	// ctx is the context of the call without its cancellation, it carries the trace into the flush
+	ctx   context.Context
+	fn    func(ctx context.Context) error
+	timer *time.Timer
+}
+
+// Limiter struct
+type Limiter struct {
+	cfg     Config
+	now     func() time.Time
+	mu      sync.Mutex
+	users   map[string]*bucket
+	types   map[string]*bucket
+	pending map[string]*collapsed
+	// flushes tracks the scheduled flushes so Close waits for the running ones
+	flushes sync.WaitGroup
+	// closed drops the notifications that would be collapsed once Close is called
+	closed bool
+	// swept is when the idle buckets were last evicted
+	swept time.Time
+
+	allowed   atomic.Uint64
+	dropped   atomic.Uint64
+	delayed   atomic.Uint64
+	collapsed atomic.Uint64
+}
+
+// NewLimiter creates a new Limiter instance
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyDrop
+	}
+	if cfg.UserBurst <= 0 {
+		cfg.UserBurst = 1
+	}
+	if cfg.TypeBurst <= 0 {
+		cfg.TypeBurst = 1
+	}
+
+	return &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		users:   make(map[string]*bucket),
+		types:   make(map[string]*bucket),
+		pending: make(map[string]*collapsed),
+	}
+}
+
+// Do runs fn for the user and notification type according to the limiter policy
+func (l *Limiter) Do(ctx context.Context, userID, notifyType string, fn func(ctx context.Context) error) error {
+	switch l.cfg.Policy {
+	case PolicyDelay:
+		wait := l.reserve(userID, notifyType)
+		if wait > 0 {
+			l.delayed.Add(1)
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				l.refund(userID, notifyType)
+				return errors.Join(ErrRateLimited, ctx.Err())
+			case <-timer.C:
+			}
+		}
+		l.allowed.Add(1)
+		return fn(ctx)
+
+	case PolicyCollapse:
+		key := typeKey(userID, notifyType)
+
+		// The pending check, the token and the pending set happen under one lock
+		// so concurrent calls cannot both schedule a flush
+		l.mu.Lock()
+		if pending, ok := l.pending[key]; ok {
+			pending.ctx, pending.fn = context.WithoutCancel(ctx), fn
+			l.mu.Unlock()
+			l.collapsed.Add(1)
+			return ErrCollapsed
+		}
+		if l.allowLocked(userID, notifyType) {
+			l.mu.Unlock()
+			l.allowed.Add(1)
+			return fn(ctx)
+		}
+		if l.closed {
+			l.mu.Unlock()
+			l.dropped.Add(1)
+			return ErrRateLimited
+		}
+		pending := &collapsed{ctx: context.WithoutCancel(ctx), fn: fn}
+		l.pending[key] = pending
+		wait := l.reserveLocked(userID, notifyType)
+		l.flushes.Add(1)
+		pending.timer = time.AfterFunc(wait, func() {
+			defer l.flushes.Done()
+			l.flush(key)
+		})
+		l.mu.Unlock()
+
+		l.collapsed.Add(1)
+		return ErrCollapsed
+
+	default:
+		if !l.allow(userID, notifyType) {
+			l.dropped.Add(1)
+			return ErrRateLimited
+		}
+		l.allowed.Add(1)
+		return fn(ctx)
+	}
+}
+
+// Stats returns the limiter counters
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Allowed:   l.allowed.Load(),
+		Dropped:   l.dropped.Load(),
+		Delayed:   l.delayed.Load(),
+		Collapsed: l.collapsed.Load(),
+	}
+}
+
+// Close sends the collapsed notifications without waiting for their timers and waits for the flushes running,
+// so nothing is sent once it returns. Later notifications over the limit are dropped.
+func (l *Limiter) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	pending := l.pending
+	l.pending = make(map[string]*collapsed)
+	l.mu.Unlock()
+
+	var errs []error
+	for key, p := range pending {
+		if p.timer.Stop() {
+			l.flushes.Done()
+		}
+		if err := l.send(key, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	l.flushes.Wait()
+
+	return errors.Join(errs...)
+}
+
+// flush sends the latest collapsed notification for the key
+func (l *Limiter) flush(key string) {
+	l.mu.Lock()
+	p, ok := l.pending[key]
+	delete(l.pending, key)
+	l.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	_ = l.send(key, p)
+}
+
+// send runs a collapsed notification in the context of its call
+func (l *Limiter) send(key string, p *collapsed) error {
+	l.allowed.Add(1)
+	err := p.fn(p.ctx)
+	if err != nil {
+		logutils.Error("Failed to send a collapsed notification", err, logutils.Fields{"key": key})
+	}
+
+	return err
+}
+
+// allow takes a token from both buckets only if both have one available
+func (l *Limiter) allow(userID, notifyType string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.allowLocked(userID, notifyType)
+}
+
+func (l *Limiter) allowLocked(userID, notifyType string) bool {
+	now := l.now()
+	l.sweep(now)
+	user := l.bucket(l.users, userID, l.cfg.UserRate, l.cfg.UserBurst, now)
+	typ := l.bucket(l.types, typeKey(userID, notifyType), l.cfg.TypeRate, l.cfg.TypeBurst, now)
+
+	if user.tokens < 1 || typ.tokens < 1 {
+		return false
+	}
+
+	user.tokens--
+	typ.tokens--
+	return true
+}
+
+// reserve takes a token from both buckets and returns how long to wait before using it
+func (l *Limiter) reserve(userID, notifyType string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.reserveLocked(userID, notifyType)
+}
+
+func (l *Limiter) reserveLocked(userID, notifyType string) time.Duration {
+	now := l.now()
+	l.sweep(now)
+	user := l.bucket(l.users, userID, l.cfg.UserRate, l.cfg.UserBurst, now)
+	typ := l.bucket(l.types, typeKey(userID, notifyType), l.cfg.TypeRate, l.cfg.TypeBurst, now)
+
+	user.tokens--
+	typ.tokens--
+
+	return max(waitFor(user.tokens, l.cfg.UserRate), waitFor(typ.tokens, l.cfg.TypeRate))
+}
+
+// refund gives back a reserved token that was not used
+func (l *Limiter) refund(userID, notifyType string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if user, ok := l.users[userID]; ok {
+		user.tokens = min(float64(l.cfg.UserBurst), user.tokens+1)
+	}
+	if typ, ok := l.types[typeKey(userID, notifyType)]; ok {
+		typ.tokens = min(float64(l.cfg.TypeBurst), typ.tokens+1)
+	}
+}
+
+// bucket returns the refilled bucket for the key
+func (l *Limiter) bucket(buckets map[string]*bucket, key string, rate float64, burst int, now time.Time) *bucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		buckets[key] = b
+		return b
+	}
+
+	b.tokens = b.refilled(rate, burst, now)
+	b.last = now
+
+	return b
+}
+
+// refilled returns the tokens of the bucket at now
+func (b *bucket) refilled(rate float64, burst int, now time.Time) float64 {
+	if rate <= 0 {
+		return float64(burst)
+	}
+
+	return min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+}
+
+// sweep evicts the buckets refilled to their burst once per sweepInterval,
+// a full bucket behaves like a missing one
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for key, b := range l.users {
+		if b.refilled(l.cfg.UserRate, l.cfg.UserBurst, now) >= float64(l.cfg.UserBurst) {
+			delete(l.users, key)
+		}
+	}
+	for key, b := range l.types {
+		if b.refilled(l.cfg.TypeRate, l.cfg.TypeBurst, now) >= float64(l.cfg.TypeBurst) {
+			delete(l.types, key)
+		}
+	}
+}
+
+// waitFor returns the time needed for a bucket to get back to zero tokens
+func waitFor(tokens, rate float64) time.Duration {
+	if tokens >= 0 || rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(-tokens / rate * float64(time.Second))
+}
+
+func typeKey(userID, notifyType string) string {
+	return userID + "." + notifyType
+}
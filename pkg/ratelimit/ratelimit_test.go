@@ -0,0 +1,228 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterDrop(t *testing.T) {
+	t.Run("Test Drop per user and type", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 1, UserBurst: 10, TypeRate: 1, TypeBurst: 2, Policy: PolicyDrop})
+		sent := 0
+		send := func(ctx context.Context) error {
+			sent++
+			return nil
+		}
+		// Act
+		var errs []error
+		for i := 0; i < 4; i++ {
+			errs = append(errs, limiter.Do(context.Background(), "1", "transfer_process", send))
+		}
+		otherType := limiter.Do(context.Background(), "1", "deposit", send)
+		// Assert
+		assert.Nil(t, errs[0])
+		assert.Nil(t, errs[1])
+		assert.ErrorIs(t, errs[2], ErrRateLimited)
+		assert.ErrorIs(t, errs[3], ErrRateLimited)
+		assert.Nil(t, otherType)
+		assert.Equal(t, 3, sent)
+		assert.Equal(t, Stats{Allowed: 3, Dropped: 2}, limiter.Stats())
+	})
+
+	t.Run("Test Drop per user", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 1, UserBurst: 1, TypeRate: 1, TypeBurst: 5})
+		send := func(ctx context.Context) error { return nil }
+		// Act
+		first := limiter.Do(context.Background(), "1", "deposit", send)
+		second := limiter.Do(context.Background(), "1", "withdraw", send)
+		otherUser := limiter.Do(context.Background(), "2", "withdraw", send)
+		// Assert
+		assert.Nil(t, first)
+		assert.ErrorIs(t, second, ErrRateLimited)
+		assert.Nil(t, otherUser)
+	})
+
+	t.Run("Test Drop refills over time", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		limiter := NewLimiter(Config{UserRate: 1, UserBurst: 1, TypeRate: 1, TypeBurst: 1})
+		limiter.now = func() time.Time { return now }
+		send := func(ctx context.Context) error { return nil }
+		// Act
+		first := limiter.Do(context.Background(), "1", "deposit", send)
+		second := limiter.Do(context.Background(), "1", "deposit", send)
+		now = now.Add(time.Second)
+		third := limiter.Do(context.Background(), "1", "deposit", send)
+		// Assert
+		assert.Nil(t, first)
+		assert.ErrorIs(t, second, ErrRateLimited)
+		assert.Nil(t, third)
+	})
+}
+
+func TestLimiterDelay(t *testing.T) {
+	t.Run("Test Delay waits for a token", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 20, UserBurst: 1, TypeRate: 20, TypeBurst: 1, Policy: PolicyDelay})
+		send := func(ctx context.Context) error { return nil }
+		// Act
+		start := time.Now()
+		first := limiter.Do(context.Background(), "1", "deposit", send)
+		second := limiter.Do(context.Background(), "1", "deposit", send)
+		// Assert
+		assert.Nil(t, first)
+		assert.Nil(t, second)
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+		assert.Equal(t, uint64(1), limiter.Stats().Delayed)
+	})
+
+	t.Run("Test Delay honours the context", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 0.1, UserBurst: 1, TypeRate: 0.1, TypeBurst: 1, Policy: PolicyDelay})
+		send := func(ctx context.Context) error { return nil }
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		// Act
+		_ = limiter.Do(ctx, "1", "deposit", send)
+		err := limiter.Do(ctx, "1", "deposit", send)
+		// Assert
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Test Delay refunds the token on cancel", func(t *testing.T) {
+		// Arrange
+		now := time.Now()
+		limiter := NewLimiter(Config{UserRate: 1, UserBurst: 1, TypeRate: 1, TypeBurst: 1, Policy: PolicyDelay})
+		limiter.now = func() time.Time { return now }
+		send := func(ctx context.Context) error { return nil }
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		// Act
+		_ = limiter.Do(context.Background(), "1", "deposit", send)
+		canceled := limiter.Do(ctx, "1", "deposit", send)
+		now = now.Add(time.Second)
+		// Assert, a second refills the token spent, not the one given up
+		assert.ErrorIs(t, canceled, context.Canceled)
+		assert.True(t, limiter.allow("1", "deposit"))
+	})
+}
+
+func TestLimiterSweep(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	limiter := NewLimiter(Config{UserRate: 1, UserBurst: 2, TypeRate: 1, TypeBurst: 1})
+	limiter.now = func() time.Time { return now }
+	send := func(ctx context.Context) error { return nil }
+	_ = limiter.Do(context.Background(), "1", "deposit", send)
+	now = now.Add(sweepInterval)
+	// Act
+	_ = limiter.Do(context.Background(), "2", "deposit", send)
+	// Assert, the idle buckets of the first user are evicted
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	assert.Len(t, limiter.users, 1)
+	assert.Len(t, limiter.types, 1)
+	assert.Contains(t, limiter.users, "2")
+}
+
+func TestLimiterCollapse(t *testing.T) {
+	t.Run("Test Collapse sends only the latest notification", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 20, UserBurst: 1, TypeRate: 20, TypeBurst: 1, Policy: PolicyCollapse})
+		var last atomic.Int32
+		var calls atomic.Int32
+		send := func(n int32) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				calls.Add(1)
+				last.Store(n)
+				return nil
+			}
+		}
+		// Act
+		first := limiter.Do(context.Background(), "1", "new_post", send(1))
+		second := limiter.Do(context.Background(), "1", "new_post", send(2))
+		third := limiter.Do(context.Background(), "1", "new_post", send(3))
+		// Assert
+		assert.Nil(t, first)
+		assert.True(t, errors.Is(second, ErrCollapsed))
+		assert.True(t, errors.Is(third, ErrCollapsed))
+		assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(3), last.Load())
+		assert.Equal(t, Stats{Allowed: 2, Collapsed: 2}, limiter.Stats())
+	})
+
+	t.Run("Test concurrent Collapse schedules one flush", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 20, UserBurst: 1, TypeRate: 20, TypeBurst: 1, Policy: PolicyCollapse})
+		var calls atomic.Int32
+		send := func(ctx context.Context) error {
+			calls.Add(1)
+			return nil
+		}
+		_ = limiter.Do(context.Background(), "1", "new_post", send)
+		// Act
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = limiter.Do(context.Background(), "1", "new_post", send)
+			}()
+		}
+		wg.Wait()
+		// Assert
+		assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+	t.Run("Test Collapse flushes in the context of the call", func(t *testing.T) {
+		// Arrange
+		type traceKey struct{}
+		limiter := NewLimiter(Config{UserRate: 20, UserBurst: 1, TypeRate: 20, TypeBurst: 1, Policy: PolicyCollapse})
+		flushed := make(chan context.Context, 1)
+		_ = limiter.Do(context.Background(), "1", "new_post", func(ctx context.Context) error { return nil })
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "span"))
+		// Act
+		err := limiter.Do(ctx, "1", "new_post", func(ctx context.Context) error {
+			flushed <- ctx
+			return nil
+		})
+		cancel()
+		got := <-flushed
+		// Assert, the values are kept but not the cancellation of the request
+		assert.ErrorIs(t, err, ErrCollapsed)
+		assert.Equal(t, "span", got.Value(traceKey{}))
+		assert.Nil(t, got.Err())
+	})
+
+	t.Run("Test Close sends the pending notifications once", func(t *testing.T) {
+		// Arrange
+		limiter := NewLimiter(Config{UserRate: 1, UserBurst: 1, TypeRate: 1, TypeBurst: 1, Policy: PolicyCollapse})
+		var calls atomic.Int32
+		send := func(ctx context.Context) error {
+			calls.Add(1)
+			return nil
+		}
+		_ = limiter.Do(context.Background(), "1", "new_post", send)
+		_ = limiter.Do(context.Background(), "1", "new_post", send)
+		// Act
+		err := limiter.Close()
+		sentOnClose := calls.Load()
+		afterClose := limiter.Do(context.Background(), "1", "new_post", send)
+		time.Sleep(1100 * time.Millisecond)
+		// Assert, the timer of the flushed notification does not send it again
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), sentOnClose)
+		assert.ErrorIs(t, afterClose, ErrRateLimited)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
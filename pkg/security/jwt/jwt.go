@@ -8,6 +8,7 @@ import (
 
 	"github.com/Mona-bele/logutils-go/logutils"
 	"github.com/Mona-bele/rote-notify/pkg/env"
+	"github.com/Mona-bele/rote-notify/pkg/metrics"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -17,6 +18,16 @@ const (
 	Algorithm  = "RS256"
 )
 
+const (
+	// TokenUseClaim tells notification tokens from client tokens, both are signed with the same key
+	TokenUseClaim        = "token_use"
+	TokenUseNotification = "notification"
+	TokenUseUser         = "user"
+)
+
+// ErrTokenUse is returned when parsing a token issued for another use
+var ErrTokenUse = errors.New("token is issued for another use")
+
 // JWT struct
 type JWT struct {
 	PrivateKey *rsa.PrivateKey
@@ -28,21 +39,30 @@ func NewJWT(privateKey *rsa.PrivateKey, env *env.Env) *JWT {
 	return &JWT{PrivateKey: privateKey, Env: env}
 }
 
-// GenerateToken generates a JWT token
+// GenerateToken generates a JWT token valid for ExpireTime
 func (j *JWT) GenerateToken(payload, issuer, audience, subject string) (string, error) {
+	return j.GenerateTokenWithExpiry(payload, issuer, audience, subject, ExpireTime)
+}
+
+// GenerateTokenWithExpiry generates a JWT token valid for the ttl
+func (j *JWT) GenerateTokenWithExpiry(payload, issuer, audience, subject string, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
-		"exp":     time.Now().Add(ExpireTime).Unix(),
+		"exp":     time.Now().Add(ttl).Unix(),
 		"iat":     time.Now().Unix(),
 		"sub":     subject,
 		"iss":     issuer,
 		"aud":     audience,
 		"payload": payload,
+
+		TokenUseClaim: TokenUseNotification,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	token.Header["kid"] = j.JwtKid
 
+	start := time.Now()
 	signedToken, err := token.SignedString(j.PrivateKey)
+	metrics.Observe(metrics.TokensSigned, metrics.SignDuration, start, metrics.Outcome(err))
 	if err != nil {
 		logutils.Error("Failed to sign the token", err, nil)
 		return "", err
@@ -51,7 +71,60 @@ func (j *JWT) GenerateToken(payload, issuer, audience, subject string) (string,
 	return signedToken, nil
 }
 
-// ParseToken parses a JWT token
+// GenerateUserToken generates a JWT token authenticating a client as the user ID
+func (j *JWT) GenerateUserToken(userID, issuer, audience string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"exp": time.Now().Add(ttl).Unix(),
+		"iat": time.Now().Unix(),
+		"sub": userID,
+		"iss": issuer,
+		"aud": audience,
+
+		TokenUseClaim: TokenUseUser,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = j.JwtKid
+
+	signedToken, err := token.SignedString(j.PrivateKey)
+	if err != nil {
+		logutils.Error("Failed to sign the token", err, nil)
+		return "", err
+	}
+
+	return signedToken, nil
+}
+
+// ParseUserToken parses a client JWT token and returns its user ID, notification tokens are rejected
+// with ErrTokenUse so a token received by a client cannot authenticate it
+func (j *JWT) ParseUserToken(tokenString, issuer, audience string) (string, error) {
+	token, err := jwt.Parse(tokenString,
+		j.ValidateToken,
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithValidMethods([]string{Algorithm}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		logutils.Error("Failed to parse the user token", err, nil)
+		return "", err
+	}
+	if use, _ := token.Claims.(jwt.MapClaims)[TokenUseClaim].(string); use != TokenUseUser {
+		logutils.Error("Token is not a user token", ErrTokenUse, nil)
+		return "", ErrTokenUse
+	}
+
+	userID, err := token.Claims.GetSubject()
+	if err != nil || userID == "" {
+		logutils.Error("User token subject is missing", err, nil)
+		return "", errors.New("user token subject is missing")
+	}
+
+	return userID, nil
+}
+
+// ParseToken parses a notification JWT token, user tokens are rejected with ErrTokenUse.
+// Tokens signed before the token_use claim are accepted.
 func (j *JWT) ParseToken(tokenString, issuer, audience, subject string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString,
 		j.ValidateToken,
@@ -69,6 +142,10 @@ func (j *JWT) ParseToken(tokenString, issuer, audience, subject string) (*jwt.To
 		logutils.Error("Token is invalid", nil, nil)
 		return nil, errors.New("token is invalid")
 	}
+	if use, ok := token.Claims.(jwt.MapClaims)[TokenUseClaim].(string); ok && use != TokenUseNotification {
+		logutils.Error("Token is not a notification token", ErrTokenUse, nil)
+		return nil, ErrTokenUse
+	}
 
 	return token, nil
 }
@@ -95,6 +172,31 @@ func (j *JWT) ValidateToken(token *jwt.Token) (any, error) {
 	}, nil
 }
 
+// SelfTest signs a short-lived token and verifies it, failing on a missing or invalid key
+func (j *JWT) SelfTest() error {
+	if j.PrivateKey == nil {
+		return errors.New("private key is missing")
+	}
+	if err := j.PrivateKey.Validate(); err != nil {
+		return err
+	}
+
+	// Signed directly so probes do not count as notification tokens
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"sub": "self-test",
+	})
+	token.Header["kid"] = j.JwtKid
+
+	signedToken, err := token.SignedString(j.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = jwt.Parse(signedToken, j.ValidateToken, jwt.WithValidMethods([]string{Algorithm}))
+	return err
+}
+
 // GetPayload returns the payload from a JWT token
 func (j *JWT) GetPayload(token *jwt.Token) string {
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Mona-bele/rote-notify/pkg/env"
 	"github.com/stretchr/testify/assert"
@@ -182,3 +183,38 @@ func TestParseToken(t *testing.T) {
 		}
 	})
 }
+
+func TestUserToken(t *testing.T) {
+	t.Run("Test ParseUserToken", func(t *testing.T) {
+		// TestParseUserToken tests the ParseUserToken function
+		// It should return the user ID of the token
+		// Arrange
+		privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		jwt := NewJWT(privateKey, &env.Env{JwtKid: "JWT_KID_1234"})
+		token, err := jwt.GenerateUserToken("42", "issuer", "gateway", time.Minute)
+		assert.Nil(t, err)
+		// Act
+		userID, err := jwt.ParseUserToken(token, "issuer", "gateway")
+		_, errAudience := jwt.ParseUserToken(token, "issuer", "other")
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, "42", userID)
+		assert.NotNil(t, errAudience)
+	})
+
+	t.Run("Test tokens are rejected for the other use", func(t *testing.T) {
+		// Arrange
+		privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		jwt := NewJWT(privateKey, &env.Env{JwtKid: "JWT_KID_1234"})
+		notification, err := jwt.GenerateTokenWithExpiry("{}", "issuer", "gateway", "42", time.Minute)
+		assert.Nil(t, err)
+		user, err := jwt.GenerateUserToken("42", "issuer", "gateway", time.Minute)
+		assert.Nil(t, err)
+		// Act
+		_, errUser := jwt.ParseUserToken(notification, "issuer", "gateway")
+		_, errNotification := jwt.ParseToken(user, "issuer", "gateway", "42")
+		// Assert
+		assert.ErrorIs(t, errUser, ErrTokenUse)
+		assert.ErrorIs(t, errNotification, ErrTokenUse)
+	})
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"type":"deposit_success"}`)
+
+	t.Run("Test Verify a valid signature", func(t *testing.T) {
+		header := Sign(secret, time.Now(), body)
+		assert.Nil(t, Verify(secret, header, body, DefaultTolerance))
+	})
+
+	t.Run("Test Verify a tampered body", func(t *testing.T) {
+		header := Sign(secret, time.Now(), body)
+		assert.ErrorIs(t, Verify(secret, header, []byte(`{"type":"withdraw_success"}`), DefaultTolerance), ErrInvalidSignature)
+	})
+
+	t.Run("Test Verify a wrong secret", func(t *testing.T) {
+		header := Sign([]byte("other"), time.Now(), body)
+		assert.ErrorIs(t, Verify(secret, header, body, DefaultTolerance), ErrInvalidSignature)
+	})
+
+	t.Run("Test Verify an old signature", func(t *testing.T) {
+		header := Sign(secret, time.Now().Add(-time.Hour), body)
+		assert.ErrorIs(t, Verify(secret, header, body, DefaultTolerance), ErrExpiredSignature)
+	})
+
+	t.Run("Test Verify an invalid header", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(secret, "v1=zz", body, DefaultTolerance), ErrInvalidHeader)
+	})
+}
@@ -0,0 +1,225 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Mona-bele/logutils-go/logutils"
+	"github.com/Mona-bele/rote-notify/pkg/security/webhook"
+)
+
+// maxReceiptSize is the largest receipt body read
+const maxReceiptSize = 64 * 1024
+
+// ErrUnauthenticatedReceipt is wrapped by the errors of receipts without a valid signature
+var ErrUnauthenticatedReceipt = errors.New("sms receipt is not authenticated")
+
+// HTTPProviderConfig struct
+type HTTPProviderConfig struct {
+	Name     string
+	Endpoint string
+	From     string
+	// Username and Password enable basic auth
+	Username string
+	Password string
+	// Token enables bearer auth
+	Token string
+	// APIKeyHeader and APIKey send the key in a custom header
+	APIKeyHeader string
+	APIKey       string
+	// ToField, FromField and TextField name the request fields, defaults are to, from and text
+	ToField   string
+	FromField string
+	TextField string
+	// IDField names the message ID in responses and receipts, defaults to id
+	IDField string
+	// StatusField names the status in receipts, defaults to status
+	StatusField string
+	// StatusMap maps provider receipt statuses, unknown statuses are pending
+	StatusMap map[string]ReceiptStatus
+	// ReceiptSecret signs the receipts as webhook.Sign does, receipts are rejected when empty
+	ReceiptSecret []byte
+	// ReceiptSignatureHeader carries the receipt signature, defaults to webhook.SignatureHeader
+	ReceiptSignatureHeader string
+	Client                 *http.Client
+}
+
+// HTTPProvider struct
+type HTTPProvider struct {
+	cfg HTTPProviderConfig
+}
+
+// NewHTTPProvider creates a new generic JSON over HTTP SMS provider
+func NewHTTPProvider(cfg HTTPProviderConfig) *HTTPProvider {
+	if cfg.Name == "" {
+		cfg.Name = "http"
+	}
+	if cfg.ToField == "" {
+		cfg.ToField = "to"
+	}
+	if cfg.FromField == "" {
+		cfg.FromField = "from"
+	}
+	if cfg.TextField == "" {
+		cfg.TextField = "text"
+	}
+	if cfg.IDField == "" {
+		cfg.IDField = "id"
+	}
+	if cfg.StatusField == "" {
+		cfg.StatusField = "status"
+	}
+	if cfg.StatusMap == nil {
+		cfg.StatusMap = map[string]ReceiptStatus{
+			"delivered":   StatusDelivered,
+			"failed":      StatusFailed,
+			"undelivered": StatusFailed,
+		}
+	}
+	if cfg.ReceiptSignatureHeader == "" {
+		cfg.ReceiptSignatureHeader = webhook.SignatureHeader
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &HTTPProvider{cfg: cfg}
+}
+
+// Name returns the provider name
+func (p *HTTPProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Send sends the text to the phone number
+func (p *HTTPProvider) Send(ctx context.Context, to, text string) (Result, error) {
+	payload, err := json.Marshal(map[string]string{
+		p.cfg.ToField:   to,
+		p.cfg.FromField: p.cfg.From,
+		p.cfg.TextField: text,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch {
+	case p.cfg.Username != "":
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	case p.cfg.Token != "":
+		req.Header.Set("Authorization", "Bearer "+p.cfg.Token)
+	}
+	if p.cfg.APIKeyHeader != "" {
+		req.Header.Set(p.cfg.APIKeyHeader, p.cfg.APIKey)
+	}
+
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logutils.Error("SMS provider rejected the message", nil, logutils.Fields{"provider": p.Name(), "status": resp.StatusCode})
+		return Result{}, fmt.Errorf("%s: status %d: %s", p.Name(), resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	fields, err := decodeFields(body)
+	if err != nil {
+		return Result{}, err
+	}
+	id, ok := field(fields, p.cfg.IDField)
+	if !ok {
+		return Result{}, fmt.Errorf("%s: response without %s", p.Name(), p.cfg.IDField)
+	}
+
+	encoding, segments := Segments(text)
+
+	return Result{
+		MessageID: id,
+		Encoding:  encoding,
+		Segments:  segments,
+	}, nil
+}
+
+// ParseReceipt verifies the signature of a JSON delivery receipt posted by the provider and parses it
+func (p *HTTPProvider) ParseReceipt(r *http.Request) (Receipt, error) {
+	if len(p.cfg.ReceiptSecret) == 0 {
+		return Receipt{}, fmt.Errorf("%w: %s: no receipt secret configured", ErrUnauthenticatedReceipt, p.Name())
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReceiptSize))
+	if err != nil {
+		return Receipt{}, err
+	}
+	if err := webhook.Verify(p.cfg.ReceiptSecret, r.Header.Get(p.cfg.ReceiptSignatureHeader), body, webhook.DefaultTolerance); err != nil {
+		return Receipt{}, fmt.Errorf("%w: %s: %w", ErrUnauthenticatedReceipt, p.Name(), err)
+	}
+
+	fields, err := decodeFields(body)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	id, ok := field(fields, p.cfg.IDField)
+	if !ok {
+		return Receipt{}, fmt.Errorf("%s: receipt without %s", p.Name(), p.cfg.IDField)
+	}
+	raw, ok := field(fields, p.cfg.StatusField)
+	if !ok {
+		return Receipt{}, fmt.Errorf("%s: receipt without %s", p.Name(), p.cfg.StatusField)
+	}
+
+	status, ok := p.cfg.StatusMap[raw]
+	if !ok {
+		status = StatusPending
+	}
+
+	receipt := Receipt{Provider: p.Name(), MessageID: id, Status: status}
+	if status == StatusFailed {
+		receipt.Error = raw
+	}
+
+	return receipt, nil
+}
+
+// decodeFields decodes a JSON object keeping its numbers as written
+func decodeFields(body []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// field returns a string or number field as text, it is false when the field is missing, empty or of another type
+func field(fields map[string]any, name string) (string, bool) {
+	var value string
+	switch v := fields[name].(type) {
+	case string:
+		value = v
+	case json.Number:
+		value = v.String()
+	}
+
+	return value, value != ""
+}
@@ -0,0 +1,80 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mona-bele/rote-notify/pkg/security/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	t.Run("Test Send reads the message ID", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			response string
+			id       string
+			err      string
+		}{
+			{name: "Test string ID", response: `{"id":"SM1"}`, id: "SM1"},
+			{name: "Test numeric ID", response: `{"id":123456789012}`, id: "123456789012"},
+			{name: "Test missing ID", response: `{"sid":"SM1"}`, err: "http: response without id"},
+			{name: "Test empty ID", response: `{"id":""}`, err: "http: response without id"},
+			{name: "Test null ID", response: `{"id":null}`, err: "http: response without id"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Arrange
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(tt.response))
+				}))
+				defer server.Close()
+				provider := NewHTTPProvider(HTTPProviderConfig{Endpoint: server.URL})
+				// Act
+				result, err := provider.Send(context.Background(), "+5511999999999", "hello")
+				// Assert
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, tt.id, result.MessageID)
+			})
+		}
+	})
+
+	t.Run("Test ParseReceipt", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			body    string
+			receipt Receipt
+			err     string
+		}{
+			{name: "Test numeric ID", body: `{"id":123456789012,"status":"delivered"}`, receipt: Receipt{Provider: "http", MessageID: "123456789012", Status: StatusDelivered}},
+			{name: "Test unknown status", body: `{"id":"SM1","status":"queued"}`, receipt: Receipt{Provider: "http", MessageID: "SM1", Status: StatusPending}},
+			{name: "Test missing ID", body: `{"status":"delivered"}`, err: "http: receipt without id"},
+			{name: "Test missing status", body: `{"id":"SM1"}`, err: "http: receipt without status"},
+		}
+		provider := NewHTTPProvider(HTTPProviderConfig{ReceiptSecret: []byte("receipt-secret")})
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Arrange
+				req := httptest.NewRequest(http.MethodPost, "/receipts", strings.NewReader(tt.body))
+				req.Header.Set(webhook.SignatureHeader, webhook.Sign([]byte("receipt-secret"), time.Now(), []byte(tt.body)))
+				// Act
+				receipt, err := provider.ParseReceipt(req)
+				// Assert
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, tt.receipt, receipt)
+			})
+		}
+	})
+}
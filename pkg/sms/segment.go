@@ -0,0 +1,111 @@
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding is the character encoding used to send an SMS
+type Encoding string
+
+const (
+	EncodingGSM7 Encoding = "gsm7"
+	EncodingUCS2 Encoding = "ucs2"
+)
+
+const (
+	gsm7Single = 160
+	gsm7Part   = 153
+	ucs2Single = 70
+	ucs2Part   = 67
+)
+
+// gsm7Basic is the GSM 03.38 basic character set
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extended are the characters sent with an escape, they take two septets
+const gsm7Extended = "^{}\\[~]|€\f"
+
+// units returns the encoding of the text and the size of each character in that encoding
+func units(text string) (Encoding, []int) {
+	sizes := make([]int, 0, len(text))
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			sizes = append(sizes, 1)
+		case strings.ContainsRune(gsm7Extended, r):
+			sizes = append(sizes, 2)
+		default:
+			sizes = sizes[:0]
+			for _, r := range text {
+				sizes = append(sizes, len(utf16.Encode([]rune{r})))
+			}
+			return EncodingUCS2, sizes
+		}
+	}
+
+	return EncodingGSM7, sizes
+}
+
+// limits returns the size of a single message and of each part of a concatenated message
+func limits(encoding Encoding) (int, int) {
+	if encoding == EncodingUCS2 {
+		return ucs2Single, ucs2Part
+	}
+
+	return gsm7Single, gsm7Part
+}
+
+// Segments returns the encoding and the number of segments needed to send the text
+func Segments(text string) (Encoding, int) {
+	encoding, sizes := units(text)
+	single, part := limits(encoding)
+
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	if total <= single {
+		return encoding, 1
+	}
+
+	// Characters are never split across parts, so count them part by part
+	segments, used := 1, 0
+	for _, size := range sizes {
+		if used+size > part {
+			segments++
+			used = 0
+		}
+		used += size
+	}
+
+	return encoding, segments
+}
+
+// Truncate shortens the text to fit in maxSegments, ending it with an ellipsis
+func Truncate(text string, maxSegments int) string {
+	if maxSegments <= 0 {
+		return text
+	}
+	encoding, segments := Segments(text)
+	if segments <= maxSegments {
+		return text
+	}
+
+	// Keep GSM-7 texts in GSM-7, a unicode ellipsis would halve the room left
+	ellipsis := "..."
+	if encoding == EncodingUCS2 {
+		ellipsis = "…"
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + ellipsis
+		if _, segments := Segments(candidate); segments <= maxSegments {
+			return candidate
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,53 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding Encoding
+		segments int
+	}{
+		{name: "Test GSM-7 single", text: strings.Repeat("a", 160), encoding: EncodingGSM7, segments: 1},
+		{name: "Test GSM-7 concatenated", text: strings.Repeat("a", 161), encoding: EncodingGSM7, segments: 2},
+		{name: "Test GSM-7 extended characters", text: strings.Repeat("€", 80) + "a", encoding: EncodingGSM7, segments: 2},
+		{name: "Test UCS-2 single", text: strings.Repeat("ç", 70), encoding: EncodingUCS2, segments: 1},
+		{name: "Test UCS-2 concatenated", text: strings.Repeat("ç", 71), encoding: EncodingUCS2, segments: 2},
+		{name: "Test UCS-2 surrogate pairs", text: strings.Repeat("😀", 35), encoding: EncodingUCS2, segments: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoding, segments := Segments(tt.text)
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.segments, segments)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Run("Test Truncate keeps short texts", func(t *testing.T) {
+		assert.Equal(t, "Withdraw failed", Truncate("Withdraw failed", 1))
+	})
+
+	t.Run("Test Truncate GSM-7", func(t *testing.T) {
+		got := Truncate(strings.Repeat("a", 200), 1)
+		_, segments := Segments(got)
+		assert.Equal(t, 1, segments)
+		assert.Len(t, got, 160)
+		assert.True(t, strings.HasSuffix(got, "..."))
+	})
+
+	t.Run("Test Truncate UCS-2", func(t *testing.T) {
+		got := Truncate(strings.Repeat("ç", 100), 1)
+		encoding, segments := Segments(got)
+		assert.Equal(t, EncodingUCS2, encoding)
+		assert.Equal(t, 1, segments)
+		assert.True(t, strings.HasSuffix(got, "…"))
+	})
+}
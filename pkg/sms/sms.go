@@ -0,0 +1,37 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+)
+
+// ReceiptStatus is the delivery state reported by a provider
+type ReceiptStatus string
+
+const (
+	StatusPending   ReceiptStatus = "pending"
+	StatusDelivered ReceiptStatus = "delivered"
+	StatusFailed    ReceiptStatus = "failed"
+)
+
+// Result struct
+type Result struct {
+	MessageID string   `json:"message_id"`
+	Encoding  Encoding `json:"encoding"`
+	Segments  int      `json:"segments"`
+}
+
+// Receipt struct
+type Receipt struct {
+	Provider  string        `json:"provider"`
+	MessageID string        `json:"message_id"`
+	Status    ReceiptStatus `json:"status"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Provider sends SMS and parses its delivery receipts
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, to, text string) (Result, error)
+	ParseReceipt(r *http.Request) (Receipt, error)
+}
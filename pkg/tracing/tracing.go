@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Mona-bele/rote-notify"
+
+// Propagator carries the W3C trace context and baggage in the AMQP headers
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracer returns the tracer of the global provider, set it with otel.SetTracerProvider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject writes the trace context of ctx into the headers
+func Inject(ctx context.Context, headers map[string]string) {
+	Propagator.Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// ExtractDelivery returns ctx with the trace context carried by the delivery headers
+func ExtractDelivery(ctx context.Context, delivery amqp.Delivery) context.Context {
+	return Propagator.Extract(ctx, tableCarrier(delivery.Headers))
+}
+
+// End records the error on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tableCarrier reads the string values of AMQP headers
+type tableCarrier amqp.Table
+
+func (c tableCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c tableCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c tableCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func TestPropagation(t *testing.T) {
+	// Arrange
+	exporter := newTestProvider(t)
+
+	t.Run("Test the consumer continues the trace of the publisher", func(t *testing.T) {
+		// Arrange
+		exporter.Reset()
+		ctx, publish := Tracer().Start(context.Background(), "publish")
+		headers := map[string]string{}
+		Inject(ctx, headers)
+		publish.End()
+
+		table := amqp.Table{}
+		for key, value := range headers {
+			table[key] = value
+		}
+		// Act
+		ctx = ExtractDelivery(context.Background(), amqp.Delivery{Headers: table})
+		_, deliver := Tracer().Start(ctx, "deliver")
+		deliver.End()
+		// Assert
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 2)
+		assert.Contains(t, headers, "traceparent")
+		assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+		assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+		assert.True(t, spans[1].Parent.IsRemote())
+	})
+
+	t.Run("Test a delivery without headers starts a new trace", func(t *testing.T) {
+		// Act
+		ctx := ExtractDelivery(context.Background(), amqp.Delivery{})
+		// Assert
+		assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+	})
+
+	t.Run("Test End records the error", func(t *testing.T) {
+		// Arrange
+		exporter.Reset()
+		_, span := Tracer().Start(context.Background(), "sign")
+		// Act
+		End(span, errors.New("sign failed"))
+		// Assert
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, "sign failed", spans[0].Status.Description)
+	})
+}